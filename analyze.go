@@ -0,0 +1,50 @@
+package testfixtures
+
+import (
+	"fmt"
+)
+
+// AnalyzeAfterLoad makes Loader update the statistics of the tables that got
+// records once they are loaded, so the query planner doesn't use stale ones
+// in the tests. The statements run outside of the loading transaction:
+// "ANALYZE" on PostgreSQL and SQLite, "ANALYZE TABLE" on MySQL and
+// "UPDATE STATISTICS" on SQL Server.
+//
+// If a statement fails, the load returns an error, unless AnalyzeBestEffort
+// is given.
+func AnalyzeAfterLoad() func(*Loader) error {
+	return func(l *Loader) error {
+		l.analyzeAfterLoad = true
+		return nil
+	}
+}
+
+// AnalyzeBestEffort makes the failures of the statements run by
+// AnalyzeAfterLoad non-fatal: they are reported in LoadResult.AnalyzeErrors
+// instead. It has no effect without AnalyzeAfterLoad.
+func AnalyzeBestEffort() func(*Loader) error {
+	return func(l *Loader) error {
+		l.analyzeBestEffort = true
+		return nil
+	}
+}
+
+// analyze updates the statistics of the tables that got records in a load.
+// See AnalyzeAfterLoad.
+func (l *Loader) analyze(result *LoadResult) error {
+	h := l.helper.(analyzer)
+	for _, tableName := range result.tables {
+		query := h.analyzeTableSQL(tableName)
+		if l.logger != nil {
+			l.logger(query, nil)
+		}
+		if _, err := l.db.Exec(query); err != nil {
+			err = fmt.Errorf("testfixtures: could not update the statistics of table %s: %w", tableName, err)
+			if !l.analyzeBestEffort {
+				return err
+			}
+			result.AnalyzeErrors = append(result.AnalyzeErrors, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// AroundTable wraps the inserts of the records of a table with fn, which
+// must call next to insert them, in the transaction given to it, like to
+// disable a trigger of the table before calling next and enable it again
+// after. The wrappers are called around the one of the dialect, like the one
+// allowing identity inserts on SQL Server, the first one given first.
+//
+// The table is named like in DeleteWhere.
+func AroundTable(table string, fn func(tx *sql.Tx, next func() error) error) func(*Loader) error {
+	return func(l *Loader) error {
+		if fn == nil {
+			return fmt.Errorf(`testfixtures: AroundTable requires a function for table "%s"`, table)
+		}
+		if l.aroundTable == nil {
+			l.aroundTable = make(map[string][]func(*sql.Tx, func() error) error)
+		}
+		l.aroundTable[table] = append(l.aroundTable[table], fn)
+		return nil
+	}
+}
+
+// whileInsertOnTable calls fn wrapped by the functions given with
+// AroundTable for the table, and by the one of the dialect, which receives
+// the columns set by the records of the file.
+func (l *Loader) whileInsertOnTable(tx *sql.Tx, file *fixtureFile, fn func() error) error {
+	tableName := file.fileNameWithoutExtension()
+	next := func() error {
+		return l.helper.whileInsertOnTable(tx, tableName, file.insertedColumns(), fn)
+	}
+	wrappers := l.aroundTable[l.optionsTableName(tableName)]
+	for i := len(wrappers) - 1; i >= 0; i-- {
+		wrapper, inner := wrappers[i], next
+		next = func() error {
+			return wrapper(tx, inner)
+		}
+	}
+	return next()
+}
@@ -0,0 +1,156 @@
+package testfixtures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// CascadeCleanup makes Loader also delete all the rows of the tables without
+// fixtures referencing, directly or not, the tables with fixtures, so no row
+// is left referencing a deleted one. The tables are found with the foreign
+// keys of the database, and cleaned before the tables with fixtures, the
+// ones referencing others first. Their names are given by
+// LoadResult.CascadedTables, and the statements are sent to the Logger.
+//
+// Only supported on PostgreSQL, MySQL, SQLite and SQL Server.
+func CascadeCleanup() func(*Loader) error {
+	return func(l *Loader) error {
+		l.cascadeCleanup = true
+		return nil
+	}
+}
+
+// dependentTables returns the tables without fixtures referencing the tables
+// of the files, directly or not, in the order they should be cleaned.
+func (l *Loader) dependentTables(q queryable, files []*fixtureFile) ([]string, error) {
+	keys, err := l.helper.(foreignKeyLister).foreignKeys(q)
+	if err != nil {
+		return nil, fmt.Errorf("testfixtures: could not list the foreign keys: %w", err)
+	}
+
+	var (
+		referencing = make(map[string][]string)
+		references  = make(map[string][]string)
+		names       = make(map[string]string)
+	)
+	for _, key := range keys {
+		table, referenced := unqualifiedTableName(key.table), unqualifiedTableName(key.referencedTable)
+		if !containsString(referencing[referenced], table) {
+			referencing[referenced] = append(referencing[referenced], table)
+		}
+		if table != referenced && !containsString(references[table], referenced) {
+			references[table] = append(references[table], referenced)
+		}
+		names[table] = unquotedTableName(key.table)
+	}
+
+	var (
+		found  = make(map[string]bool)
+		queue  []string
+		tables []string
+	)
+	for _, file := range files {
+		table := unqualifiedTableName(file.fileNameWithoutExtension())
+		if !found[table] {
+			found[table] = true
+			queue = append(queue, table)
+		}
+	}
+	for len(queue) > 0 {
+		table := queue[0]
+		queue = queue[1:]
+		for _, dependent := range referencing[table] {
+			if found[dependent] {
+				continue
+			}
+			found[dependent] = true
+			queue = append(queue, dependent)
+			tables = append(tables, dependent)
+		}
+	}
+	for i, j := 0, len(tables)-1; i < j; i, j = i+1, j-1 {
+		tables[i], tables[j] = tables[j], tables[i]
+	}
+
+	// Tables are found breadth first, so the ones farther from the tables
+	// with fixtures come first in the reverse order. Like in
+	// sortByDependencies, each step then takes the first table in that
+	// order not referenced by the tables left, so referencing tables are
+	// cleaned first. Tables in a cycle are taken in that order.
+	var (
+		sorted = make([]string, 0, len(tables))
+		done   = make(map[string]bool, len(tables))
+	)
+	for len(sorted) < len(tables) {
+		next := ""
+		for _, table := range tables {
+			if done[table] {
+				continue
+			}
+			if next == "" {
+				next = table
+			}
+			if !isReferencedByAny(table, tables, references, done) {
+				next = table
+				break
+			}
+		}
+		done[next] = true
+		sorted = append(sorted, names[next])
+	}
+	return sorted, nil
+}
+
+// isReferencedByAny reports whether a table is referenced by any of the
+// tables not done yet.
+func isReferencedByAny(table string, tables []string, references map[string][]string, done map[string]bool) bool {
+	for _, other := range tables {
+		if !done[other] && containsString(references[other], table) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanDependentTables deletes the rows of the tables without fixtures
+// referencing the tables of the files. See CascadeCleanup.
+func (l *Loader) cleanDependentTables(tx *sql.Tx, files []*fixtureFile, result *LoadResult) error {
+	tables, err := l.dependentTables(tx, files)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, err := l.exec(tx, fmt.Sprintf("DELETE FROM %s", l.helper.quoteKeyword(table))); err != nil {
+			return fmt.Errorf(`testfixtures: could not clean table "%s" referencing tables with fixtures: %w`, table, err)
+		}
+	}
+	result.CascadedTables = tables
+	return nil
+}
+
+// unquotedTableName removes the quotes of each part of a table name, as
+// listed by the foreign keys, so it can be quoted again with quoteKeyword.
+func unquotedTableName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(part, "\"`[]")
+	}
+	return strings.Join(parts, ".")
+}
+
+// cleanDependentTablesInTransaction cleans the tables referencing the tables
+// of the files in their own transaction, with PerTableTransaction.
+func (l *Loader) cleanDependentTablesInTransaction(ctx context.Context, conn *sql.Conn, files []*fixtureFile, result *LoadResult) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := l.cleanDependentTables(tx, files, result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
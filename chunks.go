@@ -0,0 +1,137 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"time"
+)
+
+// loadInChunks loads the fixtures for helpers limiting the number of
+// mutations per transaction, committing the inserts every time the limit
+// would be reached. The tables are cleaned first, in the reverse order, in
+// their own transaction. See mutationLimiter.
+func (l *Loader) loadInChunks(files []*fixtureFile, result *LoadResult, progress *progressTracker) (err error) {
+	c := &chunk{
+		db:    l.db,
+		limit: l.helper.(mutationLimiter).maxMutations(),
+	}
+	defer c.rollback()
+	defer func() {
+		if err != nil && len(c.committedTables) > 0 {
+			err = &PartialLoadError{Err: err, CommittedTables: c.committedTables}
+		}
+	}()
+
+	tx, _, err := c.next("", 0)
+	if err != nil {
+		return err
+	}
+	if err := execStatements(tx, "before load", l.beforeLoadSQL); err != nil {
+		return err
+	}
+	if !l.upsert {
+		cleanFiles := firstFilePerTable(files)
+		for i := len(cleanFiles) - 1; i >= 0; i-- {
+			if err := l.deleteFile(tx, cleanFiles[i]); err != nil {
+				return err
+			}
+			progress.cleaned(cleanFiles[i].fileNameWithoutExtension())
+		}
+	}
+	if err := c.commit(); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		start := time.Now()
+		for j, i := range file.insertSQLs {
+			tx, stmts, err := c.next(tableName, len(i.columns))
+			if err != nil {
+				return err
+			}
+			if err := l.insert(tx, stmts, file.insertSQLs, j); err != nil {
+				return l.insertError(tx, file, j, err)
+			}
+			progress.rowInserted(tableName)
+		}
+		file.insertDuration = time.Since(start)
+		progress.tableInserted(tableName)
+		result.fileLoaded(file)
+	}
+
+	tx, _, err = c.next("", 0)
+	if err != nil {
+		return err
+	}
+	if err := execStatements(tx, "after load", l.afterLoadSQL); err != nil {
+		return err
+	}
+	return c.commit()
+}
+
+// chunk is the current transaction of loadInChunks.
+type chunk struct {
+	db    dbHandle
+	limit int
+
+	tx        *sql.Tx
+	stmts     *insertStatements
+	mutations int
+
+	// tables are the tables inserted on in the current transaction, which
+	// are added to committedTables on commit.
+	tables          []string
+	committedTables []string
+}
+
+// next returns the transaction to use for the given number of mutations on
+// a table, committing the current one first if it would exceed the limit.
+func (c *chunk) next(tableName string, mutations int) (*sql.Tx, *insertStatements, error) {
+	if c.tx != nil && c.mutations > 0 && c.mutations+mutations > c.limit {
+		if err := c.commit(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.tx == nil {
+		tx, err := c.db.Begin()
+		if err != nil {
+			return nil, nil, err
+		}
+		c.tx = tx
+		c.stmts = &insertStatements{tx: tx}
+	}
+	c.mutations += mutations
+	if tableName != "" && !containsString(c.tables, tableName) {
+		c.tables = append(c.tables, tableName)
+	}
+	return c.tx, c.stmts, nil
+}
+
+func (c *chunk) commit() error {
+	if c.tx == nil {
+		return nil
+	}
+	if err := c.stmts.close(); err != nil {
+		return err
+	}
+	err := c.tx.Commit()
+	c.tx, c.stmts, c.mutations = nil, nil, 0
+	if err != nil {
+		return err
+	}
+	for _, tableName := range c.tables {
+		if !containsString(c.committedTables, tableName) {
+			c.committedTables = append(c.committedTables, tableName)
+		}
+	}
+	c.tables = nil
+	return nil
+}
+
+func (c *chunk) rollback() {
+	if c.tx == nil {
+		return
+	}
+	_ = c.stmts.close()
+	_ = c.tx.Rollback()
+}
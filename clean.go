@@ -0,0 +1,96 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Clean deletes the rows of the tables with fixtures, like Load does before
+// inserting the records, but without inserting them, for tests needing
+// empty tables. Referential integrity is disabled like when loading, and
+// DeleteWhere, NullifyBeforeDelete, CascadeCleanup, Schema and TargetSchemas
+// are honored. The next Load fills the tables again.
+//
+// Use Load, which cleans the tables first, to reset them to the fixtures,
+// and Reload to read the fixture files again.
+func (l *Loader) Clean() error {
+	if !l.skipTestDatabaseCheck {
+		if err := l.EnsureTestDatabase(); err != nil {
+			return err
+		}
+	}
+
+	if len(l.schemas()) == 0 {
+		return l.cleanFiles(l.fixturesFiles)
+	}
+	for _, schema := range l.schemas() {
+		files, err := l.schemaFiles(schema, l.fixturesFiles)
+		if err == nil {
+			err = l.cleanFiles(files)
+		}
+		if err != nil {
+			return fmt.Errorf(`testfixtures: could not clean tables in schema "%s": %w`, schema, err)
+		}
+	}
+	return nil
+}
+
+// cleanFiles deletes the rows of the tables of the files in a transaction.
+func (l *Loader) cleanFiles(files []*fixtureFile) error {
+	files = l.orderFiles(files)
+	if l.dependencyOrder {
+		var err error
+		if files, err = l.sortByDependencies(files); err != nil {
+			return err
+		}
+	}
+
+	cleanFn := func(tx *sql.Tx) error {
+		if l.cascadeCleanup {
+			if err := l.cleanDependentTables(tx, files, &LoadResult{}); err != nil {
+				return err
+			}
+		}
+		// Like when loading, tables are cleaned in the reverse order when
+		// referential integrity is kept.
+		cleanFiles := firstFilePerTable(files)
+		for i := range cleanFiles {
+			file := cleanFiles[i]
+			if l.inDependencyOrder() {
+				file = cleanFiles[len(cleanFiles)-1-i]
+			}
+			if err := l.deleteFile(tx, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if l.inDependencyOrder() {
+		err = l.cleanInTransaction(cleanFn)
+	} else {
+		err = l.helper.disableReferentialIntegrity(l.db, cleanFn)
+	}
+	if err != nil {
+		return err
+	}
+
+	for tableName := range filesByTable(files) {
+		delete(l.loadedTables, tableName)
+	}
+	return nil
+}
+
+func (l *Loader) cleanInTransaction(cleanFn func(*sql.Tx) error) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := cleanFn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
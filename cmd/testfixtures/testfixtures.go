@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	_ "github.com/denisenkom/go-mssqldb"
 	_ "github.com/go-sql-driver/mysql"
@@ -45,8 +46,28 @@ func main() {
 	pflag.Int64Var(&resetSequencesTo, "reset-sequences-to", 0, "sets the number sequences will be reset after loading fixtures (PostgreSQL and MySQL/MariaDB only, defaults to 10000)")
 	pflag.BoolVar(&skipTestDatabaseCheck, "dangerous-no-test-database-check", false, `skips check for "test" in database name (use with caution)`)
 	pflag.BoolVar(&dumpFlag, "dump", false, "dumping fixtures from the database into a directory")
+
+	// Aliases, matching the "dump" and "load" commands usage.
+	pflag.StringVar(&dialect, "driver", "", "same as --dialect")
+	pflag.StringVar(&connString, "dsn", "", "same as --conn")
+	pflag.StringSliceVar(&files, "tables", nil, "same as --files, for dump")
+	pflag.BoolVar(&skipTestDatabaseCheck, "dangerous-skip-db-check", false, "same as --dangerous-no-test-database-check")
+
+	pflag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: testfixtures [load|dump] [flags]\n\n")
+		pflag.PrintDefaults()
+	}
 	pflag.Parse()
 
+	switch args := pflag.Args(); {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "load":
+	case len(args) == 1 && args[0] == "dump":
+		dumpFlag = true
+	default:
+		log.Fatalf(`testfixtures: unknown command "%s", expected "load" or "dump"`, strings.Join(args, " "))
+	}
+
 	if versionFlag {
 		log.Printf("testfixtures version: %s", version)
 		return
@@ -132,6 +153,7 @@ func main() {
 	if skipTestDatabaseCheck {
 		options = append(options, testfixtures.DangerousSkipTestDatabaseCheck())
 	}
+	options = append(options, testfixtures.Progress(logProgress))
 
 	loader, err := testfixtures.New(options...)
 	if err != nil {
@@ -143,6 +165,17 @@ func main() {
 	log.Printf("testfixtures: fixtures loaded successfully")
 }
 
+// logProgress prints the tables as they are cleaned and filled, with the
+// number of records inserted so far on all tables.
+func logProgress(event testfixtures.ProgressEvent) {
+	switch event.Phase {
+	case testfixtures.ProgressCleanup:
+		log.Printf("testfixtures: cleaned table %s", event.Table)
+	case testfixtures.ProgressInsert:
+		log.Printf("testfixtures: filled table %s, %d/%d records overall", event.Table, event.RowsDone, event.TotalRows)
+	}
+}
+
 func getDialect(dialect string) (string, error) {
 	switch dialect {
 	case "postgres", "postgresql", "timescaledb":
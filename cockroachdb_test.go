@@ -3,7 +3,10 @@
 package testfixtures
 
 import (
+	"database/sql"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
@@ -22,3 +25,83 @@ func TestCockroachDB(t *testing.T) {
 		)
 	}
 }
+
+func TestCockroachDBWithGeneratedColumns(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("CRDB_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		DROP TABLE IF EXISTS products;
+		CREATE TABLE products (
+			id INT PRIMARY KEY,
+			price INT NOT NULL,
+			total INT AS (price * 2) STORED
+		);
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE products"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	// The query of the PostgreSQL helper may not be supported, in which
+	// case the values are inserted and dumped as they are.
+	columns, err := (&postgreSQL{}).generatedColumns(db)
+	if err != nil {
+		t.Logf("the generated columns can't be listed: %v", err)
+	}
+	listed := err == nil && columns.computed["products"]["total"]
+
+	record := "- id: 1\n  price: 10\n"
+	if listed {
+		// The generated total is left out.
+		record += "  total: 0\n"
+	}
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "products.yml"), []byte(record), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		DangerousSkipTestDatabaseCheck(),
+		UseDropConstraint(),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("postgres"),
+		DumpDirectory(dir),
+		DumpTables("products"),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "products.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "- id: 1\n  price: 10\n  total: 20\n"
+	if listed {
+		expected = "- id: 1\n  price: 10\n"
+	}
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
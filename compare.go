@@ -0,0 +1,189 @@
+package testfixtures
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// CompareTable compares the rows of a table with its fixtures, to catch
+// writes by code expected to only read from the database. It returns a
+// diff, one line per difference, which is empty if the table matches:
+// records missing from the table start with "-", and rows not in the
+// fixtures with "+".
+//
+// Only the columns of the fixtures are compared, so RAW= values and columns
+// filled by defaults are ignored. Values are compared by their text, with
+// times in UTC, booleans as 1 and 0 and binary values in hexadecimal, like
+// in dumps. Rows are matched by primary key (see PrimaryKey) when all the
+// records have it, and regardless of their order otherwise.
+func (l *Loader) CompareTable(table string) (string, error) {
+	var records []map[string]interface{}
+	for _, file := range l.fixturesFiles {
+		if file.fileNameWithoutExtension() != table {
+			continue
+		}
+		for _, i := range file.insertSQLs {
+			records = append(records, i.record())
+		}
+	}
+
+	queriedTable := table
+	if l.schema != "" && !strings.Contains(table, ".") {
+		queriedTable = l.schema + "." + table
+	}
+	rows, err := l.db.Query(fmt.Sprintf("SELECT * FROM %s", l.helper.quoteKeyword(queriedTable)))
+	if err != nil {
+		return "", fmt.Errorf(`testfixtures: could not query rows of table "%s": %w`, table, err)
+	}
+	defer rows.Close()
+	existing, err := scanRows(rows, math.MaxInt32)
+	if err != nil {
+		return "", fmt.Errorf(`testfixtures: could not query rows of table "%s": %w`, table, err)
+	}
+
+	primaryKey := l.primaryKey(table)
+	for _, record := range records {
+		if _, ok := primaryKeyValue(record, primaryKey); !ok {
+			primaryKey = nil
+			break
+		}
+	}
+	var diff []string
+	if primaryKey != nil {
+		diff = compareRowsByKey(records, existing, primaryKey)
+	} else {
+		diff = compareRows(records, existing)
+	}
+	return strings.Join(diff, "\n"), nil
+}
+
+// AssertTableMatchesFixture fails the test if the rows of a table don't match
+// its fixtures, showing the diff returned by CompareTable.
+func (l *Loader) AssertTableMatchesFixture(t testing.TB, table string) {
+	t.Helper()
+
+	diff, err := l.CompareTable(table)
+	if err != nil {
+		t.Errorf("%v", err)
+		return
+	}
+	if diff != "" {
+		t.Errorf(`table "%s" does not match its fixtures:%s%s`, table, "\n", diff)
+	}
+}
+
+// compareRowsByKey compares the records and the rows with the same primary
+// key, column by column.
+func compareRowsByKey(records, rows []map[string]interface{}, primaryKey []string) []string {
+	byKey := make(map[string]map[string]interface{}, len(rows))
+	for _, row := range rows {
+		key, _ := primaryKeyValue(normalizedRow(row), primaryKey)
+		byKey[key] = row
+	}
+
+	var diff []string
+	for _, record := range records {
+		key, _ := primaryKeyValue(normalizedRow(record), primaryKey)
+		row, ok := byKey[key]
+		if !ok {
+			diff = append(diff, "- "+formatRow(record, nil))
+			continue
+		}
+		delete(byKey, key)
+
+		for _, column := range sortedColumns(record) {
+			expected, actual := normalizeValue(record[column]), normalizeValue(row[column])
+			if expected != actual {
+				diff = append(diff, fmt.Sprintf("  %s: %s: - %s + %s", key, column, expected, actual))
+			}
+		}
+	}
+	for _, row := range rows {
+		key, _ := primaryKeyValue(normalizedRow(row), primaryKey)
+		if _, ok := byKey[key]; ok {
+			diff = append(diff, "+ "+formatRow(row, nil))
+		}
+	}
+	return diff
+}
+
+// compareRows matches each record with a row having the same values in the
+// columns of the record.
+func compareRows(records, rows []map[string]interface{}) []string {
+	var (
+		diff    []string
+		matched = make([]bool, len(rows))
+	)
+	for _, record := range records {
+		columns := sortedColumns(record)
+		expected := formatRow(record, columns)
+		found := false
+		for j, row := range rows {
+			if !matched[j] && formatRow(row, columns) == expected {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, "- "+expected)
+		}
+	}
+	for j, row := range rows {
+		if !matched[j] {
+			diff = append(diff, "+ "+formatRow(row, nil))
+		}
+	}
+	return diff
+}
+
+// formatRow formats the given columns of a row, or all of them if nil.
+func formatRow(row map[string]interface{}, columns []string) string {
+	if columns == nil {
+		columns = sortedColumns(row)
+	}
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		values[i] = column + ": " + normalizeValue(row[column])
+	}
+	return "{" + strings.Join(values, ", ") + "}"
+}
+
+func sortedColumns(row map[string]interface{}) []string {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func normalizedRow(row map[string]interface{}) map[string]interface{} {
+	normalized := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		normalized[column] = normalizeValue(value)
+	}
+	return normalized
+}
+
+// normalizeValue returns the text of a value compared by CompareTable.
+func normalizeValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return v.UTC().Format("2006-01-02 15:04:05.999999999")
+	case []byte:
+		return fmt.Sprint(convertValue(v))
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,181 @@
+package testfixtures
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// foreignKey is a foreign key from a table to another, or to itself.
+type foreignKey struct {
+	table           string
+	referencedTable string
+}
+
+// Concurrency makes Loader load up to n tables at the same time, each one in
+// its own transaction, when it's safe to do so: none of the tables with
+// fixtures has or is referenced by a foreign key, and no BeforeLoadSQL or
+// AfterLoadSQL statements were given, as they need a single transaction.
+// Otherwise, the tables are loaded one after the other as usual.
+//
+// Like with PerTableTransaction, the sequences are reset once all the tables
+// are loaded. If an error happens after some tables were committed, a
+// *PartialLoadError is returned.
+//
+// The functions given to Logger and Progress may be called from other
+// goroutines than the one running Load, though never at the same time for
+// Progress. Only supported on PostgreSQL, MySQL, SQLite and SQL Server.
+func Concurrency(n int) func(*Loader) error {
+	return func(l *Loader) error {
+		if n < 1 {
+			return fmt.Errorf("testfixtures: Concurrency must be positive, got %d", n)
+		}
+		l.concurrency = n
+		return nil
+	}
+}
+
+// canLoadConcurrently reports whether the tables of the files are
+// independent, so they can be loaded concurrently. See Concurrency.
+func (l *Loader) canLoadConcurrently(files []*fixtureFile) (bool, error) {
+	lister, ok := l.helper.(foreignKeyLister)
+	if _, isSessionHelper := l.helper.(sessionHelper); !isSessionHelper {
+		ok = false
+	}
+	if l.concurrency < 2 || !ok || l.continueOnError || len(l.beforeLoadSQL) > 0 || len(l.afterLoadSQL) > 0 {
+		return false, nil
+	}
+
+	keys, err := lister.foreignKeys(l.db)
+	if err != nil {
+		return false, err
+	}
+	tables := make(map[string]bool, len(files))
+	for _, file := range files {
+		tables[unqualifiedTableName(file.fileNameWithoutExtension())] = true
+	}
+	for _, key := range keys {
+		if tables[unqualifiedTableName(key.table)] || tables[unqualifiedTableName(key.referencedTable)] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// unqualifiedTableName returns the table name without schema and quotes, in
+// lower case, to compare it loosely: tables in different schemas may be
+// considered the same, which only prevents them from being loaded
+// concurrently.
+func unqualifiedTableName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.Trim(name, "\"`[]"))
+}
+
+// loadConcurrently loads the tables of the files concurrently, each one in
+// its own transaction. See Concurrency.
+func (l *Loader) loadConcurrently(files []*fixtureFile, result *LoadResult, progress *progressTracker) error {
+	conn, err := l.db.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	h := l.helper.(sessionHelper)
+	return h.disableReferentialIntegrityForSession(connQueryable{conn}, func() error {
+		return l.loadTablesConcurrently(files, result, progress)
+	})
+}
+
+func (l *Loader) loadTablesConcurrently(files []*fixtureFile, result *LoadResult, progress *progressTracker) error {
+	var (
+		tables     []string
+		tableFiles = filesByTable(files)
+		modified   = make(map[string]bool, len(tableFiles))
+	)
+	// The helpers are not safe for concurrent use, so the tables to load are
+	// checked first.
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		if _, ok := modified[tableName]; ok {
+			continue
+		}
+		isModified, err := l.isTableModified(l.db, tableName, tableFiles[tableName])
+		if err != nil {
+			return err
+		}
+		modified[tableName] = isModified
+		tables = append(tables, tableName)
+	}
+
+	var (
+		mu              sync.Mutex
+		wg              sync.WaitGroup
+		firstErr        error
+		committedTables []string
+		sem             = make(chan struct{}, l.concurrency)
+	)
+	for _, tableName := range tables {
+		if !modified[tableName] {
+			for _, file := range tableFiles[tableName] {
+				progress.skipped(file)
+				result.fileSkipped(file)
+			}
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(tableName string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := l.loadTableInTransaction(tableFiles[tableName], progress)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			committedTables = append(committedTables, tableName)
+			for _, file := range tableFiles[tableName] {
+				result.fileLoaded(file)
+			}
+		}(tableName)
+	}
+	wg.Wait()
+
+	if firstErr != nil && len(committedTables) > 0 {
+		return &PartialLoadError{Err: firstErr, CommittedTables: committedTables}
+	}
+	return firstErr
+}
+
+// loadTableInTransaction cleans and fills a table in its own transaction.
+func (l *Loader) loadTableInTransaction(files []*fixtureFile, progress *progressTracker) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if !l.upsert {
+		if err := l.deleteFile(tx, files[0]); err != nil {
+			return err
+		}
+		progress.cleaned(files[0].fileNameWithoutExtension())
+	}
+	for _, file := range files {
+		if err := l.insertFile(tx, file, progress); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
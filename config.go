@@ -0,0 +1,131 @@
+package testfixtures
+
+import (
+	"text/template"
+	"time"
+)
+
+// Config holds the most common settings of Loader, as an alternative to the
+// functional options of New. The zero value of a field leaves the default
+// of the matching option, and the other options can be given in Options:
+//
+//	fixtures, err := testfixtures.NewFromConfig(testfixtures.Config{
+//	        Database:    db,
+//	        Dialect:     "postgres",
+//	        Directories: []string{"testdata/fixtures"},
+//	})
+type Config struct {
+	// Database is the database to load the fixtures in. Required. See
+	// Database.
+	Database DB
+	// Dialect is the dialect of the database. Required. See Dialect.
+	Dialect string
+
+	// Directories are the directories of the fixture files. See Directory.
+	Directories []string
+	// Files are the fixture files. See Files.
+	Files []string
+	// Paths are fixture files and directories. See Paths.
+	Paths []string
+
+	// Template makes the fixture files be parsed as templates. See
+	// Template.
+	Template bool
+	// TemplateData is given to the templates. See TemplateData.
+	TemplateData interface{}
+	// TemplateFuncs are added to the functions of the templates. See
+	// TemplateFuncs.
+	TemplateFuncs template.FuncMap
+
+	// Location is the location of the dates without one. See Location.
+	Location *time.Location
+	// DatabaseName is the name of the database, when it can't be queried.
+	// See DatabaseName.
+	DatabaseName string
+	// DangerousSkipTestDatabaseCheck skips the check of the name of the
+	// database. See DangerousSkipTestDatabaseCheck.
+	DangerousSkipTestDatabaseCheck bool
+
+	// UseAlterConstraint makes the foreign keys deferrable while loading on
+	// PostgreSQL. See UseAlterConstraint.
+	UseAlterConstraint bool
+	// UseDropConstraint drops the foreign keys while loading on PostgreSQL.
+	// See UseDropConstraint.
+	UseDropConstraint bool
+	// SkipResetSequences keeps the sequences as they are on PostgreSQL. See
+	// SkipResetSequences.
+	SkipResetSequences bool
+	// ResetSequencesTo is the value the sequences are reset to, when not
+	// zero. See ResetSequencesTo.
+	ResetSequencesTo int64
+	// PerTableTransaction loads each fixture file in its own transaction.
+	// See PerTableTransaction.
+	PerTableTransaction bool
+
+	// Options are other options of New. They are applied after the
+	// settings of the fields, and before the fixture files are read.
+	Options []func(*Loader) error
+}
+
+// NewFromConfig instantiates a new Loader from a Config, like New does from
+// the matching options.
+func NewFromConfig(config Config) (*Loader, error) {
+	return New(config.options()...)
+}
+
+// options returns the options of New matching the config. The fixture files
+// are read last, as their options depend on the other ones, like Template.
+func (c Config) options() []func(*Loader) error {
+	var options []func(*Loader) error
+	if c.Database != nil {
+		options = append(options, Database(c.Database))
+	}
+	if c.Dialect != "" {
+		options = append(options, Dialect(c.Dialect))
+	}
+	if c.Template {
+		options = append(options, Template())
+	}
+	if c.TemplateData != nil {
+		options = append(options, TemplateData(c.TemplateData))
+	}
+	if c.TemplateFuncs != nil {
+		options = append(options, TemplateFuncs(c.TemplateFuncs))
+	}
+	if c.Location != nil {
+		options = append(options, Location(c.Location))
+	}
+	if c.DatabaseName != "" {
+		options = append(options, DatabaseName(c.DatabaseName))
+	}
+	if c.DangerousSkipTestDatabaseCheck {
+		options = append(options, DangerousSkipTestDatabaseCheck())
+	}
+	if c.UseAlterConstraint {
+		options = append(options, UseAlterConstraint())
+	}
+	if c.UseDropConstraint {
+		options = append(options, UseDropConstraint())
+	}
+	if c.SkipResetSequences {
+		options = append(options, SkipResetSequences())
+	}
+	if c.ResetSequencesTo != 0 {
+		options = append(options, ResetSequencesTo(c.ResetSequencesTo))
+	}
+	if c.PerTableTransaction {
+		options = append(options, PerTableTransaction())
+	}
+	options = append(options, c.Options...)
+
+	for _, dir := range c.Directories {
+		options = append(options, Directory(dir))
+	}
+	if len(c.Files) > 0 {
+		options = append(options, Files(c.Files...))
+	}
+	if len(c.Paths) > 0 {
+		options = append(options, Paths(c.Paths...))
+	}
+	return options
+}
@@ -0,0 +1,97 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// savepointName is the name of the savepoint created before inserting the
+// records of each file with ContinueOnError.
+const savepointName = "testfixtures_file"
+
+// ContinueOnError makes Loader go on with the other files when the records
+// of a file fail to be inserted, instead of rolling back the whole load. The
+// records of each file are inserted after a savepoint, which is rolled back
+// to when one of them fails, so none of them are kept. Once all the files
+// are loaded, the transaction is committed and a *FileLoadErrors with the
+// errors of all the failed files is returned.
+//
+// It can't be used together with PerTableTransaction, and disables
+// Concurrency.
+func ContinueOnError(continueOnError bool) func(*Loader) error {
+	return func(l *Loader) error {
+		l.continueOnError = continueOnError
+		return nil
+	}
+}
+
+// FileLoadErrors is returned by Load when the records of some files failed to
+// be inserted with ContinueOnError. The records of the other files are
+// committed.
+type FileLoadErrors struct {
+	// Files are the files that failed to load, in the order they were loaded.
+	Files []string
+	// Errors are the errors of each file.
+	Errors []error
+}
+
+func (e *FileLoadErrors) Error() string {
+	errs := make([]string, len(e.Files))
+	for i, file := range e.Files {
+		errs[i] = fmt.Sprintf("%s: %v", file, e.Errors[i])
+	}
+	return fmt.Sprintf("testfixtures: could not load fixture files:\n%s", strings.Join(errs, "\n"))
+}
+
+// Unwrap returns the error of the first file that failed to load.
+func (e *FileLoadErrors) Unwrap() error {
+	return e.Errors[0]
+}
+
+// savepointSQL returns the statements to create a savepoint, roll back to it
+// and release it. The release statement is empty if not supported.
+func (l *Loader) savepointSQL(name string) (create, rollback, release string) {
+	if h, ok := l.helper.(savepointer); ok {
+		return h.savepointSQL(name)
+	}
+	return "SAVEPOINT " + name, "ROLLBACK TO SAVEPOINT " + name, "RELEASE SAVEPOINT " + name
+}
+
+// insertFileInSavepoint inserts the records of a file like insertFile, but
+// rolls back to a savepoint if they fail to be inserted, returning the error
+// in fileErr. Other errors, which make the transaction unusable, are
+// returned in err.
+func (l *Loader) insertFileInSavepoint(tx *sql.Tx, file *fixtureFile, progress *progressTracker) (fileErr, err error) {
+	create, rollback, release := l.savepointSQL(savepointName)
+	if _, err = tx.Exec(create); err != nil {
+		return nil, err
+	}
+	if fileErr = l.insertFile(tx, file, progress); fileErr != nil {
+		if _, err = tx.Exec(rollback); err != nil {
+			return nil, fmt.Errorf("testfixtures: could not roll back to savepoint after error (%v): %w", fileErr, err)
+		}
+		return fileErr, nil
+	}
+	if release != "" {
+		if _, err = tx.Exec(release); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// fileFailed records the error of a file that failed to load with
+// ContinueOnError.
+func (r *LoadResult) fileFailed(file *fixtureFile, err error) {
+	if r.fileErrors == nil {
+		r.fileErrors = &FileLoadErrors{}
+	}
+	path := file.path
+	if path == "" {
+		path = file.fileName
+	}
+	r.fileErrors.Files = append(r.fileErrors.Files, path)
+	r.fileErrors.Errors = append(r.fileErrors.Errors, err)
+	r.failedTables = append(r.failedTables, file.fileNameWithoutExtension())
+}
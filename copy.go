@@ -0,0 +1,130 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UseCopyFrom makes Loader insert the records with "COPY ... FROM STDIN"
+// instead of one "INSERT" per record, which is much faster for big fixture
+// files. Only supported on PostgreSQL, when the database is opened with the
+// github.com/lib/pq driver.
+//
+// The records are inserted as usual with other drivers, or when they can't
+// be copied: with RAW= values, ColumnCast, ColumnType, Upsert or
+// CaptureInsertedIDs. Errors are only reported once all the records of a file
+// are copied, so they don't tell which record failed.
+func UseCopyFrom(useCopyFrom bool) func(*Loader) error {
+	return func(l *Loader) error {
+		l.copyFrom = useCopyFrom
+		return nil
+	}
+}
+
+// pqDriverType is the type of the github.com/lib/pq driver, the only one
+// supporting "COPY" through database/sql, which isn't imported to not make
+// it a dependency.
+const pqDriverType = "*pq.Driver"
+
+// canCopy reports whether the records of the file can be inserted with
+// "COPY": the driver supports it and all the values are bound as is, which
+// is the case when the inserts are plain "INSERT" statements.
+func (l *Loader) canCopy(file *fixtureFile) bool {
+	if !supportsCopy(l.db) {
+		return false
+	}
+	for _, i := range file.insertSQLs {
+		if i.sql != l.plainInsertSQL(file.fileNameWithoutExtension(), i.columns) {
+			return false
+		}
+	}
+	return true
+}
+
+// supportsCopy reports whether the driver of the database is
+// github.com/lib/pq.
+func supportsCopy(h dbHandle) bool {
+	var db interface{} = h
+	switch h := h.(type) {
+	case dbAdapter:
+		db = h.db
+	case contextHandle:
+		db = h.db
+	}
+	d, ok := db.(interface{ Driver() driver.Driver })
+	return ok && reflect.TypeOf(d.Driver()).String() == pqDriverType
+}
+
+// plainInsertSQL returns the statement inserting the columns of a record
+// without any RAW= value, cast or clause.
+func (l *Loader) plainInsertSQL(tableName string, columns []string) string {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = l.placeholder(i + 1)
+	}
+	return fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		l.helper.quoteKeyword(tableName),
+		strings.Join(l.quoteColumns(columns), ", "),
+		strings.Join(placeholders, ", "),
+	)
+}
+
+func (l *Loader) quoteColumns(columns []string) []string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = l.helper.quoteKeyword(column)
+	}
+	return quoted
+}
+
+// copyFile inserts the records of a file with "COPY", starting a new one
+// every time the columns change from a record to the next one.
+func (l *Loader) copyFile(tx *sql.Tx, file *fixtureFile, progress *progressTracker) (err error) {
+	var (
+		tableName = file.fileNameWithoutExtension()
+		query     string
+		stmt      *sql.Stmt
+	)
+	flush := func() error {
+		if stmt == nil {
+			return nil
+		}
+		defer stmt.Close()
+		_, err := stmt.Exec()
+		stmt = nil
+		return err
+	}
+	defer func() {
+		if stmt != nil {
+			_ = stmt.Close()
+		}
+		if err != nil {
+			err = fmt.Errorf("testfixtures: could not copy the records of %s: %w", file.fileName, err)
+		}
+	}()
+
+	for _, i := range file.insertSQLs {
+		copySQL := l.helper.(copier).copySQL(tableName, l.quoteColumns(i.columns))
+		if copySQL != query {
+			if err := flush(); err != nil {
+				return err
+			}
+			if stmt, err = tx.Prepare(copySQL); err != nil {
+				return err
+			}
+			query = copySQL
+		}
+		if l.logger != nil {
+			l.logger(copySQL, i.params)
+		}
+		if _, err := stmt.Exec(i.params...); err != nil {
+			return err
+		}
+		progress.rowInserted(tableName)
+	}
+	return flush()
+}
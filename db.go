@@ -0,0 +1,127 @@
+package testfixtures
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// DB is the database handle used by Loader and Dumper. It's implemented by
+// *sql.DB and by types that wrap or embed it, like *sqlx.DB or connection
+// pool wrappers.
+type DB interface {
+	Queryer
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// Queryer runs queries. It's implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Queryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// connector is implemented by a DB able to reserve a single database
+// session, which is required by PerTableTransaction.
+type connector interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+var errConnNotSupported = errors.New("testfixtures: the database does not support reserving a connection (missing Conn method)")
+
+// dbHandle is what Loader and the helpers use internally. A *sql.DB is used
+// directly, while other DB implementations are wrapped by dbAdapter.
+type dbHandle interface {
+	queryable
+	Begin() (*sql.Tx, error)
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+func newDBHandle(db DB) dbHandle {
+	switch db := db.(type) {
+	case nil:
+		return nil
+	case *sql.DB:
+		if db == nil {
+			return nil
+		}
+		return db
+	default:
+		return dbAdapter{db}
+	}
+}
+
+// dbAdapter makes any DB satisfy dbHandle.
+type dbAdapter struct {
+	db DB
+}
+
+func (a dbAdapter) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return a.db.ExecContext(context.Background(), query, args...)
+}
+
+func (a dbAdapter) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return a.db.QueryContext(context.Background(), query, args...)
+}
+
+func (a dbAdapter) QueryRow(query string, args ...interface{}) *sql.Row {
+	return a.db.QueryRowContext(context.Background(), query, args...)
+}
+
+func (a dbAdapter) Begin() (*sql.Tx, error) {
+	return a.db.BeginTx(context.Background(), nil)
+}
+
+func (a dbAdapter) Conn(ctx context.Context) (*sql.Conn, error) {
+	c, ok := a.db.(connector)
+	if !ok {
+		return nil, errConnNotSupported
+	}
+	return c.Conn(ctx)
+}
+
+// contextHandle binds a DB to a context, so that all the queries of Loader
+// and of the helpers, which don't take a context, are cancelled with it. See
+// LoadContext.
+type contextHandle struct {
+	ctx context.Context
+	db  DB
+}
+
+// withContext returns a handle running the queries of h with the context.
+func withContext(ctx context.Context, h dbHandle) dbHandle {
+	switch h := h.(type) {
+	case *sql.DB:
+		return contextHandle{ctx: ctx, db: h}
+	case dbAdapter:
+		return contextHandle{ctx: ctx, db: h.db}
+	case contextHandle:
+		return contextHandle{ctx: ctx, db: h.db}
+	default:
+		return h
+	}
+}
+
+func (c contextHandle) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.db.ExecContext(c.ctx, query, args...)
+}
+
+func (c contextHandle) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.db.QueryContext(c.ctx, query, args...)
+}
+
+func (c contextHandle) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.db.QueryRowContext(c.ctx, query, args...)
+}
+
+func (c contextHandle) Begin() (*sql.Tx, error) {
+	return c.db.BeginTx(c.ctx, nil)
+}
+
+func (c contextHandle) Conn(context.Context) (*sql.Conn, error) {
+	conn, ok := c.db.(connector)
+	if !ok {
+		return nil, errConnNotSupported
+	}
+	return conn.Conn(c.ctx)
+}
@@ -0,0 +1,192 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxConflictingRows is the maximum number of rows returned by
+// conflictingRows.
+const maxConflictingRows = 5
+
+// isConstraintError reports whether the error returned by the driver looks
+// like a constraint violation. Drivers don't share error types, but all
+// databases mention the constraint (or a duplicate entry) in the message.
+func isConstraintError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "constraint") || strings.Contains(msg, "duplicate")
+}
+
+// unknownColumnRegexps match the errors returned by the drivers when a
+// column doesn't exist, capturing its name.
+var unknownColumnRegexps = []*regexp.Regexp{
+	regexp.MustCompile(`column "([^"]+)" of relation "[^"]*" does not exist`), // PostgreSQL
+	regexp.MustCompile(`Unknown column '([^']+)'`),                            // MySQL
+	regexp.MustCompile(`has no column named (\S+)`),                           // SQLite
+	regexp.MustCompile(`Invalid column name '([^']+)'`),                       // SQL Server
+}
+
+// unknownColumn returns the name of the column mentioned by the error, if
+// it's about a column that doesn't exist.
+func unknownColumn(err error) (string, bool) {
+	for _, re := range unknownColumnRegexps {
+		if m := re.FindStringSubmatch(err.Error()); m != nil {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// unknownColumns returns the columns of the record that failed to be
+// inserted which are not in the table, like after a migration dropped them.
+// The drivers only mention one of them, which is returned if the columns of
+// the table can't be queried.
+func (l *Loader) unknownColumns(table string, recordColumns []string, column string) []string {
+	tableColumns, err := l.tableColumns(table)
+	if err != nil {
+		return []string{column}
+	}
+
+	var unknown []string
+	for _, recordColumn := range recordColumns {
+		if !hasColumn(tableColumns, recordColumn) {
+			unknown = append(unknown, recordColumn)
+		}
+	}
+	if len(unknown) == 0 {
+		return []string{column}
+	}
+	return unknown
+}
+
+// completeUnknownColumns sets all the unknown columns of the insert errors
+// of a failed load, which only name the column mentioned by the driver until
+// then. It must be called once the transactions of the load are over: the
+// failed one may be aborted, and querying the columns besides it would wait
+// for a second connection, which never comes with a single connection pool.
+func (l *Loader) completeUnknownColumns(err error) {
+	var fileErrs *FileLoadErrors
+	if errors.As(err, &fileErrs) {
+		for _, fileErr := range fileErrs.Errors {
+			l.completeUnknownColumns(fileErr)
+		}
+		return
+	}
+
+	var insertErr *InsertError
+	if errors.As(err, &insertErr) && insertErr.recordColumns != nil {
+		insertErr.UnknownColumns = l.unknownColumns(insertErr.Table, insertErr.recordColumns, insertErr.UnknownColumns[0])
+		insertErr.recordColumns = nil
+	}
+}
+
+// tableColumns returns the columns of a table.
+func (l *Loader) tableColumns(table string) ([]string, error) {
+	rows, err := l.db.Query(fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", l.helper.quoteKeyword(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// hasColumn reports whether the column is one of the columns of a table,
+// ignoring the case.
+func hasColumn(tableColumns []string, column string) bool {
+	for _, tableColumn := range tableColumns {
+		if strings.EqualFold(column, tableColumn) {
+			return true
+		}
+	}
+	return false
+}
+
+// abortsTransactionOnError reports whether the database refuses any statement
+// in a transaction after an error, until it's rolled back.
+func (l *Loader) abortsTransactionOnError() bool {
+	_, ok := l.helper.(*postgreSQL)
+	return ok
+}
+
+func (i insertSQL) record() map[string]interface{} {
+	record := make(map[string]interface{}, len(i.columns))
+	for j, column := range i.columns {
+		record[column] = i.params[j]
+	}
+	return record
+}
+
+// conflictingRows returns the rows of the table with the primary key of the
+// record that failed to be inserted, or, if the record doesn't give it, the
+// rows sharing any value with it. It returns nil if they can't be queried.
+func (l *Loader) conflictingRows(q queryable, table string, i insertSQL) []map[string]interface{} {
+	record := i.record()
+	columns, separator := l.primaryKey(table), " AND "
+	for _, column := range columns {
+		if record[column] == nil {
+			columns, separator = i.columns, " OR "
+			break
+		}
+	}
+
+	var (
+		conditions []string
+		params     []interface{}
+	)
+	for _, column := range columns {
+		if record[column] == nil {
+			continue
+		}
+		params = append(params, record[column])
+		conditions = append(conditions, fmt.Sprintf("%s = %s", l.helper.quoteKeyword(column), l.placeholder(len(params))))
+	}
+	if len(conditions) == 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(
+		"SELECT * FROM %s WHERE %s",
+		l.helper.quoteKeyword(table),
+		strings.Join(conditions, separator),
+	)
+	rows, err := q.Query(query, l.bindParams(params)...)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	existing, err := scanRows(rows, maxConflictingRows)
+	if err != nil {
+		return nil
+	}
+	return existing
+}
+
+func scanRows(rows *sql.Rows, limit int) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []map[string]interface{}
+	for len(result) < limit && rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for j := range values {
+			pointers[j] = &values[j]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for j, column := range columns {
+			row[column] = convertValue(values[j])
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
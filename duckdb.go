@@ -0,0 +1,108 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type duckDB struct {
+	baseHelper
+}
+
+func (*duckDB) paramType() int {
+	return paramTypeQuestion
+}
+
+func (*duckDB) databaseName(q queryable) (string, error) {
+	var file sql.NullString
+	err := q.QueryRow("SELECT file FROM pragma_database_list WHERE name = current_database()").Scan(&file)
+	if err != nil {
+		return "", err
+	}
+	if !file.Valid || file.String == "" {
+		return sqliteMemoryDatabaseName, nil
+	}
+	return filepath.Base(file.String), nil
+}
+
+func (*duckDB) tableNames(q queryable) ([]string, error) {
+	const query = `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE'
+		  AND table_schema = current_schema()
+	`
+	return queryStrings(q, query)
+}
+
+// requiresDependencyOrder is an orderedHelper interface implementation.
+// DuckDB always checks foreign keys.
+func (*duckDB) requiresDependencyOrder() {}
+
+// disableReferentialIntegrity only loads the fixtures in a transaction, as
+// DuckDB can't disable foreign keys. See orderedHelper.
+func (*duckDB) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = loadFn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// castPlaceholder is a caster interface implementation.
+func (*duckDB) castPlaceholder(placeholder, castExpr string) string {
+	return placeholder + "::" + castExpr
+}
+
+// typedValue is a columnTyper interface implementation. Lists and objects are
+// converted to DuckDB list and struct literals, like "[1, 2]" and
+// "{'name': 'John'}", which can be cast to list, struct and map types.
+func (*duckDB) typedValue(value interface{}, _ string) (interface{}, error) {
+	return duckDBLiteral(value), nil
+}
+
+func duckDBLiteral(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []interface{}:
+		elements := make([]string, len(v))
+		for i, e := range v {
+			elements[i] = duckDBLiteral(e)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case map[interface{}]interface{}:
+		keys := make([]string, 0, len(v))
+		values := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			key := fmt.Sprint(k)
+			keys = append(keys, key)
+			values[key] = e
+		}
+		sort.Strings(keys)
+		fields := make([]string, len(keys))
+		for i, key := range keys {
+			fields[i] = duckDBQuote(key) + ": " + duckDBLiteral(values[key])
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v)
+	case time.Time:
+		return duckDBQuote(v.Format(time.RFC3339Nano))
+	default:
+		return duckDBQuote(fmt.Sprint(v))
+	}
+}
+
+func duckDBQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -1,11 +1,12 @@
 package testfixtures
 
 import (
-	"database/sql"
+	"bufio"
 	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 	"unicode/utf8"
 
 	"gopkg.in/yaml.v2"
@@ -14,11 +15,19 @@ import (
 // Dumper is resposible for dumping fixtures from the database into a
 // directory.
 type Dumper struct {
-	db     *sql.DB
+	db     dbHandle
 	helper helper
 	dir    string
 
-	tables []string
+	tables         []string
+	maxRows        int
+	maxRowsPerFile int
+	casts          bool
+
+	timeLayout        string
+	fileValuesMinSize int
+	columnDecoders    map[string]map[string]func(interface{}) (interface{}, error)
+	computedColumns   map[string]map[string]bool
 }
 
 // NewDumper creates a new dumper with the given options.
@@ -33,13 +42,17 @@ func NewDumper(options ...func(*Dumper) error) (*Dumper, error) {
 		}
 	}
 
+	if _, ok := d.helper.(castLister); d.casts && !ok {
+		return nil, fmt.Errorf("testfixtures: DumpCasts is only valid for PostgreSQL databases")
+	}
+
 	return d, nil
 }
 
 // DumpDatabase sets the database to be dumped.
-func DumpDatabase(db *sql.DB) func(*Dumper) error {
+func DumpDatabase(db DB) func(*Dumper) error {
 	return func(d *Dumper) error {
-		d.db = db
+		d.db = newDBHandle(db)
 		return nil
 	}
 }
@@ -77,6 +90,62 @@ func DumpTables(tables ...string) func(*Dumper) error {
 	}
 }
 
+// DumpMaxRows limits the number of rows written for each table. The limit is
+// given to the database, with "LIMIT", or "TOP" on SQL Server, so only those
+// rows are read.
+//
+// If not informed or zero, all rows are dumped.
+func DumpMaxRows(maxRows int) func(*Dumper) error {
+	return func(d *Dumper) error {
+		if maxRows < 0 {
+			return fmt.Errorf("testfixtures: DumpMaxRows should not be negative")
+		}
+		d.maxRows = maxRows
+		return nil
+	}
+}
+
+// DumpMaxRowsPerFile splits the records of the tables with more than maxRows
+// rows in many files: "posts.yml", "posts.2.yml", "posts.3.yml", etc. Loader
+// loads all of them in the table, cleaning it once.
+//
+// If not informed or zero, each table is dumped in a single file.
+func DumpMaxRowsPerFile(maxRows int) func(*Dumper) error {
+	return func(d *Dumper) error {
+		if maxRows < 0 {
+			return fmt.Errorf("testfixtures: DumpMaxRowsPerFile should not be negative")
+		}
+		d.maxRowsPerFile = maxRows
+		return nil
+	}
+}
+
+// DumpTimeLayout sets the layout of the dumped times, which are written as
+// strings. It must be one of the layouts understood when loading fixtures.
+//
+// Defaults to time.RFC3339Nano.
+func DumpTimeLayout(layout string) func(*Dumper) error {
+	return func(d *Dumper) error {
+		if layout == "" {
+			return fmt.Errorf("testfixtures: DumpTimeLayout requires a layout")
+		}
+		d.timeLayout = layout
+		return nil
+	}
+}
+
+// DumpCasts makes Dumper write a _casts section in each file, for the columns
+// whose values can't be bound from a string by some drivers, like uuid,
+// jsonb, inet and enum columns. See ColumnCast.
+//
+// Only valid for PostgreSQL. Returns an error otherwise.
+func DumpCasts() func(*Dumper) error {
+	return func(d *Dumper) error {
+		d.casts = true
+		return nil
+	}
+}
+
 // Dump dumps the databases as YAML fixtures.
 func (d *Dumper) Dump() error {
 	tables := d.tables
@@ -88,6 +157,11 @@ func (d *Dumper) Dump() error {
 		}
 	}
 
+	// Generated columns are left out, as they can't be inserted.
+	if columns := listGeneratedColumns(d.helper, d.db); columns != nil {
+		d.computedColumns = columns.computed
+	}
+
 	for _, table := range tables {
 		if err := d.dumpTable(table); err != nil {
 			return err
@@ -97,27 +171,67 @@ func (d *Dumper) Dump() error {
 }
 
 func (d *Dumper) dumpTable(table string) error {
-	query := fmt.Sprintf("SELECT * FROM %s", d.helper.quoteKeyword(table))
-
-	stmt, err := d.db.Prepare(query)
+	rows, err := d.db.Query(d.selectSQL(table))
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
+	defer rows.Close()
 
-	rows, err := stmt.Query()
+	columns, err := rows.Columns()
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-
-	columns, err := rows.Columns()
+	columnTypes, err := rows.ColumnTypes()
 	if err != nil {
 		return err
 	}
 
-	fixtures := make([]yaml.MapSlice, 0, 10)
+	// Rows are encoded and written one at a time, so memory usage doesn't
+	// grow with the size of the table.
+	var (
+		f        *os.File
+		w        *bufio.Writer
+		part     int
+		count    int
+		hasCasts bool
+	)
+	nextFile := func() error {
+		if f != nil {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+		}
+		part++
+		var err error
+		if f, err = os.Create(filepath.Join(d.dir, partFileName(table, part))); err != nil {
+			return err
+		}
+		w = bufio.NewWriter(f)
+		if d.casts {
+			if hasCasts, err = d.writeCasts(w, table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := nextFile(); err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
 	for rows.Next() {
+		if d.maxRows > 0 && count >= d.maxRows {
+			break
+		}
+		if d.maxRowsPerFile > 0 && count > 0 && count%d.maxRowsPerFile == 0 {
+			if err := nextFile(); err != nil {
+				return err
+			}
+		}
+
 		entries := make([]interface{}, len(columns))
 		entryPtrs := make([]interface{}, len(entries))
 		for i := range entries {
@@ -127,32 +241,114 @@ func (d *Dumper) dumpTable(table string) error {
 			return err
 		}
 
-		entryMap := make([]yaml.MapItem, len(entries))
+		entryMap := make(yaml.MapSlice, 0, len(entries))
 		for i, column := range columns {
-			entryMap[i] = yaml.MapItem{
-				Key:   column,
-				Value: convertValue(entries[i]),
+			if d.computedColumns[table][column] {
+				continue
+			}
+			value := entries[i]
+			if decode, ok := d.columnDecoders[table][column]; ok {
+				if value, err = decode(value); err != nil {
+					return fmt.Errorf(`testfixtures: could not decode column "%s" of row %d of table "%s": %w`, column, count+1, table, err)
+				}
+				entryMap = append(entryMap, yaml.MapItem{Key: column, Value: value})
+				continue
+			}
+			// UUIDs are written in their canonical form, in lowercase.
+			if databaseType := columnTypes[i].DatabaseTypeName(); isUUIDType(databaseType) {
+				value = dumpUUID(databaseType, value)
 			}
+			if d.fileValuesMinSize > 0 {
+				if value, err = d.fileValue(table, column, count+1, value); err != nil {
+					return err
+				}
+			}
+			entryMap = append(entryMap, yaml.MapItem{
+				Key:   column,
+				Value: d.dumpValue(value),
+			})
+		}
+
+		// Each record is marshaled as a single item sequence, so the
+		// concatenation of all of them is still a valid YAML sequence.
+		data, err := yaml.Marshal([]yaml.MapSlice{entryMap})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
 		}
-		fixtures = append(fixtures, entryMap)
+		count++
 	}
 	if err = rows.Err(); err != nil {
 		return err
 	}
 
-	filePath := filepath.Join(d.dir, table+".yml")
-	f, err := os.Create(filePath)
-	if err != nil {
+	if count == 0 && !hasCasts {
+		if _, err := w.WriteString("[]\n"); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
 		return err
 	}
-	defer f.Close()
+	return d.removeParts(table, part+1)
+}
 
-	data, err := yaml.Marshal(fixtures)
+// removeParts removes the files of the parts of a table left by a previous
+// dump, from the given part on, so they are not loaded with the new ones.
+func (d *Dumper) removeParts(table string, from int) error {
+	for part := from; ; part++ {
+		err := os.Remove(filepath.Join(d.dir, partFileName(table, part)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// selectSQL returns the query selecting the rows of a table to dump, at
+// most DumpMaxRows of them.
+func (d *Dumper) selectSQL(table string) string {
+	quoted := d.helper.quoteKeyword(table)
+	switch limiter, ok := d.helper.(selectLimiter); {
+	case d.maxRows == 0:
+		return fmt.Sprintf("SELECT * FROM %s", quoted)
+	case ok:
+		return limiter.selectLimitSQL(quoted, d.maxRows)
+	default:
+		return fmt.Sprintf("SELECT * FROM %s LIMIT %d", quoted, d.maxRows)
+	}
+}
+
+// writeCasts writes the _casts section of a table, if it has columns to
+// cast, as the first item of the sequence.
+func (d *Dumper) writeCasts(w *bufio.Writer, table string) (bool, error) {
+	casts, err := d.helper.(castLister).columnCasts(d.db, table)
+	if err != nil || len(casts) == 0 {
+		return false, err
+	}
+	data, err := yaml.Marshal([]yaml.MapSlice{{{Key: castsKey, Value: casts}}})
 	if err != nil {
-		return err
+		return false, err
+	}
+	_, err = w.Write(data)
+	return err == nil, err
+}
+
+// dumpValue converts a value to be dumped. Times are formatted with the
+// layout given with DumpTimeLayout, and NULL values are written as null.
+func (d *Dumper) dumpValue(value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		layout := d.timeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return t.Format(layout)
 	}
-	_, err = f.Write(data)
-	return err
+	return convertValue(value)
 }
 
 func convertValue(value interface{}) interface{} {
@@ -0,0 +1,105 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SkipDuplicateKeysCheck makes New and Reload not check that the records of
+// the fixture files have different primary keys, for schemas where the
+// primary key given with PrimaryKey, or the "id" column by default, is not
+// unique.
+func SkipDuplicateKeysCheck() func(*Loader) error {
+	return func(l *Loader) error {
+		l.skipDuplicateKeysCheck = true
+		return nil
+	}
+}
+
+// DuplicateKey is a pair of records of a table with the same primary key.
+type DuplicateKey struct {
+	// Table is the table of the records.
+	Table string
+	// Key are the values of the primary key columns, separated by commas.
+	Key string
+	// File and Index are the file and the index of the first record in it,
+	// starting at 0.
+	File  string
+	Index int
+	// OtherFile and OtherIndex are the file and the index of the record
+	// with the same key, which may be in the same file.
+	OtherFile  string
+	OtherIndex int
+}
+
+// DuplicateKeyError is returned by New and Reload when records of the
+// fixture files have the same primary key. See SkipDuplicateKeysCheck.
+type DuplicateKeyError struct {
+	Duplicates []DuplicateKey
+}
+
+func (e *DuplicateKeyError) Error() string {
+	duplicates := make([]string, len(e.Duplicates))
+	for i, d := range e.Duplicates {
+		duplicates[i] = fmt.Sprintf(
+			"table %s, key (%s): record %d of %s and record %d of %s",
+			d.Table,
+			d.Key,
+			d.Index,
+			d.File,
+			d.OtherIndex,
+			d.OtherFile,
+		)
+	}
+	return fmt.Sprintf("testfixtures: duplicate primary keys:\n%s", strings.Join(duplicates, "\n"))
+}
+
+// checkDuplicateKeys returns a *DuplicateKeyError if records of the fixture
+// files have the same primary key.
+func (l *Loader) checkDuplicateKeys() error {
+	type recordRef struct {
+		file  string
+		index int
+	}
+
+	var (
+		duplicates []DuplicateKey
+		seen       = make(map[string]map[string]recordRef)
+	)
+	for _, f := range l.fixturesFiles {
+		var (
+			tableName  = f.fileNameWithoutExtension()
+			primaryKey = l.primaryKey(tableName)
+			file       = f.path
+		)
+		if file == "" {
+			file = f.fileName
+		}
+		if seen[tableName] == nil {
+			seen[tableName] = make(map[string]recordRef)
+		}
+
+		for j, i := range f.insertSQLs {
+			key, ok := primaryKeyValue(i.record(), primaryKey)
+			if !ok {
+				continue
+			}
+			if first, ok := seen[tableName][key]; ok {
+				duplicates = append(duplicates, DuplicateKey{
+					Table:      tableName,
+					Key:        key,
+					File:       first.file,
+					Index:      first.index,
+					OtherFile:  file,
+					OtherIndex: j,
+				})
+				continue
+			}
+			seen[tableName][key] = recordRef{file: file, index: j}
+		}
+	}
+	if len(duplicates) > 0 {
+		return &DuplicateKeyError{Duplicates: duplicates}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package testfixtures
+
+import "fmt"
+
+// ColumnEncoder sets a function converting the values of a column, as read
+// from the fixture files, to the values given to the driver, like for
+// composite types or for "hstore" columns on PostgreSQL. The values are then
+// not converted by Loader, like strings to times or objects to JSON, but
+// ColumnCast still applies. An error of the function aborts the load and
+// names the file, the record and the column.
+//
+// Can be given more than once, for different columns. See DumpColumnDecoder
+// for the reverse conversion.
+func ColumnEncoder(table, column string, encode func(interface{}) (interface{}, error)) func(*Loader) error {
+	return func(l *Loader) error {
+		if encode == nil {
+			return fmt.Errorf("testfixtures: ColumnEncoder requires a function")
+		}
+		if l.columnEncoders == nil {
+			l.columnEncoders = make(map[string]map[string]func(interface{}) (interface{}, error))
+		}
+		if l.columnEncoders[table] == nil {
+			l.columnEncoders[table] = make(map[string]func(interface{}) (interface{}, error))
+		}
+		l.columnEncoders[table][column] = encode
+		return nil
+	}
+}
+
+// DumpColumnDecoder sets a function converting the values of a column, as
+// read from the database, to the values written to the fixture files, which
+// are then not converted by Dumper. It's the reverse of ColumnEncoder, so
+// the files can be loaded back.
+//
+// Can be given more than once, for different columns.
+func DumpColumnDecoder(table, column string, decode func(interface{}) (interface{}, error)) func(*Dumper) error {
+	return func(d *Dumper) error {
+		if decode == nil {
+			return fmt.Errorf("testfixtures: DumpColumnDecoder requires a function")
+		}
+		if d.columnDecoders == nil {
+			d.columnDecoders = make(map[string]map[string]func(interface{}) (interface{}, error))
+		}
+		if d.columnDecoders[table] == nil {
+			d.columnDecoders[table] = make(map[string]func(interface{}) (interface{}, error))
+		}
+		d.columnDecoders[table][column] = decode
+		return nil
+	}
+}
+
+// encodeError is returned when the function given with ColumnEncoder fails.
+type encodeError struct {
+	Record string
+	Column string
+	Err    error
+}
+
+func (e *encodeError) Error() string {
+	return fmt.Sprintf(`testfixtures: could not encode column "%s" of %s: %v`, e.Column, e.Record, e.Err)
+}
+
+func (e *encodeError) Unwrap() error {
+	return e.Err
+}
+
+// recordName names a record of a file in errors, by its label, if any, or
+// by its index.
+func recordName(i int, label string) string {
+	if label != "" {
+		return fmt.Sprintf(`record "%s"`, label)
+	}
+	return fmt.Sprintf("record %d", i)
+}
@@ -0,0 +1,55 @@
+package testfixtures
+
+import (
+	"fmt"
+
+	"golang.org/x/text/encoding"
+)
+
+// FileEncoding sets the encoding of fixture files not written in UTF-8, like
+// charmap.ISO8859_1 for Latin-1 files, so they are converted to UTF-8 before
+// being parsed. The names are table names, like "users", or file names, like
+// "users.yml"; all the files are converted if none is given.
+//
+// Must be given before the options reading the files, like Directory or
+// Files.
+func FileEncoding(enc encoding.Encoding, names ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if enc == nil {
+			return fmt.Errorf("testfixtures: FileEncoding requires an encoding")
+		}
+		if len(names) == 0 {
+			l.defaultEncoding = enc
+			return nil
+		}
+		if l.fileEncodings == nil {
+			l.fileEncodings = make(map[string]encoding.Encoding, len(names))
+		}
+		for _, name := range names {
+			l.fileEncodings[name] = enc
+		}
+		return nil
+	}
+}
+
+// decodeFile converts the content of the file to UTF-8, if it was given an
+// encoding with FileEncoding.
+func (l *Loader) decodeFile(f *fixtureFile) error {
+	enc, ok := l.fileEncodings[f.fileName]
+	if !ok {
+		enc, ok = l.fileEncodings[f.fileNameWithoutExtension()]
+	}
+	if !ok {
+		enc = l.defaultEncoding
+	}
+	if enc == nil {
+		return nil
+	}
+
+	content, err := enc.NewDecoder().Bytes(f.content)
+	if err != nil {
+		return fmt.Errorf(`testfixtures: could not decode file "%s": %w`, f.path, err)
+	}
+	f.content = content
+	return nil
+}
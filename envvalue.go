@@ -0,0 +1,47 @@
+package testfixtures
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const envValuePrefix = "env:"
+
+// EnvValues makes Loader replace the string values starting with "env:" by
+// the value of the environment variable following the prefix, like in
+// "password: env:DB_PASSWORD". A default used when the variable is not set
+// can follow the name, like in "host: env:DB_HOST:localhost". Otherwise, New
+// returns an error when the variable is not set.
+//
+// Only string values are replaced, and the variables are read by New.
+func EnvValues() func(*Loader) error {
+	return func(l *Loader) error {
+		l.envValues = true
+		return nil
+	}
+}
+
+// envValue returns the value of the environment variable referenced by a
+// value of a fixture file. See EnvValues.
+func envValue(value string) (string, error) {
+	name := strings.TrimPrefix(value, envValuePrefix)
+	var (
+		defaultValue string
+		hasDefault   bool
+	)
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name, defaultValue, hasDefault = name[:i], name[i+1:], true
+	}
+	if name == "" {
+		return "", fmt.Errorf(`testfixtures: missing environment variable name in value "%s"`, value)
+	}
+
+	if v, ok := os.LookupEnv(name); ok {
+		return v, nil
+	}
+	if hasDefault {
+		return defaultValue, nil
+	}
+	return "", fmt.Errorf(`testfixtures: environment variable "%s" is not set`, name)
+}
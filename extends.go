@@ -0,0 +1,123 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// recordIndex finds the labeled records of the fixture files, by table, to
+// resolve the _extends keys of the records.
+type recordIndex struct {
+	tables map[string]map[string]*fixtureRecord
+
+	// resolving are the records being resolved, to detect cycles.
+	resolving map[*fixtureRecord]bool
+}
+
+func newRecordIndex(files []*fixtureFile) *recordIndex {
+	index := &recordIndex{
+		tables:    make(map[string]map[string]*fixtureRecord),
+		resolving: make(map[*fixtureRecord]bool),
+	}
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		for i := range file.records {
+			record := &file.records[i]
+			if record.label == "" {
+				continue
+			}
+			if index.tables[tableName] == nil {
+				index.tables[tableName] = make(map[string]*fixtureRecord)
+			}
+			index.tables[tableName][record.label] = record
+		}
+	}
+	return index
+}
+
+// resolveFile merges into the records of the file having an _extends key
+// the values of the records they extend, which are resolved first.
+func (index *recordIndex) resolveFile(file *fixtureFile) error {
+	tableName := file.fileNameWithoutExtension()
+	for i := range file.records {
+		if err := index.resolve(tableName, &file.records[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve resolves the _extends key of the record, if any. chain are the
+// names of the records extended by the record, to report cycles.
+func (index *recordIndex) resolve(tableName string, record *fixtureRecord, chain []string) error {
+	values, ok := record.values.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	extends, ok := values[extendsKey]
+	if !ok {
+		return nil
+	}
+
+	name := record.label
+	if name == "" {
+		name = fmt.Sprintf("%s record", tableName)
+	}
+	chain = append(chain, fmt.Sprintf(`"%s"`, name))
+	if index.resolving[record] {
+		return fmt.Errorf("testfixtures: records extend each other in a cycle: %s", strings.Join(chain, " -> "))
+	}
+
+	label, ok := extends.(string)
+	if !ok || label == "" {
+		return fmt.Errorf(`testfixtures: %s of record "%s" must be the label of a record`, extendsKey, name)
+	}
+	parentTable, parent := index.find(tableName, label)
+	if parent == nil {
+		return fmt.Errorf(`testfixtures: record "%s" extends unknown record "%s"`, name, label)
+	}
+
+	index.resolving[record] = true
+	err := index.resolve(parentTable, parent, chain)
+	delete(index.resolving, record)
+	if err != nil {
+		return err
+	}
+	parentValues, ok := parent.values.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf(`testfixtures: record "%s" extends record "%s", which is not a map`, name, label)
+	}
+
+	// The values of the record override the ones of the extended record,
+	// unless they only come from the _defaults of its file.
+	merged := make(map[interface{}]interface{}, len(parentValues)+len(values))
+	for key, value := range parentValues {
+		merged[key] = value
+	}
+	for key, value := range values {
+		if key == extendsKey {
+			continue
+		}
+		if _, ok := parentValues[key]; ok && record.defaulted[key] {
+			continue
+		}
+		merged[key] = value
+	}
+	record.values = merged
+	return nil
+}
+
+// find returns the record with the label in the table, or, for labels
+// qualified by a table, like "users.admin", in that table.
+func (index *recordIndex) find(tableName, label string) (string, *fixtureRecord) {
+	if record, ok := index.tables[tableName][label]; ok {
+		return tableName, record
+	}
+	if i := strings.LastIndex(label, "."); i > 0 {
+		tableName, label = label[:i], label[i+1:]
+		if record, ok := index.tables[tableName][label]; ok {
+			return tableName, record
+		}
+	}
+	return "", nil
+}
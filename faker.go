@@ -0,0 +1,99 @@
+package testfixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var (
+	fakeFirstNames = []string{
+		"Alice", "Bruno", "Carla", "David", "Emma", "Felipe", "Grace", "Hugo",
+		"Irene", "John", "Karen", "Lucas", "Maria", "Noah", "Olivia", "Pedro",
+	}
+	fakeLastNames = []string{
+		"Almeida", "Brown", "Costa", "Dubois", "Evans", "Ferreira", "Garcia",
+		"Hansen", "Ito", "Jones", "Kowalski", "Lopez", "Miller", "Nguyen",
+		"Okafor", "Smith",
+	}
+	fakeLoremWords = []string{
+		"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing",
+		"elit", "sed", "do", "eiusmod", "tempor", "incididunt", "ut", "labore",
+		"et", "dolore", "magna", "aliqua", "enim", "ad", "minim", "veniam",
+		"quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi",
+	}
+)
+
+// Seed sets the seed of the random values generated by the template
+// functions, like randomName, so the fixtures are the same on every run.
+// Without it, they change on every run. Give it before the options reading
+// the fixture files.
+func Seed(seed int64) func(*Loader) error {
+	return func(l *Loader) error {
+		l.rand = rand.New(rand.NewSource(seed))
+		return nil
+	}
+}
+
+// fakeFuncs returns the template functions generating random values, which
+// are available to all templates, unless TemplateFuncs gives functions with
+// the same names: "uuid4", "randomName", "randomEmail", "randomInt min max"
+// (both included), "randomDate from to" (formatted like
+// "2006-01-02 15:04:05") and "loremWords n".
+func (l *Loader) fakeFuncs() template.FuncMap {
+	if l.rand == nil {
+		l.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	r := l.rand
+
+	return template.FuncMap{
+		"uuid4": func() string {
+			var b [16]byte
+			_, _ = r.Read(b[:])
+			b[6] = b[6]&0x0f | 0x40 // version 4
+			b[8] = b[8]&0x3f | 0x80 // variant 10
+			return formatUUID(b)
+		},
+		"randomName": func() string {
+			return fakeFirstNames[r.Intn(len(fakeFirstNames))] + " " + fakeLastNames[r.Intn(len(fakeLastNames))]
+		},
+		"randomEmail": func() string {
+			return fmt.Sprintf(
+				"%s.%s%d@example.com",
+				strings.ToLower(fakeFirstNames[r.Intn(len(fakeFirstNames))]),
+				strings.ToLower(fakeLastNames[r.Intn(len(fakeLastNames))]),
+				r.Intn(1000),
+			)
+		},
+		"randomInt": func(min, max int) (int, error) {
+			if min > max {
+				return 0, fmt.Errorf("testfixtures: randomInt: min %d is greater than max %d", min, max)
+			}
+			return min + r.Intn(max-min+1), nil
+		},
+		"randomDate": func(from, to string) (string, error) {
+			fromTime, err := l.tryStrToDate(from)
+			if err != nil {
+				return "", err
+			}
+			toTime, err := l.tryStrToDate(to)
+			if err != nil {
+				return "", err
+			}
+			if fromTime.After(toTime) {
+				return "", fmt.Errorf(`testfixtures: randomDate: "%s" is after "%s"`, from, to)
+			}
+			seconds := r.Int63n(int64(toTime.Sub(fromTime)/time.Second) + 1)
+			return fromTime.Add(time.Duration(seconds) * time.Second).Format("2006-01-02 15:04:05"), nil
+		},
+		"loremWords": func(n int) string {
+			words := make([]string, n)
+			for i := range words {
+				words[i] = fakeLoremWords[r.Intn(len(fakeLoremWords))]
+			}
+			return strings.Join(words, " ")
+		},
+	}
+}
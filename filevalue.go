@@ -0,0 +1,108 @@
+package testfixtures
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	fileValuePrefix    = "file:"
+	binFileValuePrefix = "binfile:"
+)
+
+// FileValues makes Loader read the values starting with "file:" or
+// "binfile:" from the file at the path following the prefix, relative to the
+// directory of the fixture file, like in "body: file:bodies/post1.html".
+// Values of "file:" are bound as text, and values of "binfile:" as bytes.
+//
+// The files are read by New, which returns an error naming both the fixture
+// file and the missing file.
+func FileValues() func(*Loader) error {
+	return func(l *Loader) error {
+		l.fileValues = true
+		return nil
+	}
+}
+
+// isFileValue reports whether the value is a reference to a file. See
+// FileValues.
+func isFileValue(value string) bool {
+	return strings.HasPrefix(value, fileValuePrefix) || strings.HasPrefix(value, binFileValuePrefix)
+}
+
+// readFileValue returns the content of the file referenced by a value of the
+// fixture file.
+func (f *fixtureFile) readFileValue(value string) (interface{}, error) {
+	binary := strings.HasPrefix(value, binFileValuePrefix)
+	name := strings.TrimPrefix(strings.TrimPrefix(value, binFileValuePrefix), fileValuePrefix)
+	if name == "" {
+		return nil, fmt.Errorf(`testfixtures: missing path in value "%s" of file "%s"`, value, f.path)
+	}
+
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(f.path), filepath.FromSlash(name))
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`testfixtures: could not read file "%s" referenced by file "%s": %w`, path, f.path, err)
+	}
+	if binary {
+		return content, nil
+	}
+	return string(content), nil
+}
+
+// DumpFileValues makes Dumper write the values of at least minSize bytes to
+// their own files, referenced from the fixtures like with FileValues. The
+// files are written in a directory named after the table, next to its
+// fixture file, with a ".bin" extension for binary values and ".txt"
+// otherwise.
+func DumpFileValues(minSize int) func(*Dumper) error {
+	return func(d *Dumper) error {
+		if minSize <= 0 {
+			return fmt.Errorf("testfixtures: DumpFileValues requires a positive size")
+		}
+		d.fileValuesMinSize = minSize
+		return nil
+	}
+}
+
+// fileValue writes the value of a column of the row-th row of a table to a
+// file if it's big enough, returning the value referencing it, or the value
+// unchanged otherwise.
+func (d *Dumper) fileValue(table, column string, row int, value interface{}) (interface{}, error) {
+	var (
+		content []byte
+		prefix  = fileValuePrefix
+		ext     = ".txt"
+	)
+	switch v := value.(type) {
+	case string:
+		content = []byte(v)
+	case []byte:
+		content = v
+		if !utf8.Valid(v) {
+			prefix, ext = binFileValuePrefix, ".bin"
+		}
+	default:
+		return value, nil
+	}
+	if len(content) < d.fileValuesMinSize {
+		return value, nil
+	}
+
+	dir := filepath.Join(d.dir, table)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%d_%s%s", row, column, ext)
+	if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+		return nil, err
+	}
+	return prefix + table + "/" + name, nil
+}
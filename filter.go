@@ -0,0 +1,33 @@
+package testfixtures
+
+import "fmt"
+
+// ContentFilter sets a function changing the content of each fixture file
+// before it's parsed, like to replace placeholders with the values of
+// environment variables. It's given the path of the file and its content,
+// after it was processed as a template, if Template is given. Several
+// filters are applied in the order they are given.
+//
+// Must be given before the options reading the files, like Directory or
+// Files.
+func ContentFilter(filter func(name string, content []byte) ([]byte, error)) func(*Loader) error {
+	return func(l *Loader) error {
+		if filter == nil {
+			return fmt.Errorf("testfixtures: ContentFilter requires a function")
+		}
+		l.contentFilters = append(l.contentFilters, filter)
+		return nil
+	}
+}
+
+// filterContent applies the filters given with ContentFilter to the content
+// of the file at the path.
+func (l *Loader) filterContent(path string, content []byte) ([]byte, error) {
+	for _, filter := range l.contentFilters {
+		var err error
+		if content, err = filter(path, content); err != nil {
+			return nil, fmt.Errorf(`testfixtures: could not filter file "%s": %w`, path, err)
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,302 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+type firebird struct {
+	baseHelper
+
+	skipResetSequences bool
+	resetSequencesTo   int64
+
+	constraints []fbConstraint
+	generators  []string
+}
+
+type fbConstraint struct {
+	tableName         string
+	constraintName    string
+	columns           []string
+	referencedTable   string
+	referencedColumns []string
+	updateRule        string
+	deleteRule        string
+}
+
+func (h *firebird) init(db dbHandle) error {
+	var err error
+	h.constraints, err = h.getConstraints(db)
+	if err != nil {
+		return err
+	}
+	h.generators, err = h.getGenerators(db)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (*firebird) paramType() int {
+	return paramTypeQuestion
+}
+
+// quoteKeyword quotes identifiers with double quotes. Firebird stores the
+// unquoted identifiers in upper case, so lower case ones, like the names of
+// the fixture files, are converted to upper case.
+func (*firebird) quoteKeyword(s string) string {
+	parts := strings.Split(s, ".")
+	for i, p := range parts {
+		if p == strings.ToLower(p) {
+			p = strings.ToUpper(p)
+		}
+		parts[i] = fmt.Sprintf(`"%s"`, strings.ReplaceAll(p, `"`, `""`))
+	}
+	return strings.Join(parts, ".")
+}
+
+func (*firebird) selectLimitSQL(table string, limit int) string {
+	return fmt.Sprintf("SELECT FIRST %d * FROM %s", limit, table)
+}
+
+func (*firebird) databaseName(q queryable) (string, error) {
+	var dbName string
+	if err := q.QueryRow("SELECT TRIM(MON$DATABASE_NAME) FROM MON$DATABASE").Scan(&dbName); err != nil {
+		return "", err
+	}
+	// The name is the path of the database file, maybe on Windows.
+	if i := strings.LastIndexAny(dbName, `/\`); i >= 0 {
+		dbName = dbName[i+1:]
+	}
+	return dbName, nil
+}
+
+func (*firebird) tableNames(q queryable) ([]string, error) {
+	const query = `
+		SELECT TRIM(RDB$RELATION_NAME)
+		FROM RDB$RELATIONS
+		WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+		  AND RDB$VIEW_BLR IS NULL
+	`
+	return queryStrings(q, query)
+}
+
+func (*firebird) getGenerators(q queryable) ([]string, error) {
+	const query = `
+		SELECT TRIM(RDB$GENERATOR_NAME)
+		FROM RDB$GENERATORS
+		WHERE COALESCE(RDB$SYSTEM_FLAG, 0) = 0
+	`
+	return queryStrings(q, query)
+}
+
+func (*firebird) getConstraints(q queryable) ([]fbConstraint, error) {
+	const query = `
+		SELECT
+			TRIM(rc.RDB$RELATION_NAME),
+			TRIM(rc.RDB$CONSTRAINT_NAME),
+			TRIM(seg.RDB$FIELD_NAME),
+			TRIM(uq.RDB$RELATION_NAME),
+			TRIM(uq_seg.RDB$FIELD_NAME),
+			TRIM(ref.RDB$UPDATE_RULE),
+			TRIM(ref.RDB$DELETE_RULE)
+		FROM RDB$RELATION_CONSTRAINTS rc
+		INNER JOIN RDB$REF_CONSTRAINTS ref ON ref.RDB$CONSTRAINT_NAME = rc.RDB$CONSTRAINT_NAME
+		INNER JOIN RDB$RELATION_CONSTRAINTS uq ON uq.RDB$CONSTRAINT_NAME = ref.RDB$CONST_NAME_UQ
+		INNER JOIN RDB$INDEX_SEGMENTS seg ON seg.RDB$INDEX_NAME = rc.RDB$INDEX_NAME
+		INNER JOIN RDB$INDEX_SEGMENTS uq_seg ON uq_seg.RDB$INDEX_NAME = uq.RDB$INDEX_NAME
+		  AND uq_seg.RDB$FIELD_POSITION = seg.RDB$FIELD_POSITION
+		WHERE rc.RDB$CONSTRAINT_TYPE = 'FOREIGN KEY'
+		ORDER BY rc.RDB$CONSTRAINT_NAME, seg.RDB$FIELD_POSITION
+	`
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var constraints []fbConstraint
+	for rows.Next() {
+		var (
+			c                        fbConstraint
+			column, referencedColumn string
+		)
+		if err = rows.Scan(
+			&c.tableName,
+			&c.constraintName,
+			&column,
+			&c.referencedTable,
+			&referencedColumn,
+			&c.updateRule,
+			&c.deleteRule,
+		); err != nil {
+			return nil, err
+		}
+		// Constraints on more than one column have one row per column.
+		if n := len(constraints); n > 0 && constraints[n-1].constraintName == c.constraintName {
+			constraints[n-1].columns = append(constraints[n-1].columns, column)
+			constraints[n-1].referencedColumns = append(constraints[n-1].referencedColumns, referencedColumn)
+			continue
+		}
+		c.columns = []string{column}
+		c.referencedColumns = []string{referencedColumn}
+		constraints = append(constraints, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// disableReferentialIntegrity drops the foreign keys while loading and
+// creates them again after, as Firebird has no way to disable them. Each
+// statement is executed on its own, and the constraints are changed outside
+// of the loading transaction, because Firebird doesn't allow to use a table
+// altered in the same transaction.
+func (h *firebird) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
+	if !h.skipResetSequences {
+		defer func() {
+			if err2 := h.resetSequences(db); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
+	}
+
+	defer func() {
+		if err2 := execAll(db, h.createConstraintsSQL()); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+	if err = execAll(db, h.dropConstraintsSQL()); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = loadFn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (h *firebird) disableReferentialIntegrityForSession(q queryable, fn func() error) (err error) {
+	if !h.skipResetSequences {
+		defer func() {
+			if err2 := h.resetSequences(q); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
+	}
+
+	defer func() {
+		if err2 := execAll(q, h.createConstraintsSQL()); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+	if err = execAll(q, h.dropConstraintsSQL()); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (h *firebird) dropConstraintsSQL() []string {
+	statements := make([]string, 0, len(h.constraints))
+	for _, c := range h.constraints {
+		statements = append(statements, fmt.Sprintf(
+			"ALTER TABLE %s DROP CONSTRAINT %s",
+			h.quoteKeyword(c.tableName),
+			h.quoteKeyword(c.constraintName),
+		))
+	}
+	return statements
+}
+
+func (h *firebird) createConstraintsSQL() []string {
+	statements := make([]string, 0, len(h.constraints))
+	for _, c := range h.constraints {
+		statements = append(statements, fmt.Sprintf(
+			"ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s) ON UPDATE %s ON DELETE %s",
+			h.quoteKeyword(c.tableName),
+			h.quoteKeyword(c.constraintName),
+			h.quoteKeywords(c.columns),
+			h.quoteKeyword(c.referencedTable),
+			h.quoteKeywords(c.referencedColumns),
+			fbReferentialAction(c.updateRule),
+			fbReferentialAction(c.deleteRule),
+		))
+	}
+	return statements
+}
+
+func (h *firebird) quoteKeywords(keywords []string) string {
+	quoted := make([]string, len(keywords))
+	for i, keyword := range keywords {
+		quoted[i] = h.quoteKeyword(keyword)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// fbReferentialAction returns the action of a foreign key, as stored in
+// RDB$REF_CONSTRAINTS, to be used in "ALTER TABLE ... ADD CONSTRAINT".
+// "RESTRICT" is stored when no action was given.
+func fbReferentialAction(rule string) string {
+	switch rule {
+	case "", "RESTRICT":
+		return "NO ACTION"
+	default:
+		return rule
+	}
+}
+
+func (h *firebird) resetSequences(q queryable) error {
+	resetSequencesTo := h.resetSequencesTo
+	if resetSequencesTo == 0 {
+		resetSequencesTo = 10000
+	}
+
+	for _, generator := range h.generators {
+		if _, err := q.Exec(fmt.Sprintf("SET GENERATOR %s TO %d", h.quoteKeyword(generator), resetSequencesTo)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execAll executes the statements one by one, as Firebird doesn't allow more
+// than one statement per query.
+func execAll(q queryable, statements []string) error {
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryStrings returns the single string column of the rows of a query.
+func queryStrings(q queryable, query string) ([]string, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err = rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
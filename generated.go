@@ -0,0 +1,130 @@
+package testfixtures
+
+import "strings"
+
+// SkipGeneratedColumns sets whether the values of the generated columns, like
+// "GENERATED ALWAYS AS (...) STORED" columns on PostgreSQL or computed
+// columns on SQL Server, are left out of the inserts, as these databases
+// reject them. It's true by default, so fixtures generated by Dumper, which
+// leaves them out too, and older ones still giving them can be loaded.
+//
+// On PostgreSQL, the records giving the value of a "GENERATED ALWAYS AS
+// IDENTITY" column are inserted with "OVERRIDING SYSTEM VALUE", so explicit
+// ids are loaded.
+//
+// The columns are found when the Loader is created. If the database can't
+// list them, like servers only compatible with PostgreSQL, none are left
+// out. Only supported on PostgreSQL and SQL Server, and ignored on other
+// databases.
+func SkipGeneratedColumns(skip bool) func(*Loader) error {
+	return func(l *Loader) error {
+		l.keepGeneratedColumns = !skip
+		return nil
+	}
+}
+
+// generatedColumns are the columns whose values are generated by the
+// database, by table. The tables found by their name without the schema,
+// like in the search path on PostgreSQL, are also given without it.
+type generatedColumns struct {
+	// computed are the columns whose values can't be inserted.
+	computed map[string]map[string]bool
+	// identity are the identity columns whose values can only be inserted
+	// with "OVERRIDING SYSTEM VALUE".
+	identity map[string]map[string]bool
+}
+
+// queryGeneratedColumns returns the generated columns returned by a query
+// selecting the schema, the table and the column, whether the table is found
+// by its name without the schema and whether the column is an identity one.
+func queryGeneratedColumns(q queryable, query string) (*generatedColumns, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := &generatedColumns{
+		computed: make(map[string]map[string]bool),
+		identity: make(map[string]map[string]bool),
+	}
+	for rows.Next() {
+		var (
+			schema, table, column     string
+			defaultSchema, isIdentity bool
+		)
+		if err = rows.Scan(&schema, &table, &column, &defaultSchema, &isIdentity); err != nil {
+			return nil, err
+		}
+		tables := columns.computed
+		if isIdentity {
+			tables = columns.identity
+		}
+		names := []string{schema + "." + table}
+		if defaultSchema {
+			names = append(names, table)
+		}
+		for _, name := range names {
+			if tables[name] == nil {
+				tables[name] = make(map[string]bool)
+			}
+			tables[name][column] = true
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// loadGeneratedColumns finds the generated columns of the database, unless
+// SkipGeneratedColumns(false) is given.
+func (l *Loader) loadGeneratedColumns() {
+	if l.keepGeneratedColumns {
+		return
+	}
+	l.generatedColumns = listGeneratedColumns(l.helper, l.db)
+}
+
+// listGeneratedColumns returns the generated columns of the database, or nil
+// if the dialect or the database can't list them, in which case the values
+// are given to the database as they are.
+func listGeneratedColumns(h helper, q queryable) *generatedColumns {
+	lister, ok := h.(generatedColumnLister)
+	if !ok {
+		return nil
+	}
+	columns, err := lister.generatedColumns(q)
+	if err != nil {
+		return nil
+	}
+	return columns
+}
+
+// computedColumns returns the columns of the table whose values can't be
+// inserted.
+func (l *Loader) computedColumns(tableName string) map[string]bool {
+	if l.generatedColumns == nil {
+		return nil
+	}
+	return l.generatedColumns.computed[l.generatedColumnsTableName(tableName)]
+}
+
+// identityColumns returns the identity columns of the table whose values
+// can only be inserted with "OVERRIDING SYSTEM VALUE".
+func (l *Loader) identityColumns(tableName string) map[string]bool {
+	if l.generatedColumns == nil {
+		return nil
+	}
+	return l.generatedColumns.identity[l.generatedColumnsTableName(tableName)]
+}
+
+// generatedColumnsTableName returns the name of the table the records of a
+// table are inserted in: the tables not naming a schema are in the one given
+// with Schema, if any.
+func (l *Loader) generatedColumnsTableName(tableName string) string {
+	if l.schema != "" && !strings.Contains(tableName, ".") {
+		return l.schema + "." + tableName
+	}
+	return tableName
+}
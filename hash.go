@@ -0,0 +1,90 @@
+package testfixtures
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"text/template"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptCost sets the cost of the hashes generated by the "bcrypt" template
+// function when none is given, like in '{{bcrypt "secret"}}'. Low costs, like
+// 4, make the hashing faster, which is enough for tests.
+//
+// Defaults to bcrypt.DefaultCost.
+func BcryptCost(cost int) func(*Loader) error {
+	return func(l *Loader) error {
+		if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+			return fmt.Errorf("testfixtures: BcryptCost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, cost)
+		}
+		l.bcryptCost = cost
+		return nil
+	}
+}
+
+// hashFuncs returns the template functions hashing passwords and other
+// values, which are available to all templates, unless TemplateFuncs gives
+// functions with the same names: "bcrypt [cost] plaintext", "sha256 value"
+// and "hmacSHA256 key value", the last two returning hexadecimal strings.
+//
+// As bcrypt is slow on purpose, its hashes are computed once for each cost
+// and plaintext and reused by the Loader. They are salted, so they change on
+// every run, even with Seed.
+func (l *Loader) hashFuncs() template.FuncMap {
+	return template.FuncMap{
+		"bcrypt": func(args ...interface{}) (string, error) {
+			cost := l.bcryptCost
+			if cost == 0 {
+				cost = bcrypt.DefaultCost
+			}
+			var (
+				plaintext string
+				ok        bool
+			)
+			switch len(args) {
+			case 1:
+				plaintext, ok = args[0].(string)
+			case 2:
+				var costOK bool
+				cost, costOK = args[0].(int)
+				plaintext, ok = args[1].(string)
+				ok = ok && costOK
+			}
+			if !ok {
+				return "", fmt.Errorf(`testfixtures: bcrypt expects an optional cost and a string, like '{{bcrypt 10 "secret"}}'`)
+			}
+			return l.bcryptHash(cost, plaintext)
+		},
+		"sha256": func(value string) string {
+			sum := sha256.Sum256([]byte(value))
+			return hex.EncodeToString(sum[:])
+		},
+		"hmacSHA256": func(key, value string) string {
+			mac := hmac.New(sha256.New, []byte(key))
+			mac.Write([]byte(value))
+			return hex.EncodeToString(mac.Sum(nil))
+		},
+	}
+}
+
+// bcryptHash returns the bcrypt hash of a plaintext, computing it only once
+// for each cost and plaintext.
+func (l *Loader) bcryptHash(cost int, plaintext string) (string, error) {
+	key := fmt.Sprintf("%d:%s", cost, plaintext)
+	if hash, ok := l.bcryptHashes[key]; ok {
+		return hash, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(plaintext), cost)
+	if err != nil {
+		return "", fmt.Errorf("testfixtures: bcrypt: %w", err)
+	}
+	if l.bcryptHashes == nil {
+		l.bcryptHashes = make(map[string]string)
+	}
+	l.bcryptHashes[key] = string(hash)
+	return string(hash), nil
+}
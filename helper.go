@@ -1,28 +1,37 @@
 package testfixtures
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
 const (
 	paramTypeDollar = iota + 1
 	paramTypeQuestion
 	paramTypeAtSign
+	paramTypeCustom
 )
 
 type loadFunction func(tx *sql.Tx) error
 
 type helper interface {
-	init(*sql.DB) error
-	disableReferentialIntegrity(*sql.DB, loadFunction) error
+	init(dbHandle) error
+	disableReferentialIntegrity(dbHandle, loadFunction) error
 	paramType() int
 	databaseName(queryable) (string, error)
 	tableNames(queryable) ([]string, error)
 	isTableModified(queryable, string) (bool, error)
 	afterLoad(queryable) error
 	quoteKeyword(string) string
-	whileInsertOnTable(*sql.Tx, string, func() error) error
+
+	// whileInsertOnTable receives the unquoted table name, as in the name of
+	// the fixture file, like "schema.table", to be quoted with quoteKeyword,
+	// and the columns set by the records of the file.
+	whileInsertOnTable(*sql.Tx, string, []string, func() error) error
 }
 
 type queryable interface {
@@ -31,34 +40,251 @@ type queryable interface {
 	QueryRow(string, ...interface{}) *sql.Row
 }
 
-// batchSplitter is an interface with method which returns byte slice for
-// splitting SQL batches. This need to split sql statements and run its
-// separately.
-//
-// For Microsoft SQL Server batch splitter is "GO". For details see
-// https://docs.microsoft.com/en-us/sql/t-sql/language-elements/sql-server-utilities-statements-go
-type batchSplitter interface { //nolint
-	splitter() []byte
+// sessionHelper is implemented by helpers able to keep referential integrity
+// disabled for a whole database session, which allows fixtures to be loaded
+// using more than one transaction. See PerTableTransaction.
+type sessionHelper interface {
+	disableReferentialIntegrityForSession(queryable, func() error) error
+}
+
+// connQueryable makes a *sql.Conn satisfy the queryable interface, so
+// statements are guaranteed to run on the same database session.
+type connQueryable struct {
+	conn *sql.Conn
+}
+
+func (c connQueryable) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.conn.ExecContext(context.Background(), query, args...)
+}
+
+func (c connQueryable) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (c connQueryable) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// tableCleaner is implemented by helpers that need a custom statement to
+// clean a table before loading its fixtures, instead of "DELETE FROM".
+type tableCleaner interface {
+	cleanTableSQL(tableName string) string
+}
+
+// statementSplitter is implemented by helpers that run schema files one
+// statement at a time, because the driver may not accept several statements
+// in a single Exec call or because errors are easier to locate this way.
+type statementSplitter interface {
+	splitStatements(schema string) []string
+}
+
+// columnTyper is implemented by helpers able to convert lists and objects to
+// native types of the database. See ColumnType.
+type columnTyper interface {
+	// typedValue converts a list or an object to a value to be cast to the
+	// given column type.
+	typedValue(value interface{}, columnType string) (interface{}, error)
+}
+
+// orderedHelper is implemented by helpers unable to disable referential
+// integrity, so fixture files must be given in dependency order, like with
+// SkipReferentialIntegrity.
+type orderedHelper interface {
+	requiresDependencyOrder()
+}
+
+// mutationLimiter is implemented by helpers of databases limiting the number
+// of changes in a transaction, so the records are inserted using as many
+// transactions as needed. It also requires the files to be given in
+// dependency order.
+type mutationLimiter interface {
+	// maxMutations returns the maximum number of values inserted in a
+	// transaction.
+	maxMutations() int
+}
+
+// foreignKeyLister is implemented by helpers able to list the foreign keys
+// of the database, to know which tables can be loaded concurrently. See
+// Concurrency.
+type foreignKeyLister interface {
+	foreignKeys(queryable) ([]foreignKey, error)
+}
+
+// savepointer is implemented by helpers not supporting the standard
+// "SAVEPOINT" statements. See ContinueOnError.
+type savepointer interface {
+	savepointSQL(name string) (create, rollback, release string)
+}
+
+// analyzer is implemented by helpers able to update the statistics of a
+// table. See AnalyzeAfterLoad.
+type analyzer interface {
+	analyzeTableSQL(tableName string) string
+}
+
+// copier is implemented by helpers able to insert records with the "COPY"
+// protocol of their driver. See UseCopyFrom.
+type copier interface {
+	// copySQL returns the statement to prepare to copy the given columns,
+	// which are already quoted.
+	copySQL(tableName string, columns []string) string
+}
+
+// caster is implemented by helpers able to cast the inserted values, with
+// the "::" syntax. See ColumnCast.
+type caster interface {
+	castPlaceholder(placeholder, castExpr string) string
+}
+
+// castLister is implemented by helpers able to tell which columns need a
+// cast. See DumpCasts.
+type castLister interface {
+	// columnCasts returns the columns of a table whose values need a cast,
+	// in order, with the type to cast them to.
+	columnCasts(q queryable, tableName string) (yaml.MapSlice, error)
+}
+
+// paramBinder is implemented by helpers that may bind the params of the
+// queries in another way than by position. See UseNamedParams.
+type paramBinder interface {
+	bindParams(params []interface{}) []interface{}
+}
+
+// upserter is implemented by helpers able to insert records that may already
+// exist, updating them instead. See Upsert.
+type upserter interface {
+	// upsertClause returns the clause appended to an "INSERT" statement. The
+	// key and column names are already quoted.
+	upsertClause(keys, columns []string) string
+}
+
+// templater is implemented by helpers able to create a database as a copy of
+// another one. See CreateTemplate.
+type templater interface {
+	// createFromTemplate terminates the sessions connected to both
+	// databases, then drops the database, if it exists, and creates it
+	// from the template.
+	createFromTemplate(q queryable, database, template string) error
+}
+
+// generatedColumnLister is implemented by helpers able to list the columns
+// whose values are generated by the database. See SkipGeneratedColumns.
+type generatedColumnLister interface {
+	generatedColumns(queryable) (*generatedColumns, error)
+}
+
+// selectLimiter is implemented by helpers whose databases don't support
+// "LIMIT" to select the first rows of a table. See DumpMaxRows.
+type selectLimiter interface {
+	// selectLimitSQL returns the query selecting the first rows of a table,
+	// whose name is already quoted.
+	selectLimitSQL(table string, limit int) string
 }
 
 var (
+	_ helper = &duckDB{}
+	_ helper = &firebird{}
 	_ helper = &mySQL{}
 	_ helper = &postgreSQL{}
 	_ helper = &sqlite{}
+	_ helper = &spanner{}
 	_ helper = &sqlserver{}
+	_ helper = &customHelper{}
+
+	_ tableCleaner = &postgreSQL{}
+	_ tableCleaner = &spanner{}
+
+	_ upserter = &mySQL{}
+	_ upserter = &postgreSQL{}
+	_ upserter = &sqlite{}
+
+	_ statementSplitter = &mySQL{}
+	_ statementSplitter = &postgreSQL{}
+	_ statementSplitter = &sqlserver{}
+
+	_ sessionHelper = &firebird{}
+	_ sessionHelper = &mySQL{}
+	_ sessionHelper = &postgreSQL{}
+	_ sessionHelper = &sqlite{}
+	_ sessionHelper = &sqlserver{}
+
+	_ columnTyper = &duckDB{}
+	_ columnTyper = &postgreSQL{}
+
+	_ orderedHelper = &duckDB{}
+	_ orderedHelper = &spanner{}
+
+	_ mutationLimiter = &spanner{}
+
+	_ foreignKeyLister = &mySQL{}
+	_ foreignKeyLister = &postgreSQL{}
+	_ foreignKeyLister = &sqlite{}
+	_ foreignKeyLister = &sqlserver{}
+
+	_ analyzer = &mySQL{}
+	_ analyzer = &postgreSQL{}
+	_ analyzer = &sqlite{}
+	_ analyzer = &sqlserver{}
+
+	_ savepointer = &sqlserver{}
+
+	_ copier = &postgreSQL{}
+
+	_ templater = &postgreSQL{}
+
+	_ generatedColumnLister = &postgreSQL{}
+	_ generatedColumnLister = &sqlserver{}
+
+	_ selectLimiter = &firebird{}
+	_ selectLimiter = &sqlserver{}
+
+	_ paramBinder = &sqlserver{}
+
+	_ castLister = &postgreSQL{}
+
+	_ caster = &duckDB{}
+	_ caster = &postgreSQL{}
+	_ caster = &customHelper{}
 )
 
+// queryForeignKeys returns the foreign keys returned by a query selecting the
+// table and the referenced table.
+func queryForeignKeys(q queryable, query string) ([]foreignKey, error) {
+	rows, err := q.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []foreignKey
+	for rows.Next() {
+		var key foreignKey
+		if err = rows.Scan(&key.table, &key.referencedTable); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
 type baseHelper struct{}
 
-func (baseHelper) init(_ *sql.DB) error {
+func (baseHelper) init(_ dbHandle) error {
 	return nil
 }
 
 func (baseHelper) quoteKeyword(str string) string {
-	return fmt.Sprintf(`"%s"`, str)
+	parts := strings.Split(str, ".")
+	for i, p := range parts {
+		parts[i] = fmt.Sprintf(`"%s"`, p)
+	}
+	return strings.Join(parts, ".")
 }
 
-func (baseHelper) whileInsertOnTable(_ *sql.Tx, _ string, fn func() error) error {
+func (baseHelper) whileInsertOnTable(_ *sql.Tx, _ string, _ []string, fn func() error) error {
 	return fn()
 }
 
@@ -69,3 +295,28 @@ func (baseHelper) isTableModified(_ queryable, _ string) (bool, error) {
 func (baseHelper) afterLoad(_ queryable) error {
 	return nil
 }
+
+// onConflictClause returns the "ON CONFLICT" clause understood by both
+// PostgreSQL and SQLite to update existing records.
+func onConflictClause(keys, columns []string) string {
+	set := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if containsString(keys, column) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	if len(set) == 0 {
+		return fmt.Sprintf("ON CONFLICT (%s) DO NOTHING", strings.Join(keys, ", "))
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(keys, ", "), strings.Join(set, ", "))
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// insertedIDHelper is implemented by helpers able to get the primary keys
+// generated by the database on insert. See CaptureInsertedIDs.
+type insertedIDHelper interface {
+	// insertedIDClauses returns the clauses making an "INSERT" statement
+	// return the given (quoted) column, placed before "VALUES" and at the end
+	// of the statement. If both are empty, sql.Result.LastInsertId is used.
+	insertedIDClauses(column string) (beforeValues, atEnd string)
+}
+
+var (
+	_ insertedIDHelper = &mySQL{}
+	_ insertedIDHelper = &postgreSQL{}
+	_ insertedIDHelper = &sqlite{}
+	_ insertedIDHelper = &sqlserver{}
+)
+
+type insertedIDMode int
+
+const (
+	insertedIDNone insertedIDMode = iota
+	insertedIDReturned
+	insertedIDLastInsertID
+)
+
+// CaptureInsertedIDs makes Loader keep the primary keys of the inserted
+// records, which is useful when the fixtures leave them to the database, like
+// with serial or identity columns. Get them with InsertedID and
+// InsertedIDByLabel after Load.
+//
+// The primary key is "id", unless set with the PrimaryKey option. Only its
+// first column is captured. On PostgreSQL and SQLite (3.35.0 or newer), a
+// "RETURNING" clause is added to the inserts, and an "OUTPUT" one on SQL
+// Server, which doesn't allow it on tables with triggers. On MySQL, the
+// primary key is either taken from the record or from LAST_INSERT_ID().
+func CaptureInsertedIDs() func(*Loader) error {
+	return func(l *Loader) error {
+		l.captureInsertedIDs = true
+		return nil
+	}
+}
+
+func (l *Loader) insertedIDMode() insertedIDMode {
+	if !l.captureInsertedIDs {
+		return insertedIDNone
+	}
+	if beforeValues, atEnd := l.helper.(insertedIDHelper).insertedIDClauses(""); beforeValues == "" && atEnd == "" {
+		return insertedIDLastInsertID
+	}
+	return insertedIDReturned
+}
+
+// lastInsertID returns the primary key of a record inserted on MySQL: its
+// value if given in the record, or the one generated by the database.
+func (i insertSQL) lastInsertID(result sql.Result, primaryKey string) (interface{}, error) {
+	for j, column := range i.columns {
+		if column == primaryKey {
+			return i.params[j], nil
+		}
+	}
+	return result.LastInsertId()
+}
+
+// normalizeInsertedID converts the bytes returned by some drivers for text
+// and UUID columns to a string.
+func normalizeInsertedID(id interface{}) interface{} {
+	if b, ok := id.([]byte); ok {
+		return string(b)
+	}
+	return id
+}
+
+// InsertedID returns the primary key of the index-th (starting at 0) record
+// inserted on a table by the last load, in the order of the fixture files.
+// It requires the CaptureInsertedIDs option.
+func (l *Loader) InsertedID(table string, index int) (interface{}, error) {
+	if !l.captureInsertedIDs {
+		return nil, fmt.Errorf("testfixtures: InsertedID requires the CaptureInsertedIDs option")
+	}
+	i := index
+	for _, file := range l.loadedTables[table] {
+		if i >= 0 && i < len(file.insertedIDs) {
+			return file.insertedIDs[i], nil
+		}
+		i -= len(file.insertedIDs)
+	}
+	return nil, fmt.Errorf(`testfixtures: no record %d inserted on table "%s"`, index, table)
+}
+
+// InsertedIDByLabel works like InsertedID, but finds the record by its label.
+func (l *Loader) InsertedIDByLabel(table, label string) (interface{}, error) {
+	if !l.captureInsertedIDs {
+		return nil, fmt.Errorf("testfixtures: InsertedIDByLabel requires the CaptureInsertedIDs option")
+	}
+	for _, file := range l.loadedTables[table] {
+		for j, id := range file.insertedIDs {
+			if file.insertSQLs[j].label == label {
+				return id, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf(`testfixtures: no record labeled "%s" inserted on table "%s"`, label, table)
+}
@@ -3,6 +3,7 @@ package testfixtures
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 )
 
 var (
@@ -22,6 +23,16 @@ func (m jsonMap) Value() (driver.Value, error) {
 	return json.Marshal(m)
 }
 
+// toJSONString converts a fixture value to a JSON string, to be inserted in a
+// JSON column.
+func toJSONString(v interface{}) (string, error) {
+	bytes, err := json.Marshal(recursiveToJSON(v))
+	if err != nil {
+		return "", fmt.Errorf("testfixtures: could not convert value to JSON: %w", err)
+	}
+	return string(bytes), nil
+}
+
 // Go refuses to convert map[interface{}]interface{} to JSON because JSON only support string keys
 // So it's necessary to recursively convert all map[interface]interface{} to map[string]interface{}
 func recursiveToJSON(v interface{}) (r interface{}) {
@@ -34,7 +45,7 @@ func recursiveToJSON(v interface{}) (r interface{}) {
 	case map[interface{}]interface{}:
 		newMap := make(map[string]interface{}, len(v))
 		for k, e := range v {
-			newMap[k.(string)] = recursiveToJSON(e)
+			newMap[fmt.Sprint(k)] = recursiveToJSON(e)
 		}
 		r = jsonMap(newMap)
 	default:
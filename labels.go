@@ -0,0 +1,78 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+const labelReferencePrefix = "ref:"
+
+// LabelReferences makes Loader replace the string values starting with
+// "ref:" by the primary key of the record with the label following the
+// prefix, like in "post_id: ref:posts.first_post". The label is the map key
+// of the record, or its _label key, and is qualified by the table of the
+// record, unless it's in the same table.
+//
+// The referenced record must give its primary key, which is "id" unless set
+// with PrimaryKey, as a single column. The references are resolved by New,
+// which returns an error for unknown labels.
+func LabelReferences() func(*Loader) error {
+	return func(l *Loader) error {
+		l.labelReferences = true
+		return nil
+	}
+}
+
+// resolveReferences replaces the label references of the records of the
+// file by the primary keys of the referenced records. See LabelReferences.
+func (index *recordIndex) resolveReferences(file *fixtureFile, primaryKey func(string) []string) error {
+	tableName := file.fileNameWithoutExtension()
+	for i := range file.records {
+		record := &file.records[i]
+		values, ok := record.values.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range values {
+			str, ok := value.(string)
+			if !ok || !strings.HasPrefix(str, labelReferencePrefix) {
+				continue
+			}
+			id, err := index.referencedKey(tableName, strings.TrimPrefix(str, labelReferencePrefix), primaryKey)
+			if err != nil {
+				return fmt.Errorf("testfixtures: could not resolve %s of %s: %w", key, recordName(i, record.label), err)
+			}
+			values[key] = id
+		}
+	}
+	return nil
+}
+
+// referencedKey returns the primary key of the record with the label.
+func (index *recordIndex) referencedKey(tableName, label string, primaryKey func(string) []string) (interface{}, error) {
+	refTable, ref := index.find(tableName, label)
+	if ref == nil {
+		return nil, fmt.Errorf(`unknown record "%s"`, label)
+	}
+	// The primary key may come from an extended record.
+	if err := index.resolve(refTable, ref, nil); err != nil {
+		return nil, err
+	}
+	refValues, ok := ref.values.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf(`record "%s" is not a map`, label)
+	}
+
+	columns := primaryKey(refTable)
+	if len(columns) != 1 {
+		return nil, fmt.Errorf(`record "%s" has a primary key of %d columns`, label, len(columns))
+	}
+	id, ok := refValues[columns[0]]
+	if !ok {
+		return nil, fmt.Errorf(`record "%s" has no "%s" value`, label, columns[0])
+	}
+	if str, ok := id.(string); ok && strings.HasPrefix(str, labelReferencePrefix) {
+		return nil, fmt.Errorf(`the "%s" value of record "%s" is a reference too`, columns[0], label)
+	}
+	return id, nil
+}
@@ -0,0 +1,156 @@
+package testfixtures
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// yamlLineRegexp matches the line number of YAML syntax errors, like
+// "yaml: line 3: did not find expected key".
+var yamlLineRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// LintIssue is a problem found in a fixture file by Lint or LintDirectory.
+type LintIssue struct {
+	// File is the path of the fixture file.
+	File string
+	// Line is the line of the problem, starting at 1, or 0 when unknown.
+	// Only YAML syntax errors have a line.
+	Line int
+	// Message describes the problem.
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.File, i.Message)
+}
+
+// LintDirectory checks the fixture files of a directory without connecting
+// to a database, as a fast check before running the tests. It reports YAML
+// syntax errors, records that are not maps or have non-string keys, duplicate
+// labels, duplicate values of the "id" column and files without records.
+//
+// The files are not processed as templates. The returned error is only about
+// reading the directory.
+func LintDirectory(dir string) ([]LintIssue, error) {
+	l := &Loader{}
+	files, err := l.fixturesFromDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	markPartFiles(files)
+	return l.lintFiles(files), nil
+}
+
+// Lint checks the fixture files of the Loader like LintDirectory does, using
+// the primary keys given with PrimaryKey to find duplicate values. As New
+// already rejects invalid files, it mostly finds duplicates and empty files.
+func (l *Loader) Lint() []LintIssue {
+	return l.lintFiles(l.fixturesFiles)
+}
+
+func (l *Loader) lintFiles(files []*fixtureFile) []LintIssue {
+	var issues []LintIssue
+	for _, f := range files {
+		issues = append(issues, l.lintFile(f)...)
+	}
+	return issues
+}
+
+func (l *Loader) lintFile(f *fixtureFile) []LintIssue {
+	var issues []LintIssue
+	report := func(line int, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{File: f.path, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	records := f.records
+	if f.content != nil {
+		records = nil
+		if err := yaml.Unmarshal(f.content, &records); err != nil {
+			if m := yamlLineRegexp.FindStringSubmatch(err.Error()); m != nil {
+				line, _ := strconv.Atoi(m[1])
+				report(line, "%s", m[2])
+			} else {
+				report(0, "%s", strings.TrimPrefix(err.Error(), "testfixtures: "))
+			}
+			return issues
+		}
+	}
+	if len(records) == 0 {
+		report(0, "no records")
+		return issues
+	}
+
+	var (
+		tableName  = f.fileNameWithoutExtension()
+		primaryKey = l.primaryKey(tableName)
+		keys       = make(map[string]string)
+		labels     = make(map[string]bool)
+	)
+	for i, record := range records {
+		name := fmt.Sprintf("record %d", i+1)
+		if record.label != "" {
+			if labels[record.label] {
+				report(0, `duplicate label "%s"`, record.label)
+				continue
+			}
+			labels[record.label] = true
+			name = fmt.Sprintf(`record "%s"`, record.label)
+		}
+
+		values, ok := record.values.(map[interface{}]interface{})
+		if !ok {
+			report(0, "%s is not a map", name)
+			continue
+		}
+		for key := range values {
+			if _, ok := key.(string); !ok {
+				report(0, "%s has the non-string key %v", name, key)
+			}
+		}
+
+		expanded, err := record.expand()
+		if err != nil {
+			report(0, "%s: %s", name, strings.TrimPrefix(err.Error(), "testfixtures: "))
+			continue
+		}
+		for _, values := range expanded {
+			columns := make(map[string]interface{}, len(values))
+			for key, value := range values {
+				if column, ok := key.(string); ok {
+					columns[column] = value
+				}
+			}
+			key, ok := primaryKeyValue(columns, primaryKey)
+			if !ok {
+				continue
+			}
+			if other, ok := keys[key]; ok {
+				report(0, "%s has the same %s as %s: %s", name, strings.Join(primaryKey, ", "), other, key)
+				continue
+			}
+			keys[key] = name
+		}
+	}
+	return issues
+}
+
+// primaryKeyValue returns the values of the primary key columns of a record,
+// if it has all of them.
+func primaryKeyValue(values map[string]interface{}, primaryKey []string) (string, bool) {
+	parts := make([]string, len(primaryKey))
+	for i, column := range primaryKey {
+		value, ok := values[column]
+		if !ok || value == nil {
+			return "", false
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, ", "), true
+}
@@ -2,16 +2,18 @@ package testfixtures
 
 import (
 	"database/sql"
+	"fmt"
 )
 
 type MockHelper struct {
-	dbName string
+	dbName    string
+	dbNameErr error
 }
 
-func (*MockHelper) init(db *sql.DB) error {
+func (*MockHelper) init(db dbHandle) error {
 	return nil
 }
-func (*MockHelper) disableReferentialIntegrity(*sql.DB, loadFunction) error {
+func (*MockHelper) disableReferentialIntegrity(dbHandle, loadFunction) error {
 	return nil
 }
 func (*MockHelper) paramType() int {
@@ -29,14 +31,36 @@ func (*MockHelper) afterLoad(queryable) error {
 func (*MockHelper) quoteKeyword(string) string {
 	return ""
 }
-func (*MockHelper) whileInsertOnTable(*sql.Tx, string, func() error) error {
+func (*MockHelper) whileInsertOnTable(*sql.Tx, string, []string, func() error) error {
 	return nil
 }
 func (h *MockHelper) databaseName(queryable) (string, error) {
-	return h.dbName, nil
+	return h.dbName, h.dbNameErr
 }
 
 // NewMockHelper returns MockHelper
 func NewMockHelper(dbName string) *MockHelper {
 	return &MockHelper{dbName: dbName}
 }
+
+// FakeHelper is a Helper for a made-up database, quoting names with brackets
+// and using ":1" style placeholders. Referential integrity is disabled with
+// SQLite pragmas, so it can be used to load fixtures on SQLite.
+type FakeHelper struct{}
+
+func (FakeHelper) Placeholder(i int) string {
+	return fmt.Sprintf(":%d", i)
+}
+func (FakeHelper) QuoteKeyword(name string) string {
+	return fmt.Sprintf("[%s]", name)
+}
+func (FakeHelper) DatabaseName(Queryer) (string, error) {
+	return "fake_test", nil
+}
+func (FakeHelper) TableNames(Queryer) ([]string, error) {
+	return nil, nil
+}
+func (FakeHelper) DisableReferentialIntegrity(tx *sql.Tx) error {
+	_, err := tx.Exec("PRAGMA defer_foreign_keys = ON")
+	return err
+}
@@ -3,6 +3,7 @@ package testfixtures
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 )
 
 type mySQL struct {
@@ -15,7 +16,7 @@ type mySQL struct {
 	tablesChecksum map[string]int64
 }
 
-func (h *mySQL) init(db *sql.DB) error {
+func (h *mySQL) init(db dbHandle) error {
 	var err error
 	h.tables, err = h.tableNames(db)
 	if err != nil {
@@ -30,7 +31,11 @@ func (*mySQL) paramType() int {
 }
 
 func (*mySQL) quoteKeyword(str string) string {
-	return fmt.Sprintf("`%s`", str)
+	parts := strings.Split(str, ".")
+	for i, p := range parts {
+		parts[i] = fmt.Sprintf("`%s`", p)
+	}
+	return strings.Join(parts, ".")
 }
 
 func (*mySQL) databaseName(q queryable) (string, error) {
@@ -72,7 +77,7 @@ func (h *mySQL) tableNames(q queryable) ([]string, error) {
 
 }
 
-func (h *mySQL) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction) (err error) {
+func (h *mySQL) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
 	if !h.skipResetSequences {
 		defer func() {
 			if err2 := h.resetSequences(db); err2 != nil && err == nil {
@@ -86,30 +91,55 @@ func (h *mySQL) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction) (er
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// FOREIGN_KEY_CHECKS is a session variable, so it's set on the
+	// connection of the transaction, and must be enabled again before the
+	// connection goes back to the pool, even if loading fails or panics.
 	if _, err = tx.Exec("SET FOREIGN_KEY_CHECKS = 0"); err != nil {
 		return err
 	}
-
-	err = loadFn(tx)
-	_, err2 := tx.Exec("SET FOREIGN_KEY_CHECKS = 1")
+	err = func() (err error) {
+		defer func() {
+			if _, err2 := tx.Exec("SET FOREIGN_KEY_CHECKS = 1"); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
+		return loadFn(tx)
+	}()
 	if err != nil {
 		return err
 	}
-	if err2 != nil {
-		return err2
+	return tx.Commit()
+}
+
+func (h *mySQL) disableReferentialIntegrityForSession(q queryable, fn func() error) (err error) {
+	if !h.skipResetSequences {
+		defer func() {
+			if err2 := h.resetSequences(q); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
 	}
 
-	return tx.Commit()
+	if _, err = q.Exec("SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err2 := q.Exec("SET FOREIGN_KEY_CHECKS = 1"); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+
+	return fn()
 }
 
-func (h *mySQL) resetSequences(db *sql.DB) error {
+func (h *mySQL) resetSequences(q queryable) error {
 	resetSequencesTo := h.resetSequencesTo
 	if resetSequencesTo == 0 {
 		resetSequencesTo = 10000
 	}
 
 	for _, t := range h.tables {
-		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d", h.quoteKeyword(t), resetSequencesTo)); err != nil {
+		if _, err := q.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d", h.quoteKeyword(t), resetSequencesTo)); err != nil {
 			return err
 		}
 	}
@@ -157,3 +187,54 @@ func (h *mySQL) getChecksum(q queryable, tableName string) (int64, error) {
 	}
 	return checksum.Int64, nil
 }
+
+// splitStatements is a statementSplitter interface implementation. MySQL
+// drivers don't run multiple statements in a single call unless explicitly
+// enabled, so schema files are executed one statement at a time. The
+// "DELIMITER" command of the MySQL client is supported, as it's needed to
+// declare procedures and triggers.
+func (*mySQL) splitStatements(schema string) []string {
+	return splitSQLStatements(schema, sqlSplitOptions{
+		backslashEscapes: true,
+		hashComments:     true,
+		delimiterCommand: true,
+	})
+}
+
+// upsertClause is an upserter interface implementation. MySQL finds the
+// existing records by any primary key or unique index, so the keys are only
+// used to know which columns not to update.
+func (*mySQL) upsertClause(keys, columns []string) string {
+	set := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if containsString(keys, column) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s = VALUES(%s)", column, column))
+	}
+	if len(set) == 0 {
+		set = append(set, fmt.Sprintf("%s = %s", keys[0], keys[0]))
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+// insertedIDClauses is an insertedIDHelper interface implementation. MySQL
+// has no "RETURNING" clause, so LAST_INSERT_ID() is used instead.
+func (*mySQL) insertedIDClauses(string) (string, string) {
+	return "", ""
+}
+
+// foreignKeys is a foreignKeyLister interface implementation.
+func (*mySQL) foreignKeys(q queryable) ([]foreignKey, error) {
+	const query = `
+		SELECT table_name, referenced_table_name
+		FROM information_schema.referential_constraints
+		WHERE constraint_schema = DATABASE()
+	`
+	return queryForeignKeys(q, query)
+}
+
+// analyzeTableSQL is an analyzer interface implementation.
+func (h *mySQL) analyzeTableSQL(tableName string) string {
+	return fmt.Sprintf("ANALYZE TABLE %s", h.quoteKeyword(tableName))
+}
@@ -3,8 +3,12 @@
 package testfixtures
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -17,3 +21,82 @@ func TestMySQL(t *testing.T) {
 		"testdata/schema/mysql.sql",
 	)
 }
+
+func TestMySQLWithPerTableTransaction(t *testing.T) {
+	testLoader(
+		t,
+		"mysql",
+		os.Getenv("MYSQL_CONN_STRING"),
+		"testdata/schema/mysql.sql",
+		PerTableTransaction(),
+	)
+}
+
+func TestMySQLForeignKeyChecks(t *testing.T) {
+	db, err := sql.Open("mysql", os.Getenv("MYSQL_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	// A single connection, to check the session variable on the connection
+	// used to load. The loads time out instead of waiting forever if they
+	// ever need a second one.
+	db.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	schemaLoader, err := New(Database(db), Dialect("mysql"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/mysql.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	assertForeignKeyChecks := func(t *testing.T) {
+		t.Helper()
+		var checks int
+		if err := db.QueryRow("SELECT @@SESSION.FOREIGN_KEY_CHECKS").Scan(&checks); err != nil {
+			t.Fatalf("cannot query FOREIGN_KEY_CHECKS: %v", err)
+		}
+		if checks != 1 {
+			t.Errorf("expected the foreign key checks to be enabled again, got %d", checks)
+		}
+	}
+
+	// The comments reference the posts, which are loaded after them.
+	l, err := New(
+		Database(db),
+		Dialect("mysql"),
+		Files(
+			"testdata/fixtures/comments.yml",
+			"testdata/fixtures/posts.yml",
+		),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadContext(ctx); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "comments", 4)
+	assertForeignKeyChecks(t)
+
+	l, err = New(
+		Database(db),
+		Dialect("mysql"),
+		Files("testdata/fixtures_unknown_columns/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	err = l.LoadContext(ctx)
+	if ctx.Err() != nil {
+		t.Fatalf("load should not wait for a second connection: %v", err)
+	}
+	var insertErr *InsertError
+	if !errors.As(err, &insertErr) {
+		t.Fatalf("expected an InsertError loading unknown columns, got %v", err)
+	}
+	assertForeignKeyChecks(t)
+}
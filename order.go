@@ -0,0 +1,170 @@
+package testfixtures
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Order sets the order the fixture files are loaded in, overriding the order
+// they were given or found in directories. The names are table names, like
+// "users", or file names, like "users.yml". Files not listed are loaded
+// afterward, in their usual order.
+//
+// This is useful with SkipReferentialIntegrity, or on databases unable to
+// disable foreign keys, where the tables must be filled in dependency order.
+// Can be given once.
+func Order(names ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if l.order != nil {
+			return fmt.Errorf("testfixtures: Order can be given only once")
+		}
+		l.order = make(map[string]int, len(names))
+		for i, name := range names {
+			if _, ok := l.order[name]; ok {
+				return fmt.Errorf(`testfixtures: "%s" is given twice to Order`, name)
+			}
+			l.order[name] = i
+		}
+		return nil
+	}
+}
+
+// orderFiles returns the files sorted as given with Order.
+func (l *Loader) orderFiles(files []*fixtureFile) []*fixtureFile {
+	if len(l.order) == 0 {
+		return files
+	}
+
+	position := func(file *fixtureFile) int {
+		if i, ok := l.order[file.fileName]; ok {
+			return i
+		}
+		if i, ok := l.order[l.optionsTableName(file.fileNameWithoutExtension())]; ok {
+			return i
+		}
+		return len(l.order)
+	}
+	ordered := make([]*fixtureFile, len(files))
+	copy(ordered, files)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return position(ordered[i]) < position(ordered[j])
+	})
+	return ordered
+}
+
+// DependencyOrder makes Loader sort the fixture files by the foreign keys
+// between their tables, so referenced tables are filled first, which is
+// needed when referential integrity is not disabled, like with
+// SkipReferentialIntegrity. Tables without foreign keys between them keep
+// their usual order.
+//
+// Tables referencing each other, directly or not, can't be sorted, so an
+// error naming them is returned. Load them without DependencyOrder, giving
+// the order of their files with Order or with referential integrity
+// disabled. Tables referencing themselves are fine.
+//
+// Only supported on PostgreSQL, MySQL, SQLite and SQL Server. Can't be used
+// together with Order.
+func DependencyOrder() func(*Loader) error {
+	return func(l *Loader) error {
+		l.dependencyOrder = true
+		return nil
+	}
+}
+
+// sortByDependencies returns the files sorted by the foreign keys between
+// their tables. See DependencyOrder.
+func (l *Loader) sortByDependencies(files []*fixtureFile) ([]*fixtureFile, error) {
+	keys, err := l.helper.(foreignKeyLister).foreignKeys(l.db)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tables     []string
+		tableFiles = make(map[string][]*fixtureFile)
+	)
+	for _, file := range files {
+		table := unqualifiedTableName(file.fileNameWithoutExtension())
+		if _, ok := tableFiles[table]; !ok {
+			tables = append(tables, table)
+		}
+		tableFiles[table] = append(tableFiles[table], file)
+	}
+	references := make(map[string][]string)
+	for _, key := range keys {
+		table, referenced := unqualifiedTableName(key.table), unqualifiedTableName(key.referencedTable)
+		if table == referenced || tableFiles[table] == nil || tableFiles[referenced] == nil {
+			continue
+		}
+		if !containsString(references[table], referenced) {
+			references[table] = append(references[table], referenced)
+		}
+	}
+
+	// Each step takes the first table, in the usual order, whose referenced
+	// tables were all taken, so files already in dependency order keep it.
+	var (
+		sorted = make([]*fixtureFile, 0, len(files))
+		done   = make(map[string]bool, len(tables))
+	)
+	for len(done) < len(tables) {
+		next := ""
+		for _, table := range tables {
+			if done[table] {
+				continue
+			}
+			ready := true
+			for _, referenced := range references[table] {
+				if !done[referenced] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = table
+				break
+			}
+		}
+		if next == "" {
+			return nil, fmt.Errorf(
+				"testfixtures: can't sort the tables by their foreign keys, which form a cycle: %s; drop DependencyOrder, and give their order with Order or load them with referential integrity disabled",
+				strings.Join(findCycle(tables, references, done), " -> "),
+			)
+		}
+		done[next] = true
+		sorted = append(sorted, tableFiles[next]...)
+	}
+	return sorted, nil
+}
+
+// findCycle returns the tables of a cycle of references among the tables not
+// done yet, each one referencing the next one, starting and ending with the
+// same table.
+func findCycle(tables []string, references map[string][]string, done map[string]bool) []string {
+	var (
+		path    []string
+		visited = make(map[string]int)
+	)
+	table := ""
+	for _, t := range tables {
+		if !done[t] {
+			table = t
+			break
+		}
+	}
+	for {
+		if i, ok := visited[table]; ok {
+			return append(path[i:], table)
+		}
+		visited[table] = len(path)
+		path = append(path, table)
+		for _, referenced := range references[table] {
+			if !done[referenced] {
+				table = referenced
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package testfixtures
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// partFileName returns the name of the file of a part of the records of a
+// table, numbered from 1: "posts.yml", "posts.2.yml", "posts.3.yml", etc.
+// See DumpMaxRowsPerFile.
+func partFileName(table string, part int) string {
+	if part <= 1 {
+		return table + ".yml"
+	}
+	return fmt.Sprintf("%s.%d.yml", table, part)
+}
+
+// splitPartNumber splits a file name without its extension in the name of
+// the table and the part number, which is 0 if the name has none.
+func splitPartNumber(name string) (string, int) {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 {
+		return name, 0
+	}
+	for _, r := range name[i+1:] {
+		if r < '0' || r > '9' {
+			return name, 0
+		}
+	}
+	part, err := strconv.Atoi(name[i+1:])
+	if err != nil {
+		return name, 0
+	}
+	return name[:i], part
+}
+
+// markPartFiles marks the files named like the parts written by the dumper,
+// as "posts.2.yml", next to the file of their table, as "posts.yml", so
+// their records are loaded in it. Other files whose name ends with a number,
+// like "events.2021.yml", keep it in the name of their table.
+func markPartFiles(files []*fixtureFile) {
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[filepath.Join(filepath.Dir(file.path), file.fileName)] = true
+	}
+	for _, file := range files {
+		if file.sourcePath != "" {
+			// Named after a table of FilesMultiTables.
+			continue
+		}
+		ext := filepath.Ext(file.fileName)
+		table, part := splitPartNumber(strings.TrimSuffix(file.fileName, ext))
+		file.part = part > 1 && names[filepath.Join(filepath.Dir(file.path), table+ext)]
+	}
+}
+
+// firstFilePerTable returns the first fixture file of each table, in order,
+// for the statements run once per table, like cleaning it, as the records of
+// a table may be split in many files.
+func firstFilePerTable(files []*fixtureFile) []*fixtureFile {
+	var (
+		first  = make([]*fixtureFile, 0, len(files))
+		tables = make(map[string]bool, len(files))
+	)
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		if tables[tableName] {
+			continue
+		}
+		tables[tableName] = true
+		first = append(first, file)
+	}
+	return first
+}
@@ -4,21 +4,28 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
 type postgreSQL struct {
 	baseHelper
 
-	useAlterConstraint bool
-	useDropConstraint  bool
-	skipResetSequences bool
-	resetSequencesTo   int64
+	useAlterConstraint     bool
+	useDropConstraint      bool
+	useDeferredConstraints bool
+	skipResetSequences     bool
+	resetSequencesTo       int64
+	resetAllSequences      bool
+	timescaleDB            bool
 
 	tables                   []string
 	sequences                []string
 	nonDeferrableConstraints []pgConstraint
 	constraints              []pgConstraint
 	tablesChecksum           map[string]string
+	hypertables              map[string]bool
 }
 
 type pgConstraint struct {
@@ -27,7 +34,7 @@ type pgConstraint struct {
 	definition     string
 }
 
-func (h *postgreSQL) init(db *sql.DB) error {
+func (h *postgreSQL) init(db dbHandle) error {
 	var err error
 
 	h.tables, err = h.tableNames(db)
@@ -50,6 +57,13 @@ func (h *postgreSQL) init(db *sql.DB) error {
 		return err
 	}
 
+	if h.timescaleDB {
+		h.hypertables, err = h.getHypertables(db)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -123,6 +137,41 @@ func (h *postgreSQL) getSequences(q queryable) ([]string, error) {
 	return sequences, nil
 }
 
+// getHypertables returns the TimescaleDB hypertables. Their chunks live on
+// internal "_timescaledb" schemas and are never handled directly.
+func (*postgreSQL) getHypertables(q queryable) (map[string]bool, error) {
+	const sql = `
+		SELECT hypertable_schema || '.' || hypertable_name
+		FROM timescaledb_information.hypertables
+	`
+
+	rows, err := q.Query(sql)
+	if err != nil {
+		return nil, fmt.Errorf("testfixtures: could not get hypertables, is the timescaledb extension installed?: %w", err)
+	}
+	defer rows.Close()
+
+	hypertables := make(map[string]bool)
+	for rows.Next() {
+		var hypertable string
+		if err = rows.Scan(&hypertable); err != nil {
+			return nil, err
+		}
+		hypertables[hypertable] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return hypertables, nil
+}
+
+func (h *postgreSQL) isHypertable(tableName string) bool {
+	if !strings.Contains(tableName, ".") {
+		tableName = "public." + tableName
+	}
+	return h.hypertables[tableName]
+}
+
 func (*postgreSQL) getNonDeferrableConstraints(q queryable) ([]pgConstraint, error) {
 	var constraints []pgConstraint
 
@@ -189,32 +238,15 @@ func (h *postgreSQL) getConstraints(q queryable) ([]pgConstraint, error) {
 	return constraints, nil
 }
 
-func (h *postgreSQL) dropAndRecreateConstraints(db *sql.DB, loadFn loadFunction) (err error) {
+func (h *postgreSQL) dropAndRecreateConstraints(db dbHandle, loadFn loadFunction) (err error) {
 	defer func() {
 		// Re-create constraints again after load
-		var b strings.Builder
-		for _, constraint := range h.constraints {
-			b.WriteString(fmt.Sprintf(
-				"ALTER TABLE %s ADD CONSTRAINT %s %s;",
-				h.quoteKeyword(constraint.tableName),
-				h.quoteKeyword(constraint.constraintName),
-				constraint.definition,
-			))
-		}
-		if _, err2 := db.Exec(b.String()); err2 != nil && err == nil {
+		if _, err2 := db.Exec(h.createConstraintsSQL()); err2 != nil && err == nil {
 			err = err2
 		}
 	}()
 
-	var b strings.Builder
-	for _, constraint := range h.constraints {
-		b.WriteString(fmt.Sprintf(
-			"ALTER TABLE %s DROP CONSTRAINT %s;",
-			h.quoteKeyword(constraint.tableName),
-			h.quoteKeyword(constraint.constraintName),
-		))
-	}
-	if _, err := db.Exec(b.String()); err != nil {
+	if _, err := db.Exec(h.dropConstraintsSQL()); err != nil {
 		return err
 	}
 
@@ -231,13 +263,34 @@ func (h *postgreSQL) dropAndRecreateConstraints(db *sql.DB, loadFn loadFunction)
 	return tx.Commit()
 }
 
-func (h *postgreSQL) disableTriggers(db *sql.DB, loadFn loadFunction) (err error) {
+func (h *postgreSQL) dropConstraintsSQL() string {
+	var b strings.Builder
+	for _, constraint := range h.constraints {
+		b.WriteString(fmt.Sprintf(
+			"ALTER TABLE %s DROP CONSTRAINT %s;",
+			h.quoteKeyword(constraint.tableName),
+			h.quoteKeyword(constraint.constraintName),
+		))
+	}
+	return b.String()
+}
+
+func (h *postgreSQL) createConstraintsSQL() string {
+	var b strings.Builder
+	for _, constraint := range h.constraints {
+		b.WriteString(fmt.Sprintf(
+			"ALTER TABLE %s ADD CONSTRAINT %s %s;",
+			h.quoteKeyword(constraint.tableName),
+			h.quoteKeyword(constraint.constraintName),
+			constraint.definition,
+		))
+	}
+	return b.String()
+}
+
+func (h *postgreSQL) disableTriggers(db dbHandle, loadFn loadFunction) (err error) {
 	defer func() {
-		var b strings.Builder
-		for _, table := range h.tables {
-			b.WriteString(fmt.Sprintf("ALTER TABLE %s ENABLE TRIGGER ALL;", h.quoteKeyword(table)))
-		}
-		if _, err2 := db.Exec(b.String()); err2 != nil && err == nil {
+		if _, err2 := db.Exec(h.triggersSQL("ENABLE")); err2 != nil && err == nil {
 			err = err2
 		}
 	}()
@@ -247,11 +300,7 @@ func (h *postgreSQL) disableTriggers(db *sql.DB, loadFn loadFunction) (err error
 		return err
 	}
 
-	var b strings.Builder
-	for _, table := range h.tables {
-		b.WriteString(fmt.Sprintf("ALTER TABLE %s DISABLE TRIGGER ALL;", h.quoteKeyword(table)))
-	}
-	if _, err = tx.Exec(b.String()); err != nil {
+	if _, err = tx.Exec(h.triggersSQL("DISABLE")); err != nil {
 		return err
 	}
 
@@ -263,7 +312,15 @@ func (h *postgreSQL) disableTriggers(db *sql.DB, loadFn loadFunction) (err error
 	return tx.Commit()
 }
 
-func (h *postgreSQL) makeConstraintsDeferrable(db *sql.DB, loadFn loadFunction) (err error) {
+func (h *postgreSQL) triggersSQL(action string) string {
+	var b strings.Builder
+	for _, table := range h.tables {
+		b.WriteString(fmt.Sprintf("ALTER TABLE %s %s TRIGGER ALL;", h.quoteKeyword(table), action))
+	}
+	return b.String()
+}
+
+func (h *postgreSQL) makeConstraintsDeferrable(db dbHandle, loadFn loadFunction) (err error) {
 	defer func() {
 		// ensure constraint being not deferrable again after load
 		var b strings.Builder
@@ -283,6 +340,12 @@ func (h *postgreSQL) makeConstraintsDeferrable(db *sql.DB, loadFn loadFunction)
 		return err
 	}
 
+	return h.deferConstraints(db, loadFn)
+}
+
+// deferConstraints loads the fixtures in a transaction checking the
+// deferrable constraints only on commit.
+func (h *postgreSQL) deferConstraints(db dbHandle, loadFn loadFunction) (err error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -300,7 +363,7 @@ func (h *postgreSQL) makeConstraintsDeferrable(db *sql.DB, loadFn loadFunction)
 	return tx.Commit()
 }
 
-func (h *postgreSQL) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction) (err error) {
+func (h *postgreSQL) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
 	// ensure sequences being reset after load
 	if !h.skipResetSequences {
 		defer func() {
@@ -316,17 +379,63 @@ func (h *postgreSQL) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction
 	if h.useAlterConstraint {
 		return h.makeConstraintsDeferrable(db, loadFn)
 	}
+	if h.useDeferredConstraints {
+		return h.deferConstraints(db, loadFn)
+	}
 	return h.disableTriggers(db, loadFn)
 }
 
-func (h *postgreSQL) resetSequences(db *sql.DB) error {
+func (h *postgreSQL) disableReferentialIntegrityForSession(q queryable, fn func() error) (err error) {
+	if h.useAlterConstraint {
+		return fmt.Errorf("testfixtures: PerTableTransaction can't be used together with UseAlterConstraint")
+	}
+	if h.useDeferredConstraints {
+		return fmt.Errorf("testfixtures: PerTableTransaction can't be used together with UseDeferredConstraints")
+	}
+
+	if !h.skipResetSequences {
+		defer func() {
+			if err2 := h.resetSequences(q); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
+	}
+
+	if h.useDropConstraint {
+		defer func() {
+			if _, err2 := q.Exec(h.createConstraintsSQL()); err2 != nil && err == nil {
+				err = err2
+			}
+		}()
+		if _, err = q.Exec(h.dropConstraintsSQL()); err != nil {
+			return err
+		}
+		return fn()
+	}
+
+	defer func() {
+		if _, err2 := q.Exec(h.triggersSQL("ENABLE")); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+	if _, err = q.Exec(h.triggersSQL("DISABLE")); err != nil {
+		return err
+	}
+	return fn()
+}
+
+func (h *postgreSQL) resetSequences(q queryable) error {
+	if h.resetAllSequences {
+		return h.resetSequencesToMax(q)
+	}
+
 	resetSequencesTo := h.resetSequencesTo
 	if resetSequencesTo == 0 {
 		resetSequencesTo = 10000
 	}
 
 	for _, sequence := range h.sequences {
-		_, err := db.Exec(fmt.Sprintf("SELECT SETVAL('%s', %d)", sequence, resetSequencesTo))
+		_, err := q.Exec(fmt.Sprintf("SELECT SETVAL('%s', %d)", sequence, resetSequencesTo))
 		if err != nil {
 			return err
 		}
@@ -334,6 +443,75 @@ func (h *postgreSQL) resetSequences(db *sql.DB) error {
 	return nil
 }
 
+// pgOwnedSequence is a sequence and the column owning it, if any, like the
+// one of a SERIAL or IDENTITY column.
+type pgOwnedSequence struct {
+	sequence string
+	table    sql.NullString
+	column   sql.NullString
+}
+
+// resetSequencesToMax resets every sequence of the database so the next value
+// follows the greatest value of the column owning it, whether the table had
+// fixtures or not. Sequences not owned by a column are reset like usual. See
+// ResetAllSequences.
+func (h *postgreSQL) resetSequencesToMax(q queryable) error {
+	const query = `
+		SELECT quote_ident(seq_ns.nspname) || '.' || quote_ident(seq.relname)
+			,quote_ident(tbl_ns.nspname) || '.' || quote_ident(tbl.relname)
+			,quote_ident(att.attname)
+		FROM pg_class seq
+		INNER JOIN pg_namespace seq_ns ON seq_ns.oid = seq.relnamespace
+		LEFT JOIN pg_depend dep ON dep.objid = seq.oid
+			AND dep.classid = 'pg_class'::regclass
+			AND dep.refclassid = 'pg_class'::regclass
+			AND dep.deptype IN ('a', 'i')
+		LEFT JOIN pg_class tbl ON tbl.oid = dep.refobjid
+		LEFT JOIN pg_namespace tbl_ns ON tbl_ns.oid = tbl.relnamespace
+		LEFT JOIN pg_attribute att ON att.attrelid = dep.refobjid
+			AND att.attnum = dep.refobjsubid
+		WHERE seq.relkind = 'S'
+		  AND seq_ns.nspname NOT LIKE '\_timescaledb%'
+	`
+	rows, err := q.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var sequences []pgOwnedSequence
+	for rows.Next() {
+		var s pgOwnedSequence
+		if err = rows.Scan(&s.sequence, &s.table, &s.column); err != nil {
+			return err
+		}
+		sequences = append(sequences, s)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	resetSequencesTo := h.resetSequencesTo
+	if resetSequencesTo == 0 {
+		resetSequencesTo = 10000
+	}
+	for _, s := range sequences {
+		statement := fmt.Sprintf("SELECT SETVAL('%s', %d)", s.sequence, resetSequencesTo)
+		if s.table.Valid && s.column.Valid {
+			statement = fmt.Sprintf(
+				"SELECT SETVAL('%s', COALESCE(MAX(%s), 0) + 1, false) FROM %s",
+				s.sequence,
+				s.column.String,
+				s.table.String,
+			)
+		}
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (h *postgreSQL) isTableModified(q queryable, tableName string) (bool, error) {
 	checksum, err := h.getChecksum(q, tableName)
 	if err != nil {
@@ -376,10 +554,182 @@ func (h *postgreSQL) getChecksum(q queryable, tableName string) (string, error)
 	return checksum.String, nil
 }
 
+// cleanTableSQL is a tableCleaner interface implementation. Hypertables are
+// truncated, which TimescaleDB handles by dropping their chunks at once
+// instead of deleting rows chunk by chunk.
+func (h *postgreSQL) cleanTableSQL(tableName string) string {
+	if h.isHypertable(tableName) {
+		return fmt.Sprintf("TRUNCATE TABLE %s", h.quoteKeyword(tableName))
+	}
+	return fmt.Sprintf("DELETE FROM %s", h.quoteKeyword(tableName))
+}
+
+// upsertClause is an upserter interface implementation.
+func (*postgreSQL) upsertClause(keys, columns []string) string {
+	return onConflictClause(keys, columns)
+}
+
 func (*postgreSQL) quoteKeyword(s string) string {
 	parts := strings.Split(s, ".")
 	for i, p := range parts {
-		parts[i] = fmt.Sprintf(`"%s"`, p)
+		parts[i] = fmt.Sprintf(`"%s"`, strings.ReplaceAll(p, `"`, `""`))
 	}
 	return strings.Join(parts, ".")
 }
+
+// splitStatements is a statementSplitter interface implementation. Function
+// bodies are usually dollar-quoted and contain semicolons, so dollar quotes
+// are kept intact.
+func (*postgreSQL) splitStatements(schema string) []string {
+	return splitSQLStatements(schema, sqlSplitOptions{dollarQuotes: true, nestedComments: true})
+}
+
+// insertedIDClauses is an insertedIDHelper interface implementation.
+func (*postgreSQL) insertedIDClauses(column string) (string, string) {
+	return "", " RETURNING " + column
+}
+
+// typedValue is a columnTyper interface implementation. Lists are converted
+// to arrays.
+func (*postgreSQL) typedValue(value interface{}, columnType string) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok || !strings.HasSuffix(columnType, "[]") {
+		return nil, fmt.Errorf(`testfixtures: only lists in array columns are supported by ColumnType on PostgreSQL, not type "%s"`, columnType)
+	}
+	return pgArrayLiteral(list)
+}
+
+// pgArrayLiteral converts a list to a PostgreSQL array literal, like
+// `{"a","b"}`, to be cast to the array type. See ColumnType.
+func pgArrayLiteral(values []interface{}) (string, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch v := value.(type) {
+		case nil:
+			b.WriteString("NULL")
+		case []interface{}:
+			nested, err := pgArrayLiteral(v)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(nested)
+		case map[interface{}]interface{}:
+			s, err := toJSONString(v)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(pgQuoteArrayElement(s))
+		case time.Time:
+			b.WriteString(pgQuoteArrayElement(v.Format(time.RFC3339Nano)))
+		default:
+			b.WriteString(pgQuoteArrayElement(fmt.Sprint(v)))
+		}
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+func pgQuoteArrayElement(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// foreignKeys is a foreignKeyLister interface implementation.
+func (*postgreSQL) foreignKeys(q queryable) ([]foreignKey, error) {
+	const query = `
+		SELECT conrelid::regclass::text, confrelid::regclass::text
+		FROM pg_constraint
+		WHERE contype = 'f'
+	`
+	return queryForeignKeys(q, query)
+}
+
+// castPlaceholder is a caster interface implementation.
+func (*postgreSQL) castPlaceholder(placeholder, castExpr string) string {
+	return placeholder + "::" + castExpr
+}
+
+// columnCasts is a castLister interface implementation. Enums and other
+// user-defined types are cast to their name.
+func (*postgreSQL) columnCasts(q queryable, tableName string) (yaml.MapSlice, error) {
+	const query = `
+		SELECT column_name
+			,CASE WHEN data_type = 'USER-DEFINED' THEN udt_name ELSE data_type END
+		FROM information_schema.columns
+		WHERE (table_schema || '.' || table_name = $1 OR (table_name = $1 AND table_schema = current_schema()))
+		  AND (data_type IN ('uuid', 'json', 'jsonb', 'inet', 'cidr', 'macaddr') OR data_type = 'USER-DEFINED')
+		ORDER BY ordinal_position
+	`
+	rows, err := q.Query(query, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var casts yaml.MapSlice
+	for rows.Next() {
+		var column, cast string
+		if err = rows.Scan(&column, &cast); err != nil {
+			return nil, err
+		}
+		casts = append(casts, yaml.MapItem{Key: column, Value: cast})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return casts, nil
+}
+
+// copySQL is a copier interface implementation.
+func (h *postgreSQL) copySQL(tableName string, columns []string) string {
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN", h.quoteKeyword(tableName), strings.Join(columns, ", "))
+}
+
+// analyzeTableSQL is an analyzer interface implementation.
+func (h *postgreSQL) analyzeTableSQL(tableName string) string {
+	return fmt.Sprintf("ANALYZE %s", h.quoteKeyword(tableName))
+}
+
+// createFromTemplate is a templater interface implementation.
+func (h *postgreSQL) createFromTemplate(q queryable, database, template string) error {
+	const terminateSQL = `
+		SELECT pg_terminate_backend(pid)
+		FROM pg_stat_activity
+		WHERE datname IN ($1, $2) AND pid <> pg_backend_pid()
+	`
+	if _, err := q.Exec(terminateSQL, database, template); err != nil {
+		return err
+	}
+	for _, statement := range []string{
+		fmt.Sprintf("DROP DATABASE IF EXISTS %s", h.quoteKeyword(database)),
+		fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", h.quoteKeyword(database), h.quoteKeyword(template)),
+	} {
+		if _, err := q.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generatedColumns is a generatedColumnLister interface implementation. A
+// table is found by its name without the schema if the name resolves to it
+// in the search path, like in the inserts.
+func (*postgreSQL) generatedColumns(q queryable) (*generatedColumns, error) {
+	const query = `
+		SELECT
+			table_schema,
+			table_name,
+			column_name,
+			COALESCE(to_regclass(quote_ident(table_name)) = to_regclass(quote_ident(table_schema) || '.' || quote_ident(table_name)), false),
+			is_generated <> 'ALWAYS'
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		  AND (is_generated = 'ALWAYS' OR identity_generation = 'ALWAYS')
+	`
+	return queryGeneratedColumns(q, query)
+}
@@ -3,7 +3,13 @@
 package testfixtures
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
@@ -44,3 +50,797 @@ func TestPostgreSQLWithDropConstraint(t *testing.T) {
 		)
 	}
 }
+
+func TestPostgreSQLWithPerTableTransaction(t *testing.T) {
+	for _, dialect := range []string{"postgres", "pgx"} {
+		testLoader(
+			t,
+			dialect,
+			os.Getenv("PG_CONN_STRING"),
+			"testdata/schema/postgresql.sql",
+			PerTableTransaction(),
+		)
+	}
+}
+
+func TestPostgreSQLWithCopyFrom(t *testing.T) {
+	// pgx doesn't support COPY through database/sql, so the records are
+	// inserted as usual.
+	for _, dialect := range []string{"postgres", "pgx"} {
+		testLoader(
+			t,
+			dialect,
+			os.Getenv("PG_CONN_STRING"),
+			"testdata/schema/postgresql.sql",
+			UseCopyFrom(true),
+		)
+	}
+}
+
+func TestPostgreSQLWithDeferredConstraints(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"authors.yml": "- id: 1\n  name: J. R. R. Tolkien\n",
+		"books.yml":   "- id: 1\n  author_id: 1\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, deferrable := range []bool{true, false} {
+		constraint := "NOT DEFERRABLE"
+		if deferrable {
+			constraint = "DEFERRABLE"
+		}
+		_, err = db.Exec(fmt.Sprintf(`
+			DROP TABLE IF EXISTS books;
+			DROP TABLE IF EXISTS authors;
+			CREATE TABLE authors (id INT PRIMARY KEY, name TEXT NOT NULL);
+			CREATE TABLE books (id INT PRIMARY KEY, author_id INT NOT NULL REFERENCES authors (id) %s);
+		`, constraint))
+		if err != nil {
+			t.Fatalf("cannot create tables: %v", err)
+		}
+
+		// The books are inserted before the authors they reference.
+		l, err := New(
+			Database(db),
+			Dialect("postgres"),
+			UseDeferredConstraints(true),
+			Order("books", "authors"),
+			Directory(dir),
+		)
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		err = l.Load()
+		if deferrable && err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		if !deferrable && err == nil {
+			t.Error("expected the load to fail with a constraint which is not deferrable")
+		}
+	}
+	if _, err := db.Exec("DROP TABLE books; DROP TABLE authors"); err != nil {
+		t.Errorf("cannot drop tables: %v", err)
+	}
+}
+
+func TestPostgreSQLWithUUIDFor(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		DROP TABLE IF EXISTS uuid_comments;
+		DROP TABLE IF EXISTS uuid_posts;
+		CREATE TABLE uuid_posts (id UUID PRIMARY KEY, title TEXT NOT NULL);
+		CREATE TABLE uuid_comments (
+			id UUID PRIMARY KEY,
+			post_id UUID NOT NULL REFERENCES uuid_posts (id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("cannot create tables: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE uuid_comments; DROP TABLE uuid_posts"); err != nil {
+			t.Errorf("cannot drop tables: %v", err)
+		}
+	}()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"uuid_posts.yml":    "- id: '{{uuidFor \"posts/first\"}}'\n  title: First\n",
+		"uuid_comments.yml": "- id: '{{uuidFor \"comments/first\"}}'\n  post_id: '{{uuidFor \"posts/first\"}}'\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		Template(),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM uuid_comments JOIN uuid_posts ON uuid_posts.id = uuid_comments.post_id").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected the comment to reference the post, got %d", count)
+	}
+
+	dumpDir := t.TempDir()
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("postgres"),
+		DumpDirectory(dumpDir),
+		DumpTables("uuid_posts"),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dumpDir, "uuid_posts.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "- id: afa9d310-3a8f-56b5-9164-362c84c84368\n  title: First\n"; string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+func TestPostgreSQLWithGeneratedColumns(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		DROP TABLE IF EXISTS products;
+		CREATE TABLE products (
+			id INT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			price INT NOT NULL,
+			total INT GENERATED ALWAYS AS (price * 2) STORED
+		);
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE products"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	// The generated total is left out, and the explicit id is kept.
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "products.yml"), []byte("- id: 42\n  price: 10\n  total: 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	var id, total int
+	if err := db.QueryRow("SELECT id, total FROM products").Scan(&id, &total); err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 || total != 20 {
+		t.Errorf("expected the explicit id and the generated total, got %d and %d", id, total)
+	}
+
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("postgres"),
+		DumpDirectory(dir),
+		DumpTables("products"),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "products.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "- id: 42\n  price: 10\n"; string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	l, err = New(
+		Database(db),
+		Dialect("postgres"),
+		SkipGeneratedColumns(false),
+		Files(filepath.Join(dir, "products.yml")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err == nil {
+		t.Error("expected the explicit id to be rejected without SkipGeneratedColumns")
+	}
+}
+
+func TestPostgreSQLWithResetAllSequences(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	// The tags table has no fixture below.
+	if _, err := db.Exec("INSERT INTO tags (id, name, created_at, updated_at) VALUES (42, 'Go', NOW(), NOW())"); err != nil {
+		t.Fatalf("cannot insert tag: %v", err)
+	}
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		ResetAllSequences(true),
+		Files("testdata/fixtures/posts.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	for _, test := range []struct {
+		sequence string
+		query    string
+	}{
+		{"tags_id_seq", "SELECT MAX(id) + 1 FROM tags"},
+		{"posts_id_seq", "SELECT MAX(id) + 1 FROM posts"},
+		{"users_id_seq", "SELECT 1"},
+	} {
+		var expected, next int64
+		if err := db.QueryRow(test.query).Scan(&expected); err != nil {
+			t.Fatalf("cannot query the expected value of %s: %v", test.sequence, err)
+		}
+		if err := db.QueryRow("SELECT NEXTVAL($1)", test.sequence).Scan(&next); err != nil {
+			t.Fatalf("cannot query the next value of %s: %v", test.sequence, err)
+		}
+		if next != expected {
+			t.Errorf("expected the next value of %s to be %d, got %d", test.sequence, expected, next)
+		}
+	}
+}
+
+func TestPostgreSQLWithTargetSchemas(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	for _, schema := range []string{"tenant_a", "tenant_b"} {
+		_, err := db.Exec(fmt.Sprintf(`
+			DROP SCHEMA IF EXISTS %[1]s CASCADE;
+			CREATE SCHEMA %[1]s;
+			CREATE TABLE %[1]s.tags (id SERIAL PRIMARY KEY, name VARCHAR(255) NOT NULL, created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL);
+		`, schema))
+		if err != nil {
+			t.Fatalf("cannot create schema: %v", err)
+		}
+	}
+	defer func() {
+		if _, err := db.Exec("DROP SCHEMA tenant_a, tenant_b CASCADE"); err != nil {
+			t.Errorf("cannot drop schemas: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		TargetSchemas("tenant_a", "tenant_b"),
+		ResetAllSequences(true),
+		Files("testdata/fixtures/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	for _, schema := range []string{"tenant_a", "tenant_b"} {
+		assertCount(t, l, schema+".tags", 3)
+
+		var next int64
+		if err := db.QueryRow("SELECT NEXTVAL($1)", schema+".tags_id_seq").Scan(&next); err != nil {
+			t.Fatalf("cannot query the next value of the sequence of %s: %v", schema, err)
+		}
+		if next != 4 {
+			t.Errorf("expected the next value of the sequence of %s to be 4, got %d", schema, next)
+		}
+	}
+}
+
+func TestPostgreSQLWithSchema(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		DROP SCHEMA IF EXISTS app CASCADE;
+		CREATE SCHEMA app;
+		CREATE TABLE app.tags (id SERIAL PRIMARY KEY, name VARCHAR(255) NOT NULL, created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL);
+		INSERT INTO app.tags (id, name, created_at, updated_at) VALUES (42, 'Kept', NOW(), NOW());
+	`)
+	if err != nil {
+		t.Fatalf("cannot create schema: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP SCHEMA app CASCADE"); err != nil {
+			t.Errorf("cannot drop schema: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		Schema("app"),
+		DeleteWhere("tags", "id < 42"),
+		Files("testdata/fixtures/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	// The tag kept by DeleteWhere is still there.
+	assertCount(t, l, "app.tags", 4)
+}
+
+func TestPostgreSQLWithTemplate(t *testing.T) {
+	const template = "testfixtures_template_test"
+
+	connString := os.Getenv("PG_CONN_STRING")
+	admin, err := sql.Open("postgres", strings.Replace(connString, "dbname=testfixtures_test", "dbname=postgres", 1))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer admin.Close()
+	defer func() {
+		if _, err := admin.Exec("DROP DATABASE IF EXISTS " + template); err != nil {
+			t.Errorf("cannot drop template: %v", err)
+		}
+	}()
+
+	// The connections to the database are terminated by each call, so it's
+	// opened again after them.
+	open := func() (*sql.DB, *Loader) {
+		db, err := sql.Open("postgres", connString)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		l, err := New(
+			Database(db),
+			Dialect("postgres"),
+			AdminDatabase(admin),
+			Template(),
+			TemplateData(map[string]interface{}{
+				"PostIds": []int{1, 2},
+				"TagIds":  []int{1, 2, 3},
+			}),
+			Directory("testdata/fixtures"),
+		)
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		return db, l
+	}
+
+	db, l := open()
+	if err := l.LoadSchemaFile("testdata/schema/postgresql.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	if err := l.CreateTemplate(template); err != nil {
+		t.Fatalf("cannot create template: %v", err)
+	}
+	db.Close()
+
+	db, l = open()
+	if _, err := db.Exec("DELETE FROM comments"); err != nil {
+		t.Fatalf("cannot delete comments: %v", err)
+	}
+	if err := l.RestoreFromTemplate(template); err != nil {
+		t.Fatalf("cannot restore template: %v", err)
+	}
+	db.Close()
+
+	db, l = open()
+	defer db.Close()
+	assertFixturesLoaded(t, l)
+
+	if err := l.RestoreFromTemplate("production"); !errors.Is(err, ErrNotTestDatabase) {
+		t.Errorf("expected ErrNotTestDatabase for the template name, got %v", err)
+	}
+}
+
+func TestPostgreSQLDumpCasts(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql_enum.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE moods; DROP TYPE mood"); err != nil {
+			t.Errorf("cannot drop enum: %v", err)
+		}
+	}()
+	if _, err := db.Exec("INSERT INTO moods (id, mood) VALUES (1, 'happy')"); err != nil {
+		t.Fatalf("cannot insert mood: %v", err)
+	}
+
+	dir := t.TempDir()
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("postgres"),
+		DumpDirectory(dir),
+		DumpTables("moods"),
+		DumpCasts(),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+
+	// The dumped _casts section makes the enum loadable without ColumnCast.
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "moods", 1)
+}
+
+func TestPostgreSQLWithSearchPath(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql_search_path.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP SCHEMA fixtures_schema CASCADE"); err != nil {
+			t.Errorf("cannot drop schema: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		BeforeLoadSQL("SET LOCAL search_path TO fixtures_schema"),
+		AfterLoadSQL("INSERT INTO settings (id, name) VALUES (3, 'timezone')"),
+		Files("testdata/fixtures_search_path/settings.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "fixtures_schema.settings", 3)
+}
+
+func TestPostgreSQLWithColumnCast(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql_enum.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE moods; DROP TYPE mood"); err != nil {
+			t.Errorf("cannot drop enum: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		ColumnCast("moods", "mood", "text::mood"),
+		Files("testdata/fixtures_enum/moods.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM moods WHERE mood = 'happy'").Scan(&count); err != nil {
+		t.Fatalf("cannot count moods: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 happy mood, got %d", count)
+	}
+}
+
+func TestPostgreSQLWithJSONColumns(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		JSONColumns("users", "attributes"),
+		Files("testdata/fixtures_json/users.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadSchemaFile("testdata/schema/postgresql.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	var city string
+	if err := db.QueryRow("SELECT attributes->'address'->>'city' FROM users WHERE id = 1").Scan(&city); err != nil {
+		t.Fatalf("cannot query nested object: %v", err)
+	}
+	if city != "Paris" {
+		t.Errorf(`expected city "Paris", got "%s"`, city)
+	}
+
+	var attributes string
+	if err := db.QueryRow("SELECT attributes #>> '{}' FROM users WHERE id = 2").Scan(&attributes); err != nil {
+		t.Fatalf("cannot query JSON string: %v", err)
+	}
+	if attributes != "guest" {
+		t.Errorf(`expected "guest", got "%s"`, attributes)
+	}
+}
+
+func TestPostgreSQLWithCaseSensitiveTable(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE SCHEMA IF NOT EXISTS sales;
+		CREATE TABLE sales."Order-Items" (id INT PRIMARY KEY, name TEXT NOT NULL);
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP SCHEMA sales CASCADE"); err != nil {
+			t.Errorf("cannot drop schema: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		Directory("testdata/fixtures_quoted"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "sales.Order-Items", 2)
+}
+
+func TestPostgreSQLWithArrays(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE articles (id INT PRIMARY KEY, tags TEXT[] NOT NULL, scores INT[] NOT NULL)"); err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE articles"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		ColumnType("articles", "tags", "text[]"),
+		ColumnType("articles", "scores", "int[]"),
+		Files("testdata/fixtures_arrays/articles.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	var (
+		tags, thirdTag string
+		scoresSum      int
+	)
+	query := "SELECT array_to_string(tags, '|'), tags[3], (SELECT SUM(s) FROM unnest(scores) s) FROM articles WHERE id = 1"
+	if err := db.QueryRow(query).Scan(&tags, &thirdTag, &scoresSum); err != nil {
+		t.Fatalf("cannot query arrays: %v", err)
+	}
+	if expected := `go|with, comma|with "quotes"`; tags != expected {
+		t.Errorf(`expected tags "%s", got "%s"`, expected, tags)
+	}
+	if expected := `with "quotes"`; thirdTag != expected {
+		t.Errorf(`expected third tag "%s", got "%s"`, expected, thirdTag)
+	}
+	if scoresSum != 6 {
+		t.Errorf("expected scores sum 6, got %d", scoresSum)
+	}
+}
+
+func TestPostgreSQLWithInterval(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql_interval.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE durations"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("postgres"),
+		ColumnCast("durations", "value", "text::interval"),
+		Files("testdata/fixtures_interval/durations.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	rows, err := db.Query("SELECT EXTRACT(EPOCH FROM value)::integer FROM durations ORDER BY id")
+	if err != nil {
+		t.Fatalf("cannot query durations: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var seconds int
+		if err := rows.Scan(&seconds); err != nil {
+			t.Fatalf("cannot scan duration: %v", err)
+		}
+		if seconds != 5400 {
+			t.Errorf("expected 5400 seconds, got %d", seconds)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Errorf("cannot read durations: %v", err)
+	}
+}
+
+func BenchmarkPostgreSQLLoadWithCopyFrom(b *testing.B) {
+	db, err := sql.Open("postgres", os.Getenv("PG_CONN_STRING"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("postgres"))
+	if err != nil {
+		b.Fatalf("failed to create loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/postgresql.sql"); err != nil {
+		b.Fatalf("failed to load schema: %v", err)
+	}
+
+	for _, useCopyFrom := range []bool{false, true} {
+		l, err := New(
+			Database(db),
+			Dialect("postgres"),
+			UseCopyFrom(useCopyFrom),
+			Files("testdata/fixtures_copy/tags.yml"),
+		)
+		if err != nil {
+			b.Fatalf("failed to create loader: %v", err)
+		}
+
+		name := "Insert"
+		if useCopyFrom {
+			name = "CopyFrom"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := l.Load(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package testfixtures
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ProgressPhase is the phase of the load a ProgressEvent refers to.
+type ProgressPhase string
+
+const (
+	// ProgressCleanup is reported after a table is cleaned.
+	ProgressCleanup ProgressPhase = "cleanup"
+	// ProgressInsert is reported after the records of a table are inserted,
+	// and every N records if ProgressEveryRows was given.
+	ProgressInsert ProgressPhase = "insert"
+)
+
+// ProgressEvent is sent to the function given to Progress while loading.
+type ProgressEvent struct {
+	Phase ProgressPhase
+	Table string
+	// RowsDone is the number of records inserted so far, on all tables.
+	RowsDone int
+	// TotalRows is the number of records to be inserted, on all tables.
+	// Tables not modified since the last load are not counted.
+	TotalRows int
+	// Elapsed is the time since the load started.
+	Elapsed time.Duration
+}
+
+// Progress sets a function to be called to report the progress of Load,
+// at least once per table cleaned and once per table filled. It's called
+// from the goroutine running Load, or, with Concurrency, from the ones
+// loading the tables, though never at the same time.
+func Progress(fn func(ProgressEvent)) func(*Loader) error {
+	return func(l *Loader) error {
+		l.progress = fn
+		return nil
+	}
+}
+
+// ProgressEveryRows makes Loader also report the progress every given
+// number of records inserted. See Progress.
+func ProgressEveryRows(rows int) func(*Loader) error {
+	return func(l *Loader) error {
+		if rows <= 0 {
+			return fmt.Errorf("testfixtures: ProgressEveryRows must be positive, got %d", rows)
+		}
+		l.progressEveryRows = rows
+		return nil
+	}
+}
+
+// progressTracker reports the progress of a load. All methods are no-ops
+// on a nil tracker, which is used when no Progress function was given.
+type progressTracker struct {
+	// mu guards the counters and the calls to fn when loading concurrently.
+	mu sync.Mutex
+
+	fn        func(ProgressEvent)
+	everyRows int
+	start     time.Time
+	rowsDone  int
+	totalRows int
+}
+
+func (l *Loader) newProgressTracker(files []*fixtureFile) *progressTracker {
+	if l.progress == nil {
+		return nil
+	}
+	p := &progressTracker{
+		fn:        l.progress,
+		everyRows: l.progressEveryRows,
+		start:     time.Now(),
+	}
+	for _, file := range files {
+		p.totalRows += len(file.insertSQLs)
+	}
+	return p
+}
+
+func (p *progressTracker) skipped(file *fixtureFile) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.totalRows -= len(file.insertSQLs)
+}
+
+func (p *progressTracker) cleaned(table string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.report(ProgressCleanup, table)
+}
+
+func (p *progressTracker) rowInserted(table string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.rowsDone++
+	if p.everyRows > 0 && p.rowsDone%p.everyRows == 0 {
+		p.report(ProgressInsert, table)
+	}
+}
+
+func (p *progressTracker) tableInserted(table string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.report(ProgressInsert, table)
+}
+
+func (p *progressTracker) report(phase ProgressPhase, table string) {
+	p.fn(ProgressEvent{
+		Phase:     phase,
+		Table:     table,
+		RowsDone:  p.rowsDone,
+		TotalRows: p.totalRows,
+		Elapsed:   time.Since(p.start),
+	})
+}
@@ -0,0 +1,362 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// defaultsKey is a special key of fixture files whose values are merged
+	// into every record of the file, unless the record overrides them.
+	defaultsKey = "_defaults"
+
+	// castsKey is a special key of fixture files mapping columns to the
+	// type their values are cast to, like ColumnCast.
+	castsKey = "_casts"
+
+	// labelKey is a special key of records that sets their label, overriding
+	// the map key of records declared in the map form.
+	labelKey = "_label"
+
+	// tagsKey is a special key of records listing their tags. See LoadTags.
+	tagsKey = "_tags"
+
+	// extendsKey is a special key of records giving the label of a record,
+	// of the same table or qualified by its table, like "users.admin", whose
+	// values are merged into theirs, unless they have them.
+	extendsKey = "_extends"
+
+	// repeatKey is a special key of records that makes them be inserted
+	// the given number of times. In string values of repeated records,
+	// indexToken is replaced by the index of the copy (starting at 1), and
+	// seqToken (optionally followed by ":start") by an incrementing integer.
+	repeatKey  = "_repeat"
+	indexToken = "@index"
+	seqToken   = "@seq"
+)
+
+// fixtureRecord is a record declared in a fixture file. The label is only
+// set for records declared in the map form or having a _label key.
+type fixtureRecord struct {
+	label  string
+	tags   []string
+	values interface{}
+
+	// casts are the casts of the file, shared by all its records.
+	casts map[string]string
+
+	// defaulted are the keys of the values only set by the _defaults of the
+	// file, which don't override the values of an extended record.
+	defaulted map[interface{}]bool
+}
+
+// expand returns the values of the record, repeated as many times as asked by
+// its _repeat key, or just once if not given.
+func (r fixtureRecord) expand() ([]map[interface{}]interface{}, error) {
+	values, ok := r.values.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("testfixtures: could not cast record: not a map[interface{}]interface{}")
+	}
+
+	repeatValue, ok := values[repeatKey]
+	if !ok {
+		return []map[interface{}]interface{}{values}, nil
+	}
+	if r.label != "" {
+		return nil, fmt.Errorf(`testfixtures: %s is not allowed on labeled records, found on record "%s"`, repeatKey, r.label)
+	}
+	repeat, ok := repeatValue.(int)
+	if !ok || repeat < 0 {
+		return nil, fmt.Errorf("testfixtures: %s must be a non-negative integer", repeatKey)
+	}
+
+	expanded := make([]map[interface{}]interface{}, 0, repeat)
+	for i := 0; i < repeat; i++ {
+		copied := make(map[interface{}]interface{}, len(values)-1)
+		for key, value := range values {
+			if key == repeatKey {
+				continue
+			}
+			if str, ok := value.(string); ok {
+				v, err := expandRepeatedValue(str, i)
+				if err != nil {
+					return nil, err
+				}
+				value = v
+			}
+			copied[key] = value
+		}
+		expanded = append(expanded, copied)
+	}
+	return expanded, nil
+}
+
+// expandRepeatedValue replaces the tokens of a string value for the copy
+// with the given zero-based index.
+func expandRepeatedValue(value string, i int) (interface{}, error) {
+	if value == seqToken {
+		return i + 1, nil
+	}
+	if strings.HasPrefix(value, seqToken+":") {
+		start, err := strconv.Atoi(strings.TrimPrefix(value, seqToken+":"))
+		if err != nil {
+			return nil, fmt.Errorf(`testfixtures: invalid sequence start on "%s": %w`, value, err)
+		}
+		return start + i, nil
+	}
+	return strings.ReplaceAll(value, indexToken, strconv.Itoa(i+1)), nil
+}
+
+// fixtureRecords are the records of a fixture, in the order they were
+// declared on the file, even for fixtures declared in the map form.
+type fixtureRecords []fixtureRecord
+
+func (r *fixtureRecords) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var records interface{}
+	if err := unmarshal(&records); err != nil {
+		return err
+	}
+
+	var (
+		defaults, casts interface{}
+		hasDefaults     bool
+	)
+	switch records := records.(type) {
+	case nil:
+		// empty file or table without records
+		return nil
+	case []interface{}:
+		for _, record := range records {
+			if m, ok := record.(map[interface{}]interface{}); ok && len(m) == 1 {
+				if value, ok := m[defaultsKey]; ok {
+					defaults, hasDefaults = value, true
+					continue
+				}
+			}
+			if m, ok := record.(map[interface{}]interface{}); ok && len(m) == 1 && m[castsKey] != nil {
+				casts = m[castsKey]
+				continue
+			}
+			*r = append(*r, fixtureRecord{values: record})
+		}
+	case map[interface{}]interface{}:
+		// Decode again as a yaml.MapSlice to know the order of the records.
+		var items yaml.MapSlice
+		if err := unmarshal(&items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.Key == defaultsKey {
+				defaults, hasDefaults = records[item.Key], true
+				continue
+			}
+			if item.Key == castsKey {
+				casts = records[item.Key]
+				continue
+			}
+			*r = append(*r, fixtureRecord{label: fmt.Sprint(item.Key), values: records[item.Key]})
+		}
+	default:
+		return fmt.Errorf("testfixtures: fixture is not a slice or map")
+	}
+
+	if hasDefaults {
+		if err := r.applyDefaults(defaults); err != nil {
+			return err
+		}
+	}
+	if casts != nil {
+		if err := r.applyCasts(casts); err != nil {
+			return err
+		}
+	}
+	if err := r.applyLabels(); err != nil {
+		return err
+	}
+	return r.applyTags()
+}
+
+// applyCasts sets the given casts on every record.
+func (r fixtureRecords) applyCasts(casts interface{}) error {
+	castValues, ok := casts.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("testfixtures: %s is not a map", castsKey)
+	}
+
+	columnCasts := make(map[string]string, len(castValues))
+	for column, cast := range castValues {
+		columnStr, ok := column.(string)
+		castStr, ok2 := cast.(string)
+		if !ok || !ok2 || castStr == "" {
+			return fmt.Errorf("testfixtures: %s must map column names to types", castsKey)
+		}
+		columnCasts[columnStr] = castStr
+	}
+	for i := range r {
+		r[i].casts = columnCasts
+	}
+	return nil
+}
+
+// casts returns the casts given in the _casts section of the file.
+func (r fixtureRecords) casts() map[string]string {
+	if len(r) == 0 {
+		return nil
+	}
+	return r[0].casts
+}
+
+// applyLabels sets the label of the records having a _label key, which is
+// removed from their values.
+func (r fixtureRecords) applyLabels() error {
+	for i, record := range r {
+		values, ok := record.values.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		label, ok := values[labelKey]
+		if !ok {
+			continue
+		}
+		labelStr, ok := label.(string)
+		if !ok || labelStr == "" {
+			return fmt.Errorf("testfixtures: %s must be a non-empty string", labelKey)
+		}
+		copied := make(map[interface{}]interface{}, len(values)-1)
+		for key, value := range values {
+			if key != labelKey {
+				copied[key] = value
+			}
+		}
+		r[i].label = labelStr
+		r[i].values = copied
+	}
+	return nil
+}
+
+// applyDefaults merges the given defaults into every record, keeping the
+// values the records already have.
+func (r fixtureRecords) applyDefaults(defaults interface{}) error {
+	defaultValues, ok := defaults.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("testfixtures: %s is not a map", defaultsKey)
+	}
+
+	for i, record := range r {
+		values, ok := record.values.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		merged := make(map[interface{}]interface{}, len(defaultValues)+len(values))
+		for key, value := range defaultValues {
+			merged[key] = value
+		}
+		for key, value := range values {
+			merged[key] = value
+		}
+		if _, ok := values[extendsKey]; ok {
+			r[i].defaulted = make(map[interface{}]bool, len(defaultValues))
+			for key := range defaultValues {
+				if _, ok := values[key]; !ok {
+					r[i].defaulted[key] = true
+				}
+			}
+		}
+		r[i].values = merged
+	}
+	return nil
+}
+
+// applyTags sets the tags of the records having a _tags key, which is removed
+// from their values.
+func (r fixtureRecords) applyTags() error {
+	for i, record := range r {
+		values, ok := record.values.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		tagsValue, ok := values[tagsKey]
+		if !ok {
+			continue
+		}
+		list, ok := tagsValue.([]interface{})
+		if !ok {
+			return fmt.Errorf("testfixtures: %s must be a list of strings", tagsKey)
+		}
+		tags := make([]string, 0, len(list))
+		for _, tag := range list {
+			tagStr, ok := tag.(string)
+			if !ok {
+				return fmt.Errorf("testfixtures: %s must be a list of strings", tagsKey)
+			}
+			tags = append(tags, tagStr)
+		}
+		copied := make(map[interface{}]interface{}, len(values)-1)
+		for key, value := range values {
+			if key != tagsKey {
+				copied[key] = value
+			}
+		}
+		r[i].tags = tags
+		r[i].values = copied
+	}
+	return nil
+}
+
+// hasAnyTag reports whether the record has no tags or any of the given ones.
+func (r fixtureRecord) hasAnyTag(tags []string) bool {
+	if len(r.tags) == 0 {
+		return true
+	}
+	for _, tag := range r.tags {
+		if containsString(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// fixtureTable is a table declared in a file given to FilesMultiTables.
+type fixtureTable struct {
+	name    string
+	records fixtureRecords
+}
+
+// fixtureTables are the tables declared in a file given to FilesMultiTables,
+// in the order they were declared.
+type fixtureTables []fixtureTable
+
+func (t *fixtureTables) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tables interface{}
+	if err := unmarshal(&tables); err != nil {
+		return err
+	}
+	switch tables.(type) {
+	case nil:
+		return nil
+	case map[interface{}]interface{}:
+	default:
+		return fmt.Errorf("testfixtures: could not cast tables: not a map[interface{}]interface{}")
+	}
+
+	var items yaml.MapSlice
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	var records map[string]fixtureRecords
+	if err := unmarshal(&records); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		name, ok := item.Key.(string)
+		if !ok {
+			return fmt.Errorf("testfixtures: could not cast tableName: not a string")
+		}
+		*t = append(*t, fixtureTable{name: name, records: records[name]})
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package testfixtures
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RequireReferencedTables makes Load return an error, before changing the
+// database, when a table getting records references, with a foreign key,
+// a table not getting any, like when its fixture file is missing or when
+// LoadTags filters out all of its records. The error lists the referenced
+// tables and the ones referencing them.
+//
+// Only supported on PostgreSQL, MySQL, SQLite and SQL Server.
+func RequireReferencedTables() func(*Loader) error {
+	return func(l *Loader) error {
+		l.requireReferencedTables = true
+		return nil
+	}
+}
+
+// checkReferencedTables returns an error if a table of the files having
+// records references a table without any. See RequireReferencedTables.
+func (l *Loader) checkReferencedTables(files []*fixtureFile) error {
+	keys, err := l.helper.(foreignKeyLister).foreignKeys(l.db)
+	if err != nil {
+		return fmt.Errorf("testfixtures: could not list the foreign keys: %w", err)
+	}
+
+	loaded := make(map[string]bool, len(files))
+	for _, file := range files {
+		if len(file.insertSQLs) > 0 {
+			loaded[unqualifiedTableName(file.fileNameWithoutExtension())] = true
+		}
+	}
+
+	referencedBy := make(map[string][]string)
+	for _, key := range keys {
+		table, referenced := unqualifiedTableName(key.table), unqualifiedTableName(key.referencedTable)
+		if !loaded[table] || loaded[referenced] || table == referenced {
+			continue
+		}
+		if !containsString(referencedBy[referenced], table) {
+			referencedBy[referenced] = append(referencedBy[referenced], table)
+		}
+	}
+	if len(referencedBy) == 0 {
+		return nil
+	}
+
+	missing := make([]string, 0, len(referencedBy))
+	for referenced, tables := range referencedBy {
+		sort.Strings(tables)
+		missing = append(missing, fmt.Sprintf("%s (referenced by %s)", referenced, strings.Join(tables, ", ")))
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("testfixtures: tables without fixtures are referenced by tables with fixtures: %s", strings.Join(missing, "; "))
+}
@@ -0,0 +1,140 @@
+package testfixtures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// Helper implements the database specific parts of loading fixtures, for
+// dialects not supported by this package. See RegisterHelper.
+type Helper interface {
+	// Placeholder returns the placeholder of the i-th (starting at 1) param
+	// of a query, like "?" or "$1".
+	Placeholder(i int) string
+
+	// QuoteKeyword quotes a table or column name.
+	QuoteKeyword(name string) string
+
+	// DatabaseName returns the name of the database, which is checked to
+	// contain "test" unless DangerousSkipTestDatabaseCheck is given.
+	DatabaseName(q Queryer) (string, error)
+
+	// TableNames returns the names of the tables of the database. It's used
+	// by Dumper.
+	TableNames(q Queryer) ([]string, error)
+
+	// DisableReferentialIntegrity is called at the start of the transaction
+	// used to load the fixtures, to disable (or defer) the foreign keys
+	// checks until it's committed.
+	DisableReferentialIntegrity(tx *sql.Tx) error
+}
+
+var (
+	customHelpersMu sync.RWMutex
+	customHelpers   = make(map[string]func() Helper)
+)
+
+// RegisterHelper makes a dialect available to the Dialect, DumpDialect and
+// SplitStatements functions, calling the factory to create its Helper. It
+// takes precedence over the dialects supported by this package. It's usually
+// called from an init function:
+//
+//     func init() {
+//             testfixtures.RegisterHelper("mydb", func() testfixtures.Helper {
+//                     return &myDBHelper{}
+//             })
+//     }
+func RegisterHelper(dialect string, factory func() Helper) {
+	customHelpersMu.Lock()
+	defer customHelpersMu.Unlock()
+
+	if factory == nil {
+		delete(customHelpers, dialect)
+		return
+	}
+	customHelpers[dialect] = factory
+}
+
+func customHelperForDialect(dialect string) (helper, bool) {
+	customHelpersMu.RLock()
+	factory, ok := customHelpers[dialect]
+	customHelpersMu.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+	return &customHelper{h: factory()}, true
+}
+
+// customHelper makes a Helper satisfy the helper interface.
+type customHelper struct {
+	baseHelper
+
+	h Helper
+}
+
+func (c *customHelper) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = c.h.DisableReferentialIntegrity(tx); err != nil {
+		return fmt.Errorf("testfixtures: could not disable referential integrity: %w", err)
+	}
+	if err = loadFn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (*customHelper) paramType() int {
+	return paramTypeCustom
+}
+
+func (c *customHelper) placeholder(i int) string {
+	return c.h.Placeholder(i)
+}
+
+func (c *customHelper) quoteKeyword(name string) string {
+	return c.h.QuoteKeyword(name)
+}
+
+func (c *customHelper) databaseName(q queryable) (string, error) {
+	return c.h.DatabaseName(contextQueryable{q})
+}
+
+func (c *customHelper) tableNames(q queryable) ([]string, error) {
+	return c.h.TableNames(contextQueryable{q})
+}
+
+// castPlaceholder is a caster interface implementation, keeping the "::"
+// syntax for the dialects that may support it.
+func (*customHelper) castPlaceholder(placeholder, castExpr string) string {
+	return placeholder + "::" + castExpr
+}
+
+// contextQueryable makes a queryable satisfy the Queryer interface. The
+// contexts are ignored.
+type contextQueryable struct {
+	q queryable
+}
+
+func (c contextQueryable) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.q.Exec(query, args...)
+}
+
+func (c contextQueryable) QueryContext(_ context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.q.Query(query, args...)
+}
+
+func (c contextQueryable) QueryRowContext(_ context.Context, query string, args ...interface{}) *sql.Row {
+	return c.q.QueryRow(query, args...)
+}
@@ -0,0 +1,63 @@
+package testfixtures
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// errRolledBack makes the dialect roll back the transaction of a load
+// started by LoadAndRollback.
+var errRolledBack = errors.New("testfixtures: fixtures rolled back")
+
+// LoadAndRollback loads all fixtures in a transaction, calls fn with it and
+// always rolls it back, so nothing persists after it returns:
+//
+//	err := fixtures.LoadAndRollback(func(tx *sql.Tx) error {
+//	        ...
+//	})
+//
+// fn is not called when the load fails, and its error is returned. As the
+// transaction is never committed, deferred foreign keys are not checked, and
+// sequences reset after the load keep their new values.
+//
+// The fixtures must be loaded in a single transaction, so it can't be used
+// together with PerTableTransaction, with more than one schema given with
+// TargetSchemas, or on dialects loading in chunks, like Cloud Spanner.
+func (l *Loader) LoadAndRollback(fn func(tx *sql.Tx) error) error {
+	if fn == nil {
+		return fmt.Errorf("testfixtures: LoadAndRollback requires a function")
+	}
+	switch {
+	case l.perTableTransaction:
+		return fmt.Errorf("testfixtures: LoadAndRollback can't be used together with PerTableTransaction")
+	case len(l.schemas()) > 1:
+		return fmt.Errorf("testfixtures: LoadAndRollback can't be used together with more than one schema")
+	case l.limitsMutations():
+		return fmt.Errorf("testfixtures: LoadAndRollback is not supported by this dialect")
+	}
+
+	l.rollbackFn = fn
+	defer func() { l.rollbackFn = nil }()
+
+	_, err := l.load(l.fixturesFiles)
+	if errors.Is(err, errRolledBack) {
+		return nil
+	}
+	return err
+}
+
+// rollback calls the function given to LoadAndRollback, if any, with the
+// transaction of the load, and then makes it roll back.
+func (l *Loader) rollback(tx *sql.Tx, result *LoadResult) error {
+	if l.rollbackFn == nil {
+		return nil
+	}
+	if result.fileErrors != nil {
+		return result.fileErrors
+	}
+	if err := l.rollbackFn(tx); err != nil {
+		return err
+	}
+	return errRolledBack
+}
@@ -0,0 +1,106 @@
+package testfixtures
+
+import (
+	"fmt"
+)
+
+// Scenarios declares named groups of fixture files, loaded on top of the
+// other fixtures with LoadScenario:
+//
+//     testfixtures.Scenarios(map[string][]string{
+//             "checkout": {"testdata/scenarios/carts.yml", "testdata/scenarios/orders.yml"},
+//     })
+//
+// A scenario file replaces the other fixtures of the same table, unless
+// AppendScenarioFiles is given.
+//
+// Can be given more than once.
+func Scenarios(scenarios map[string][]string) func(*Loader) error {
+	return func(l *Loader) error {
+		if l.scenarioPaths == nil {
+			l.scenarioPaths = make(map[string][]string, len(scenarios))
+		}
+		for name, paths := range scenarios {
+			l.scenarioPaths[name] = paths
+		}
+		return l.readScenarios()
+	}
+}
+
+// AppendScenarioFiles makes LoadScenario load the scenario files together
+// with the other fixtures of the same table, instead of replacing them.
+func AppendScenarioFiles() func(*Loader) error {
+	return func(l *Loader) error {
+		l.appendScenarioFiles = true
+		return nil
+	}
+}
+
+// readScenarios reads the files of the scenarios.
+func (l *Loader) readScenarios() error {
+	if len(l.scenarioPaths) == 0 {
+		return nil
+	}
+
+	scenarios := make(map[string][]*fixtureFile, len(l.scenarioPaths))
+	for name, paths := range l.scenarioPaths {
+		files, err := l.fixturesFromFiles(paths...)
+		if err != nil {
+			return fmt.Errorf(`testfixtures: could not read scenario "%s": %w`, name, err)
+		}
+		markPartFiles(files)
+		scenarios[name] = files
+	}
+	l.scenarios = scenarios
+	return nil
+}
+
+// LoadScenario works like Load, but also loads the files of the given
+// scenario, in the same transaction. See Scenarios.
+func (l *Loader) LoadScenario(name string) error {
+	files, err := l.scenarioFiles(name)
+	if err != nil {
+		return err
+	}
+	_, err = l.load(files)
+	return err
+}
+
+// scenarioFiles returns the fixture files to load for a scenario.
+func (l *Loader) scenarioFiles(name string) ([]*fixtureFile, error) {
+	scenario, ok := l.scenarios[name]
+	if !ok {
+		return nil, fmt.Errorf(`testfixtures: unknown scenario "%s"`, name)
+	}
+
+	files := make([]*fixtureFile, 0, len(l.fixturesFiles)+len(scenario))
+	if l.appendScenarioFiles {
+		files = append(files, l.fixturesFiles...)
+		return append(files, scenario...), nil
+	}
+
+	var (
+		scenarioTables = filesByTable(scenario)
+		replaced       = make(map[string]bool, len(scenarioTables))
+	)
+	for _, file := range l.fixturesFiles {
+		tableName := file.fileNameWithoutExtension()
+		tableFiles, ok := scenarioTables[tableName]
+		if !ok {
+			files = append(files, file)
+			continue
+		}
+		// Keep the position of the replaced file, which may matter for
+		// foreign keys.
+		if !replaced[tableName] {
+			files = append(files, tableFiles...)
+			replaced[tableName] = true
+		}
+	}
+	for _, file := range scenario {
+		if !replaced[file.fileNameWithoutExtension()] {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
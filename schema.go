@@ -0,0 +1,248 @@
+package testfixtures
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+const schemaSnippetLength = 80
+
+var (
+	dollarQuoteRegexp = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+	whitespaceRegexp  = regexp.MustCompile(`\s+`)
+)
+
+// LoadSchemaFile executes the SQL file at the given path in the database,
+// usually to create the tables before loading fixtures. It's checked
+// that the database is a test database, unless DangerousSkipTestDatabaseCheck
+// was given.
+//
+// The file is split in a way appropriate to the dialect, see SplitStatements.
+func (l *Loader) LoadSchemaFile(path string) error {
+	if !l.skipTestDatabaseCheck {
+		if err := l.EnsureTestDatabase(); err != nil {
+			return err
+		}
+	}
+
+	schema, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf(`testfixtures: could not read schema file "%s": %w`, path, err)
+	}
+
+	for i, statement := range splitSchema(l.helper, schema) {
+		if _, err := l.db.Exec(statement); err != nil {
+			return &SchemaError{
+				Err:       err,
+				File:      path,
+				Index:     i,
+				Statement: statement,
+			}
+		}
+	}
+	return nil
+}
+
+// SplitStatements splits a SQL script in the statements (or batches) to be
+// executed one by one, the same way LoadSchemaFile does for the given
+// dialect:
+//
+//   - SQL Server: on lines with only "GO";
+//   - MySQL: on semicolons, or on the delimiter set with "DELIMITER";
+//   - PostgreSQL: on semicolons, keeping dollar-quoted strings intact;
+//   - SQLite: not split at all.
+//
+// Delimiters inside strings, quoted identifiers and comments are ignored.
+func SplitStatements(dialect string, script []byte) ([][]byte, error) {
+	h, err := helperForDialect(dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements [][]byte
+	for _, statement := range splitSchema(h, script) {
+		statements = append(statements, []byte(statement))
+	}
+	return statements, nil
+}
+
+func splitSchema(h helper, schema []byte) []string {
+	if s, ok := h.(statementSplitter); ok {
+		return s.splitStatements(string(schema))
+	}
+	if len(bytes.TrimSpace(schema)) == 0 {
+		return nil
+	}
+	return []string{string(schema)}
+}
+
+// SchemaError will be returned if any statement of a schema file
+// fails to execute.
+type SchemaError struct {
+	Err       error
+	File      string
+	Index     int
+	Statement string
+}
+
+func (e *SchemaError) Error() string {
+	snippet := strings.TrimSpace(whitespaceRegexp.ReplaceAllString(e.Statement, " "))
+	if len(snippet) > schemaSnippetLength {
+		snippet = snippet[:schemaSnippetLength] + "..."
+	}
+	return fmt.Sprintf(
+		`testfixtures: could not execute statement %d of schema file "%s": %v, statement: %s`,
+		e.Index,
+		e.File,
+		e.Err,
+		snippet,
+	)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+type sqlSplitOptions struct {
+	// dollarQuotes keeps PostgreSQL dollar-quoted strings ($$...$$ or
+	// $tag$...$tag$) intact.
+	dollarQuotes bool
+	// backslashEscapes allows escaping quotes with a backslash inside
+	// strings, as MySQL does.
+	backslashEscapes bool
+	// hashComments treats "#" as the start of a line comment, as MySQL does.
+	hashComments bool
+	// nestedComments allows block comments to be nested, as PostgreSQL and
+	// SQL Server do.
+	nestedComments bool
+	// delimiterCommand handles the "DELIMITER" command of the MySQL client,
+	// which changes the statement delimiter.
+	delimiterCommand bool
+	// goBatches splits on lines with only "GO" instead of on semicolons,
+	// as the SQL Server tools do.
+	goBatches bool
+}
+
+// splitSQLStatements splits the given SQL on semicolons (or on the
+// delimiter set with the "DELIMITER" command, or on "GO" lines, depending on
+// the options), ignoring the ones inside strings, quoted identifiers and
+// comments. Statements with nothing but comments and whitespace are omitted.
+func splitSQLStatements(script string, options sqlSplitOptions) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		hasContent bool
+		delimiter  = ";"
+	)
+	flush := func() {
+		if hasContent {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasContent = false
+	}
+
+	for i := 0; i < len(script); {
+		if i == 0 || script[i-1] == '\n' {
+			lineEnd := strings.IndexByte(script[i:], '\n')
+			if lineEnd < 0 {
+				lineEnd = len(script)
+			} else {
+				lineEnd += i + 1
+			}
+			line := strings.TrimSpace(script[i:lineEnd])
+
+			if options.goBatches && strings.EqualFold(line, "GO") {
+				flush()
+				i = lineEnd
+				continue
+			}
+			if options.delimiterCommand && !hasContent && len(line) > len("DELIMITER ") && strings.EqualFold(line[:len("DELIMITER ")], "DELIMITER ") {
+				delimiter = strings.TrimSpace(line[len("DELIMITER "):])
+				i = lineEnd
+				continue
+			}
+		}
+
+		c := script[i]
+		end := i + 1
+
+		switch {
+		case !options.goBatches && strings.HasPrefix(script[i:], delimiter):
+			flush()
+			i += len(delimiter)
+			continue
+		case strings.HasPrefix(script[i:], "--"), c == '#' && options.hashComments:
+			if idx := strings.IndexByte(script[i:], '\n'); idx >= 0 {
+				end = i + idx + 1
+			} else {
+				end = len(script)
+			}
+		case strings.HasPrefix(script[i:], "/*"):
+			end = closingCommentIndex(script, i, options.nestedComments)
+		case c == '\'' || c == '"' || c == '`':
+			end = closingQuoteIndex(script, i, options.backslashEscapes)
+			hasContent = true
+		case c == '$' && options.dollarQuotes && dollarQuoteRegexp.MatchString(script[i:]):
+			tag := dollarQuoteRegexp.FindString(script[i:])
+			if idx := strings.Index(script[i+len(tag):], tag); idx >= 0 {
+				end = i + len(tag) + idx + len(tag)
+			} else {
+				end = len(script)
+			}
+			hasContent = true
+		default:
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasContent = true
+			}
+		}
+
+		current.WriteString(script[i:end])
+		i = end
+	}
+	flush()
+
+	return statements
+}
+
+// closingCommentIndex returns the index right after the end of the block
+// comment starting at position start.
+func closingCommentIndex(s string, start int, nested bool) int {
+	depth := 0
+	for i := start; i < len(s)-1; i++ {
+		switch {
+		case s[i] == '/' && s[i+1] == '*' && (nested || depth == 0):
+			depth++
+			i++
+		case s[i] == '*' && s[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// closingQuoteIndex returns the index right after the quote closing the
+// one at position start. Doubled quotes are treated as escaped.
+func closingQuoteIndex(s string, start int, backslashEscapes bool) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && backslashEscapes && quote != '`':
+			i++
+		case s[i] == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
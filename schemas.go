@@ -0,0 +1,180 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TargetSchemas makes Loader load the fixtures in each of the given schemas
+// in turn, like for schema-per-tenant databases: the table of each file is
+// prefixed with the schema, like "tenant_a.users", and the tables are
+// cleaned and filled once per schema, in a different transaction each.
+// Sequences are reset as usual after each schema is loaded.
+//
+// The records are read once and reused for every schema. The fixture files
+// can't name a schema themselves, like "public.users.yml", and other
+// options, like DeleteWhere, name the tables without the schema.
+func TargetSchemas(schemas ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if len(schemas) == 0 {
+			return fmt.Errorf("testfixtures: TargetSchemas requires at least one schema")
+		}
+		for _, schema := range schemas {
+			if schema == "" {
+				return fmt.Errorf("testfixtures: TargetSchemas requires non-empty schemas")
+			}
+		}
+		l.targetSchemas = schemas
+		return nil
+	}
+}
+
+// Schema sets the schema of the tables of the fixture files not naming one,
+// like "users.yml", instead of the default one of the database, like
+// "public" on PostgreSQL or "dbo" on SQL Server. Files naming a schema, like
+// "audit.logs.yml", are loaded as usual. Other options, like DeleteWhere,
+// name the tables without the schema.
+//
+// Only valid for PostgreSQL and SQL Server. Can't be used together with
+// TargetSchemas.
+func Schema(schema string) func(*Loader) error {
+	return func(l *Loader) error {
+		if schema == "" {
+			return fmt.Errorf("testfixtures: Schema requires a schema")
+		}
+		l.schema = schema
+		return nil
+	}
+}
+
+// checkSchema returns an error if Schema is given with another dialect than
+// PostgreSQL and SQL Server, or together with TargetSchemas.
+func (l *Loader) checkSchema() error {
+	if l.schema == "" {
+		return nil
+	}
+	switch l.helper.(type) {
+	case *postgreSQL, *sqlserver:
+	default:
+		return fmt.Errorf("testfixtures: Schema is only valid for PostgreSQL and SQL Server databases")
+	}
+	if len(l.targetSchemas) > 0 {
+		return fmt.Errorf("testfixtures: Schema can't be used together with TargetSchemas")
+	}
+	return nil
+}
+
+// schemas returns the schemas the fixture files are loaded in, given with
+// Schema or TargetSchemas.
+func (l *Loader) schemas() []string {
+	if l.schema != "" {
+		return []string{l.schema}
+	}
+	return l.targetSchemas
+}
+
+// optionsTableName returns the name of a table prefixed with a schema by
+// Schema or TargetSchemas as given to the options, like DeleteWhere, which
+// is without the schema.
+func (l *Loader) optionsTableName(tableName string) string {
+	for _, schema := range l.schemas() {
+		if strings.HasPrefix(tableName, schema+".") {
+			return strings.TrimPrefix(tableName, schema+".")
+		}
+	}
+	return tableName
+}
+
+// checkTargetSchemas returns an error if TargetSchemas is given and a
+// fixture file names a schema.
+func (l *Loader) checkTargetSchemas(files []*fixtureFile) error {
+	if len(l.targetSchemas) == 0 {
+		return nil
+	}
+	for _, file := range files {
+		if strings.Contains(file.fileNameWithoutExtension(), ".") {
+			return fmt.Errorf(`testfixtures: TargetSchemas can't be used with file "%s", which names a schema`, file.path)
+		}
+	}
+	return nil
+}
+
+// load loads the fixture files, once in each schema given with
+// TargetSchemas, or in the one given with Schema, if any.
+func (l *Loader) load(files []*fixtureFile) (*LoadResult, error) {
+	if len(l.schemas()) == 0 {
+		return l.loadFiles(files)
+	}
+
+	result := &LoadResult{}
+	for _, schema := range l.schemas() {
+		schemaFiles, err := l.schemaFiles(schema, files)
+		if err != nil {
+			return result, fmt.Errorf(`testfixtures: could not load fixtures in schema "%s": %w`, schema, err)
+		}
+		schemaResult, err := l.loadFiles(schemaFiles)
+		if schemaResult != nil {
+			result.add(schemaResult)
+		}
+		if err != nil {
+			return result, fmt.Errorf(`testfixtures: could not load fixtures in schema "%s": %w`, schema, err)
+		}
+	}
+	return result, nil
+}
+
+// schemaFiles returns the fixture files with their tables in the schema,
+// unless they name a schema already. Each one is built once, from the
+// records of the fixture file, so unmodified tables are skipped by the next
+// loads like usual.
+func (l *Loader) schemaFiles(schema string, files []*fixtureFile) ([]*fixtureFile, error) {
+	if l.schemaFixtureFiles == nil {
+		l.schemaFixtureFiles = make(map[string]map[*fixtureFile]*fixtureFile, len(l.schemas()))
+	}
+	built := l.schemaFixtureFiles[schema]
+	if built == nil {
+		built = make(map[*fixtureFile]*fixtureFile, len(files))
+		l.schemaFixtureFiles[schema] = built
+	}
+
+	schemaFiles := make([]*fixtureFile, len(files))
+	for i, file := range files {
+		if strings.Contains(file.fileNameWithoutExtension(), ".") {
+			schemaFiles[i] = file
+			continue
+		}
+		schemaFile, ok := built[file]
+		if !ok {
+			schemaFile = &fixtureFile{
+				path:     file.path,
+				fileName: schema + "." + file.fileName,
+				fromDir:  file.fromDir,
+				part:     file.part,
+				content:  file.content,
+				records:  file.records,
+			}
+			if err := l.buildFileInsertSQLs(schemaFile); err != nil {
+				return nil, fmt.Errorf("%s: %w", file.path, err)
+			}
+			built[file] = schemaFile
+		}
+		schemaFiles[i] = schemaFile
+	}
+	return schemaFiles, nil
+}
+
+// add adds the counts of another result to the result.
+func (r *LoadResult) add(other *LoadResult) {
+	r.FilesLoaded += other.FilesLoaded
+	r.FilesSkipped += other.FilesSkipped
+	r.Rows += other.Rows
+	r.AnalyzeErrors = append(r.AnalyzeErrors, other.AnalyzeErrors...)
+	r.Duration += other.Duration
+	r.CascadedTables = append(r.CascadedTables, other.CascadedTables...)
+	for tableName, table := range other.Tables {
+		if r.Tables == nil {
+			r.Tables = make(map[string]*TableResult)
+		}
+		r.Tables[tableName] = table
+	}
+}
@@ -0,0 +1,69 @@
+package testfixtures
+
+import (
+	"errors"
+	"fmt"
+)
+
+// spannerMaxMutations is the maximum number of mutations in a Cloud Spanner
+// transaction.
+const spannerMaxMutations = 20000
+
+var errSpannerDatabaseName = errors.New("testfixtures: the database name can't be queried on Cloud Spanner, give it with DatabaseName")
+
+type spanner struct {
+	baseHelper
+}
+
+func (*spanner) paramType() int {
+	return paramTypeAtSign
+}
+
+// quoteKeyword returns the identifier as is, as the Spanner drivers don't
+// agree on quoting.
+func (*spanner) quoteKeyword(s string) string {
+	return s
+}
+
+func (*spanner) databaseName(queryable) (string, error) {
+	return "", errSpannerDatabaseName
+}
+
+func (*spanner) tableNames(q queryable) ([]string, error) {
+	const query = `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = ''
+	`
+	return queryStrings(q, query)
+}
+
+// cleanTableSQL is a tableCleaner interface implementation. Spanner rejects
+// "DELETE" statements without a "WHERE" clause.
+func (h *spanner) cleanTableSQL(tableName string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE true", h.quoteKeyword(tableName))
+}
+
+// requiresDependencyOrder is an orderedHelper interface implementation.
+// Spanner can't disable foreign keys.
+func (*spanner) requiresDependencyOrder() {}
+
+// maxMutations is a mutationLimiter interface implementation.
+func (*spanner) maxMutations() int {
+	return spannerMaxMutations
+}
+
+// disableReferentialIntegrity is not used, as the fixtures are loaded by
+// loadInChunks. See mutationLimiter.
+func (*spanner) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err = loadFn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
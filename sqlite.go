@@ -1,10 +1,13 @@
 package testfixtures
 
 import (
-	"database/sql"
 	"path/filepath"
 )
 
+// sqliteMemoryDatabaseName is the name reported for in-memory (or temporary)
+// databases, which are always considered test databases.
+const sqliteMemoryDatabaseName = ":memory:"
+
 type sqlite struct {
 	baseHelper
 }
@@ -20,6 +23,9 @@ func (*sqlite) databaseName(q queryable) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if dbName == "" {
+		return sqliteMemoryDatabaseName, nil
+	}
 	dbName = filepath.Base(dbName)
 	return dbName, nil
 }
@@ -50,26 +56,72 @@ func (*sqlite) tableNames(q queryable) ([]string, error) {
 	return tables, nil
 }
 
-func (*sqlite) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction) (err error) {
-	defer func() {
-		if _, err2 := db.Exec("PRAGMA defer_foreign_keys = OFF"); err2 != nil && err == nil {
-			err = err2
-		}
-	}()
-
-	if _, err = db.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
-		return err
-	}
-
+func (*sqlite) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
 	tx, err := db.Begin()
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// Running the pragma inside the transaction ensures it applies to the
+	// same connection. It's automatically switched off on commit or rollback,
+	// so there's no previous state to restore. "PRAGMA foreign_keys" can't be
+	// used instead, as it's a no-op inside a transaction.
+	if _, err = tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
 	if err = loadFn(tx); err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func (*sqlite) disableReferentialIntegrityForSession(q queryable, fn func() error) (err error) {
+	var foreignKeys bool
+	if err = q.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return err
+	}
+	if !foreignKeys {
+		return fn()
+	}
+
+	if _, err = q.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer func() {
+		if _, err2 := q.Exec("PRAGMA foreign_keys = ON"); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+
+	return fn()
+}
+
+// upsertClause is an upserter interface implementation. It requires SQLite
+// 3.24.0 or newer.
+func (*sqlite) upsertClause(keys, columns []string) string {
+	return onConflictClause(keys, columns)
+}
+
+// insertedIDClauses is an insertedIDHelper interface implementation. It
+// requires SQLite 3.35.0 or newer.
+func (*sqlite) insertedIDClauses(column string) (string, string) {
+	return "", " RETURNING " + column
+}
+
+// foreignKeys is a foreignKeyLister interface implementation.
+func (*sqlite) foreignKeys(q queryable) ([]foreignKey, error) {
+	const query = `
+		SELECT m.name, p."table"
+		FROM sqlite_master m, pragma_foreign_key_list(m.name) p
+		WHERE m.type = 'table'
+	`
+	return queryForeignKeys(q, query)
+}
+
+// analyzeTableSQL is an analyzer interface implementation.
+func (h *sqlite) analyzeTableSQL(tableName string) string {
+	return "ANALYZE " + h.quoteKeyword(tableName)
+}
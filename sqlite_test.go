@@ -3,10 +3,21 @@
 package testfixtures
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v2"
 )
 
 func TestSQLite(t *testing.T) {
@@ -17,3 +28,1111 @@ func TestSQLite(t *testing.T) {
 		"testdata/schema/sqlite.sql",
 	)
 }
+
+func TestSQLiteWithPerTableTransaction(t *testing.T) {
+	testLoader(
+		t,
+		"sqlite3",
+		os.Getenv("SQLITE_CONN_STRING"),
+		"testdata/schema/sqlite.sql",
+		PerTableTransaction(),
+	)
+}
+
+func TestSQLiteInMemory(t *testing.T) {
+	testLoader(
+		t,
+		"sqlite3",
+		"file::memory:?cache=shared&_foreign_keys=1",
+		"testdata/schema/sqlite.sql",
+	)
+}
+
+func TestSQLiteWithOrder(t *testing.T) {
+	// The foreign keys are enforced and left enabled, so the tables must be
+	// loaded before the ones referencing them, with the users first.
+	testLoader(
+		t,
+		"sqlite3",
+		"file::memory:?cache=shared&_foreign_keys=1",
+		"testdata/schema/sqlite.sql",
+		SkipReferentialIntegrity(),
+		Order("users", "posts", "tags"),
+	)
+}
+
+func BenchmarkSQLiteLoad(b *testing.B) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("sqlite3"))
+	if err != nil {
+		b.Fatalf("failed to create loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		b.Fatalf("failed to load schema: %v", err)
+	}
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		Files("testdata/fixtures_repeat/tags.yml"),
+	)
+	if err != nil {
+		b.Fatalf("failed to create loader: %v", err)
+	}
+
+	b.Run("Load", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := l.Load(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("ReloadAndLoad", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := l.Reload(); err != nil {
+				b.Fatal(err)
+			}
+			if err := l.Load(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestSQLiteWithRegisteredHelper(t *testing.T) {
+	RegisterHelper("fakedb", func() Helper { return FakeHelper{} })
+	defer RegisterHelper("fakedb", nil)
+
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("fakedb"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	l, err := New(
+		Database(db),
+		Dialect("fakedb"),
+		Files("testdata/fixtures/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "tags", 3)
+}
+
+func TestSQLiteVerifyWithTrigger(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		Files("testdata/fixtures/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TRIGGER duplicate_tag AFTER INSERT ON tags WHEN NEW.id = 1
+		BEGIN
+			INSERT INTO tags (id, name, created_at, updated_at)
+			VALUES (100, NEW.name, NEW.created_at, NEW.updated_at);
+		END
+	`)
+	if err != nil {
+		t.Fatalf("cannot create trigger: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TRIGGER duplicate_tag"); err != nil {
+			t.Errorf("cannot drop trigger: %v", err)
+		}
+	}()
+
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	if err := l.Verify(); err == nil {
+		t.Error("should return an error when a trigger inserts an extra row")
+	}
+}
+
+func TestSQLiteWithDependencyOrder(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("sqlite3"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	// The comments reference the posts, so they are loaded after them.
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		SkipReferentialIntegrity(),
+		DependencyOrder(),
+		Files(
+			"testdata/fixtures/comments.yml",
+			"testdata/fixtures/posts.yml",
+		),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "comments", 4)
+
+	_, err = db.Exec(`
+		CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL, favorite_book_id INTEGER REFERENCES books (id));
+		CREATE TABLE books (id INTEGER PRIMARY KEY, title TEXT NOT NULL, author_id INTEGER NOT NULL REFERENCES authors (id));
+	`)
+	if err != nil {
+		t.Fatalf("cannot create tables: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE books; DROP TABLE authors"); err != nil {
+			t.Errorf("cannot drop tables: %v", err)
+		}
+	}()
+
+	l, err = New(
+		Database(db),
+		Dialect("sqlite3"),
+		SkipReferentialIntegrity(),
+		DependencyOrder(),
+		Directory("testdata/fixtures_cycle"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	err = l.Load()
+	if err == nil || !strings.Contains(err.Error(), "authors -> books -> authors") {
+		t.Errorf("expected an error naming the cycle, got %v", err)
+	}
+}
+
+func TestSQLiteWithCascadeCleanup(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		Template(),
+		TemplateData(map[string]interface{}{
+			"PostIds": []int{1, 2},
+			"TagIds":  []int{1, 2, 3},
+		}),
+		Directory("testdata/fixtures"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	// The votes reference the comments, which reference the posts, so they
+	// are cleaned first.
+	l, err = New(
+		Database(db),
+		Dialect("sqlite3"),
+		SkipReferentialIntegrity(),
+		CascadeCleanup(),
+		Files("testdata/fixtures/posts.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	result, err := l.LoadWithResult()
+	if err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "posts", 2)
+	for _, table := range []string{"comments", "votes", "posts_tags"} {
+		assertCount(t, l, table, 0)
+	}
+	if len(result.CascadedTables) != 3 || result.CascadedTables[0] != "votes" {
+		t.Errorf("expected votes, then comments and posts_tags to be cleaned, got %v", result.CascadedTables)
+	}
+	assertCount(t, l, "tags", 3)
+}
+
+func TestSQLiteWithForeignKeysEnabled(t *testing.T) {
+	connString := filepath.Join(t.TempDir(), "testdb.sqlite3") + "?_foreign_keys=1"
+	db, err := sql.Open("sqlite3", connString)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		DROP TABLE IF EXISTS books;
+		DROP TABLE IF EXISTS authors;
+		CREATE TABLE authors (code TEXT PRIMARY KEY, name TEXT NOT NULL) WITHOUT ROWID;
+		CREATE TABLE books (code TEXT PRIMARY KEY, author_code TEXT NOT NULL REFERENCES authors (code)) WITHOUT ROWID;
+	`)
+	if err != nil {
+		t.Fatalf("cannot create tables: %v", err)
+	}
+	defer func() { _, _ = db.Exec("DROP TABLE books; DROP TABLE authors") }()
+	if _, err := db.Exec("INSERT INTO books (code, author_code) VALUES ('orphan', 'nobody')"); err == nil {
+		t.Fatal("expected the foreign keys to be enforced")
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"authors.yml": "- code: tolkien\n  name: J. R. R. Tolkien\n",
+		"books.yml":   "- code: hobbit\n  author_code: tolkien\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, options := range map[string][]func(*Loader) error{
+		"Transaction":         nil,
+		"PerTableTransaction": {PerTableTransaction()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// The books are loaded before the authors they reference.
+			l, err := New(append([]func(*Loader) error{
+				Database(db),
+				Dialect("sqlite3"),
+				Order("books", "authors"),
+				Directory(dir),
+			}, options...)...)
+			if err != nil {
+				t.Fatalf("failed to create Loader: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := l.Load(); err != nil {
+					t.Fatalf("cannot load fixtures: %v", err)
+				}
+			}
+			assertCount(t, l, "authors", 1)
+			assertCount(t, l, "books", 1)
+
+			var foreignKeys bool
+			if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+				t.Fatal(err)
+			}
+			if !foreignKeys {
+				t.Error("expected the foreign keys to be enabled again after the load")
+			}
+		})
+	}
+}
+
+func TestSQLiteWithAroundTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	const createTrigger = `CREATE TRIGGER comments_readonly BEFORE INSERT ON comments BEGIN SELECT RAISE(ABORT, 'comments are read-only'); END`
+	options := []func(*Loader) error{
+		Database(db),
+		Dialect("sqlite3"),
+		Template(),
+		TemplateData(map[string]interface{}{
+			"PostIds": []int{1, 2},
+			"TagIds":  []int{1, 2, 3},
+		}),
+		Directory("testdata/fixtures"),
+	}
+	l, err := New(options...)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+	if _, err := db.Exec(createTrigger); err != nil {
+		t.Fatalf("cannot create trigger: %v", err)
+	}
+	defer func() { _, _ = db.Exec("DROP TRIGGER IF EXISTS comments_readonly") }()
+	if err := l.Load(); err == nil || !strings.Contains(err.Error(), "comments are read-only") {
+		t.Fatalf("expected the trigger to abort the load, got %v", err)
+	}
+
+	var calls int
+	l, err = New(append(options,
+		AroundTable("comments", func(tx *sql.Tx, next func() error) error {
+			calls++
+			if _, err := tx.Exec("DROP TRIGGER comments_readonly"); err != nil {
+				return err
+			}
+			if err := next(); err != nil {
+				return err
+			}
+			_, err := tx.Exec(createTrigger)
+			return err
+		}),
+	)...)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected the function to be called once, got %d calls", calls)
+	}
+	assertFixturesLoaded(t, l)
+
+	var triggers int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'comments_readonly'").Scan(&triggers); err != nil {
+		t.Fatal(err)
+	}
+	if triggers != 1 {
+		t.Error("expected the trigger to be created again")
+	}
+}
+
+func TestSQLiteWithRequireReferencedTables(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	l, err := New(Database(db), Dialect("sqlite3"), Files("testdata/fixtures/posts.yml"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	// The comments reference the posts, which have no fixtures.
+	l, err = New(
+		Database(db),
+		Dialect("sqlite3"),
+		RequireReferencedTables(),
+		Files("testdata/fixtures/comments.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	err = l.Load()
+	if err == nil || !strings.Contains(err.Error(), "posts (referenced by comments)") {
+		t.Errorf("expected an error naming the posts table, got %v", err)
+	}
+	assertCount(t, l, "comments", 0)
+}
+
+func TestSQLiteWithTargetSchemas(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	// Attached databases, which act as schemas, are only seen by the
+	// connection attaching them.
+	db.SetMaxOpenConns(1)
+
+	for _, schema := range []string{"tenant_a", "tenant_b"} {
+		_, err := db.Exec(fmt.Sprintf(`
+			ATTACH DATABASE ':memory:' AS %[1]s;
+			CREATE TABLE %[1]s.tags (id INT PRIMARY KEY, name VARCHAR(255) NOT NULL, created_at TIMESTAMP NOT NULL, updated_at TIMESTAMP NOT NULL);
+		`, schema))
+		if err != nil {
+			t.Fatalf("cannot create schema: %v", err)
+		}
+	}
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		TargetSchemas("tenant_a", "tenant_b"),
+		Files("testdata/fixtures/tags.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	result, err := l.LoadWithResult()
+	if err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "tenant_a.tags", 3)
+	assertCount(t, l, "tenant_b.tags", 3)
+	if result.Rows != 6 || result.Tables["tenant_a.tags"] == nil || result.Tables["tenant_b.tags"] == nil {
+		t.Errorf("expected the tags of both schemas in the result, got %d rows and tables %v", result.Rows, result.Tables)
+	}
+
+	// The records are loaded again in each schema.
+	if _, err := db.Exec("DELETE FROM tenant_b.tags WHERE id = 1"); err != nil {
+		t.Fatalf("cannot delete tag: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures again: %v", err)
+	}
+	assertCount(t, l, "tenant_b.tags", 3)
+
+	if _, err := db.Exec("DROP TABLE tenant_b.tags"); err != nil {
+		t.Fatalf("cannot drop table: %v", err)
+	}
+	err = l.Load()
+	if err == nil || !strings.Contains(err.Error(), `schema "tenant_b"`) {
+		t.Errorf("expected an error naming the schema, got %v", err)
+	}
+}
+
+func TestSQLiteDumpTimes(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE events (id INTEGER PRIMARY KEY, happened_at DATETIME NOT NULL, cancelled_at DATETIME NULL);
+		INSERT INTO events (id, happened_at, cancelled_at) VALUES
+			(1, '2020-01-15 12:30:45.5', NULL),
+			(2, '2020-02-01 08:00:00', '2020-02-02 09:15:00');
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE events"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	for _, test := range []struct {
+		options  []func(*Dumper) error
+		expected string
+	}{
+		{
+			expected: "- id: 1\n  happened_at: \"2020-01-15T12:30:45.5Z\"\n  cancelled_at: null\n" +
+				"- id: 2\n  happened_at: \"2020-02-01T08:00:00Z\"\n  cancelled_at: \"2020-02-02T09:15:00Z\"\n",
+		},
+		{
+			options: []func(*Dumper) error{DumpTimeLayout("2006-01-02 15:04:05")},
+			expected: "- id: 1\n  happened_at: \"2020-01-15 12:30:45\"\n  cancelled_at: null\n" +
+				"- id: 2\n  happened_at: \"2020-02-01 08:00:00\"\n  cancelled_at: \"2020-02-02 09:15:00\"\n",
+		},
+	} {
+		dir := t.TempDir()
+		dumper, err := NewDumper(append(
+			[]func(*Dumper) error{
+				DumpDatabase(db),
+				DumpDialect("sqlite3"),
+				DumpDirectory(dir),
+				DumpTables("events"),
+			},
+			test.options...,
+		)...)
+		if err != nil {
+			t.Fatalf("could not create dumper: %v", err)
+		}
+		if err := dumper.Dump(); err != nil {
+			t.Fatalf("cannot dump fixtures: %v", err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, "events.yml"))
+		if err != nil {
+			t.Fatalf("cannot read dumped file: %v", err)
+		}
+		if string(content) != test.expected {
+			t.Errorf("expected:\n%s\ngot:\n%s", test.expected, content)
+		}
+
+		l, err := New(
+			Database(db),
+			Dialect("sqlite3"),
+			Location(time.UTC),
+			Directory(dir),
+		)
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		if err := l.Load(); err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		l.AssertTableMatchesFixture(t, "events")
+	}
+}
+
+func TestSQLiteDumpFileValues(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE documents (id INTEGER PRIMARY KEY, title TEXT NOT NULL, body TEXT NOT NULL, attachment BLOB NOT NULL);
+		INSERT INTO documents (id, title, body, attachment) VALUES (1, 'Short', '<p>A long enough body</p>', X'0001FEFF');
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE documents"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	dir := t.TempDir()
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("sqlite3"),
+		DumpDirectory(dir),
+		DumpTables("documents"),
+		DumpFileValues(4),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "documents.yml"))
+	if err != nil {
+		t.Fatalf("cannot read dumped file: %v", err)
+	}
+	expected := "- id: 1\n  title: file:documents/1_title.txt\n  body: file:documents/1_body.txt\n  attachment: binfile:documents/1_attachment.bin\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		FileValues(),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	l.AssertTableMatchesFixture(t, "documents")
+}
+
+func TestSQLiteColumnEncoderRoundTrip(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE places (id INTEGER PRIMARY KEY, location TEXT NOT NULL);
+		INSERT INTO places (id, location) VALUES (1, '48.85,2.35');
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE places"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	// Locations are stored as "lat,lng" strings and written as objects.
+	decode := func(value interface{}) (interface{}, error) {
+		var location string
+		switch v := value.(type) {
+		case string:
+			location = v
+		case []byte:
+			location = string(v)
+		default:
+			return nil, fmt.Errorf("unexpected location %v", value)
+		}
+		parts := strings.Split(location, ",")
+		return yaml.MapSlice{{Key: "lat", Value: parts[0]}, {Key: "lng", Value: parts[1]}}, nil
+	}
+	encode := func(value interface{}) (interface{}, error) {
+		location, ok := value.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("location must be an object, got %v", value)
+		}
+		return fmt.Sprintf("%v,%v", location["lat"], location["lng"]), nil
+	}
+
+	dir := t.TempDir()
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("sqlite3"),
+		DumpDirectory(dir),
+		DumpTables("places"),
+		DumpColumnDecoder("places", "location", decode),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "places.yml"))
+	if err != nil {
+		t.Fatalf("cannot read dumped file: %v", err)
+	}
+	expected := "- id: 1\n  location:\n    lat: \"48.85\"\n    lng: \"2.35\"\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	if _, err := db.Exec("DELETE FROM places"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		ColumnEncoder("places", "location", encode),
+		Directory(dir),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	var location string
+	if err := db.QueryRow("SELECT location FROM places WHERE id = 1").Scan(&location); err != nil {
+		t.Fatal(err)
+	}
+	if location != "48.85,2.35" {
+		t.Errorf("expected the location to be encoded back, got %q", location)
+	}
+}
+
+// chunkedSQLite limits the number of values inserted per transaction, like
+// the Spanner helper does.
+type chunkedSQLite struct {
+	sqlite
+	limit int
+}
+
+func (h *chunkedSQLite) maxMutations() int {
+	return h.limit
+}
+
+func TestSQLiteDumpMaxRowsPerFile(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);
+		INSERT INTO notes (id, body) VALUES (1, 'a'), (2, 'b'), (3, 'c'), (4, 'd'), (5, 'e');
+	`)
+	if err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE notes"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	dir := t.TempDir()
+	// A part left by a previous dump is removed.
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.4.yml"), []byte("- id: 6\n  body: f\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dumper, err := NewDumper(
+		DumpDatabase(db),
+		DumpDialect("sqlite3"),
+		DumpDirectory(dir),
+		DumpTables("notes"),
+		DumpMaxRowsPerFile(2),
+	)
+	if err != nil {
+		t.Fatalf("could not create dumper: %v", err)
+	}
+	if err := dumper.Dump(); err != nil {
+		t.Fatalf("cannot dump fixtures: %v", err)
+	}
+	names, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	if expected := []string{"notes.2.yml", "notes.3.yml", "notes.yml"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "notes.3.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "- id: 5\n  body: e\n"; string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	// Every part is loaded, whether the table is cleaned in the transaction
+	// of the load or in the one of its first file.
+	for _, perTableTransaction := range []bool{false, true} {
+		if _, err := db.Exec("UPDATE notes SET body = 'changed'"); err != nil {
+			t.Fatal(err)
+		}
+		options := []func(*Loader) error{
+			Database(db),
+			Dialect("sqlite3"),
+			Directory(dir),
+		}
+		if perTableTransaction {
+			options = append(options, PerTableTransaction())
+		}
+		l, err := New(options...)
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		if err := l.Load(); err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM notes WHERE body <> 'changed'").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		if count != 5 {
+			t.Errorf("expected the 5 notes to be loaded, got %d", count)
+		}
+	}
+}
+
+func TestSQLiteWatch(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE watched_notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL)"); err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE watched_notes"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "watched_notes.yml")
+	writeFile := func(content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("- id: 1\n  body: a\n")
+
+	var (
+		mu       sync.Mutex
+		messages []string
+	)
+	l, err := New(
+		Database(db),
+		Dialect("sqlite3"),
+		Directory(dir),
+		WatchInterval(10*time.Millisecond),
+		Logger(func(query string, _ []interface{}) {
+			if strings.HasPrefix(query, "-- testfixtures:") {
+				mu.Lock()
+				messages = append(messages, query)
+				mu.Unlock()
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- l.Watch(ctx) }()
+
+	waitFor := func(what string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	logged := func(part string) func() bool {
+		return func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, message := range messages {
+				if strings.Contains(message, part) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	writeFile("- id: 1\n  body: a\n- id: 2\n  body: b\n")
+	waitFor("the reload", logged("reloaded 1 fixture files"))
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM watched_notes").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected the records of the changed file, got %d", count)
+	}
+
+	// Errors are logged, and the previous fixtures are kept.
+	writeFile("- id: [\n")
+	waitFor("the error", logged("could not reload fixtures"))
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected Watch to stop without error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch didn't stop")
+	}
+}
+
+func TestSQLiteInChunks(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newLoader := func(limit int, files ...string) *Loader {
+		l, err := New(Database(db), Dialect("sqlite3"), SkipDuplicateKeysCheck(), Files(files...))
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		l.helper = &chunkedSQLite{limit: limit}
+		return l
+	}
+	if err := newLoader(1).LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	t.Run("Load", func(t *testing.T) {
+		// Up to 2 records per transaction.
+		l := newLoader(10, "testdata/fixtures/posts.yml", "testdata/fixtures/comments.yml", "testdata/fixtures/tags.yml")
+		if err := l.Load(); err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		assertCount(t, l, "posts", 2)
+		assertCount(t, l, "comments", 4)
+		assertCount(t, l, "tags", 3)
+	})
+
+	t.Run("PartialLoad", func(t *testing.T) {
+		// The second record has the same id as the first one, which is
+		// already committed.
+		l := newLoader(4, "testdata/fixtures_duplicates/tags.yml")
+		err := l.Load()
+		var partialErr *PartialLoadError
+		if !errors.As(err, &partialErr) {
+			t.Fatalf("expected a PartialLoadError, got %v", err)
+		}
+		if expected := []string{"tags"}; !reflect.DeepEqual(partialErr.CommittedTables, expected) {
+			t.Errorf("expected committed tables %v, got %v", expected, partialErr.CommittedTables)
+		}
+		assertCount(t, l, "tags", 1)
+	})
+}
+
+func TestSQLiteWithConcurrency(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newLoader := func(files ...string) *Loader {
+		l, err := New(Database(db), Dialect("sqlite3"), Concurrency(4), Files(files...))
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		return l
+	}
+	if err := newLoader().LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	t.Run("IndependentTables", func(t *testing.T) {
+		l := newLoader("testdata/fixtures/users.yml", "testdata/fixtures/assets.yml", "testdata/fixtures_nulls/nullables.yml")
+		concurrent, err := l.canLoadConcurrently(l.fixturesFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !concurrent {
+			t.Fatal("expected the tables to be loaded concurrently")
+		}
+		for i := 0; i < 2; i++ {
+			if err := l.Load(); err != nil {
+				t.Fatalf("cannot load fixtures: %v", err)
+			}
+		}
+		assertCount(t, l, "users", 2)
+		assertCount(t, l, "assets", 1)
+		assertCount(t, l, "nullables", 3)
+	})
+
+	t.Run("DependentTables", func(t *testing.T) {
+		l := newLoader("testdata/fixtures/users.yml", "testdata/fixtures/posts.yml", "testdata/fixtures/comments.yml")
+		concurrent, err := l.canLoadConcurrently(l.fixturesFiles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if concurrent {
+			t.Fatal("expected the tables to be loaded sequentially")
+		}
+		if err := l.Load(); err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		assertCount(t, l, "posts", 2)
+		assertCount(t, l, "comments", 4)
+	})
+}
+
+func BenchmarkSQLiteLoadWithConcurrency(b *testing.B) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("sqlite3"))
+	if err != nil {
+		b.Fatalf("failed to create loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		b.Fatalf("failed to load schema: %v", err)
+	}
+
+	for _, n := range []int{1, 4} {
+		// Tables without foreign keys in either direction, so they are
+		// loaded concurrently with a concurrency above 1.
+		l, err := New(
+			Database(db),
+			Dialect("sqlite3"),
+			Concurrency(n),
+			Files(
+				"testdata/fixtures/users.yml",
+				"testdata/fixtures/assets.yml",
+				"testdata/fixtures_nulls/nullables.yml",
+			),
+		)
+		if err != nil {
+			b.Fatalf("failed to create loader: %v", err)
+		}
+		if concurrent, err := l.canLoadConcurrently(l.fixturesFiles); err != nil || concurrent != (n > 1) {
+			b.Fatalf("unexpected concurrent load %v: %v", concurrent, err)
+		}
+
+		b.Run(fmt.Sprintf("Concurrency%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := l.Load(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// failingAnalyzeSQLite updates the statistics with an invalid statement.
+type failingAnalyzeSQLite struct {
+	sqlite
+}
+
+func (*failingAnalyzeSQLite) analyzeTableSQL(tableName string) string {
+	return "ANALYZE no_such_table"
+}
+
+func TestSQLiteAnalyzeBestEffort(t *testing.T) {
+	db, err := sql.Open("sqlite3", os.Getenv("SQLITE_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newLoader := func(options ...func(*Loader) error) *Loader {
+		l, err := New(append([]func(*Loader) error{
+			Database(db),
+			Dialect("sqlite3"),
+			AnalyzeAfterLoad(),
+			Files("testdata/fixtures/tags.yml"),
+		}, options...)...)
+		if err != nil {
+			t.Fatalf("failed to create Loader: %v", err)
+		}
+		l.helper = &failingAnalyzeSQLite{}
+		return l
+	}
+	if err := newLoader().LoadSchemaFile("testdata/schema/sqlite.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	t.Run("Fatal", func(t *testing.T) {
+		if err := newLoader().Load(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+
+	t.Run("BestEffort", func(t *testing.T) {
+		result, err := newLoader(AnalyzeBestEffort()).LoadWithResult()
+		if err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		if len(result.AnalyzeErrors) != 1 {
+			t.Fatalf("expected 1 analyze error, got %v", result.AnalyzeErrors)
+		}
+	})
+}
@@ -10,10 +10,11 @@ type sqlserver struct {
 	baseHelper
 
 	paramTypeCache int
+	namedParams    bool
 	tables         []string
 }
 
-func (h *sqlserver) init(db *sql.DB) error {
+func (h *sqlserver) init(db dbHandle) error {
 	var err error
 
 	// NOTE(@andreynering): The SQL Server lib (github.com/denisenkom/go-mssqldb)
@@ -23,7 +24,9 @@ func (h *sqlserver) init(db *sql.DB) error {
 	// Since we don't have a way to know which driver it's been used,
 	// this is a small hack to detect the allowed param style.
 	var v int
-	if err := db.QueryRow("SELECT ?", 1).Scan(&v); err == nil && v == 1 {
+	if h.namedParams {
+		h.paramTypeCache = paramTypeAtSign
+	} else if err := db.QueryRow("SELECT ?", 1).Scan(&v); err == nil && v == 1 {
 		h.paramTypeCache = paramTypeQuestion
 	} else {
 		h.paramTypeCache = paramTypeAtSign
@@ -41,14 +44,30 @@ func (h *sqlserver) paramType() int {
 	return h.paramTypeCache
 }
 
+// bindParams is a paramBinder interface implementation. See UseNamedParams.
+func (h *sqlserver) bindParams(params []interface{}) []interface{} {
+	if !h.namedParams {
+		return params
+	}
+	named := make([]interface{}, len(params))
+	for i, param := range params {
+		named[i] = sql.Named(fmt.Sprintf("p%d", i+1), param)
+	}
+	return named
+}
+
 func (*sqlserver) quoteKeyword(s string) string {
 	parts := strings.Split(s, ".")
 	for i, p := range parts {
-		parts[i] = fmt.Sprintf(`[%s]`, p)
+		parts[i] = fmt.Sprintf(`[%s]`, strings.ReplaceAll(p, "]", "]]"))
 	}
 	return strings.Join(parts, ".")
 }
 
+func (*sqlserver) selectLimitSQL(table string, limit int) string {
+	return fmt.Sprintf("SELECT TOP (%d) * FROM %s", limit, table)
+}
+
 func (*sqlserver) databaseName(q queryable) (string, error) {
 	var dbName string
 	err := q.QueryRow("SELECT DB_NAME()").Scan(&dbName)
@@ -76,26 +95,39 @@ func (*sqlserver) tableNames(q queryable) ([]string, error) {
 	return tables, nil
 }
 
-func (h *sqlserver) tableHasIdentityColumn(q queryable, tableName string) (bool, error) {
-	sql := fmt.Sprintf(`
-		SELECT COUNT(*)
+// identityColumn returns the name of the identity column of a table, or an
+// empty string if it has none.
+func (h *sqlserver) identityColumn(q queryable, tableName string) (string, error) {
+	query := fmt.Sprintf(`
+		SELECT name
 		FROM SYS.IDENTITY_COLUMNS
 		WHERE OBJECT_ID = OBJECT_ID('%s')
-	`, tableName)
-	var count int
-	if err := q.QueryRow(sql).Scan(&count); err != nil {
-		return false, err
+	`, h.quotedObjectName(tableName))
+	var name string
+	err := q.QueryRow(query).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
 	}
-	return count > 0, nil
+	return name, err
+}
 
+// quotedObjectName returns the table name to give to OBJECT_ID: quoted, so
+// the schema and names needing escaping are understood, and escaped for the
+// string literal.
+func (h *sqlserver) quotedObjectName(tableName string) string {
+	return strings.ReplaceAll(h.quoteKeyword(tableName), "'", "''")
 }
 
-func (h *sqlserver) whileInsertOnTable(tx *sql.Tx, tableName string, fn func() error) (err error) {
-	hasIdentityColumn, err := h.tableHasIdentityColumn(tx, tableName)
+// whileInsertOnTable enables identity inserts on the table if the records
+// set its identity column. It's left disabled otherwise, because SQL Server
+// rejects the inserts omitting the identity column while it's enabled, like
+// the ones of CaptureInsertedIDs.
+func (h *sqlserver) whileInsertOnTable(tx *sql.Tx, tableName string, columns []string, fn func() error) (err error) {
+	identityColumn, err := h.identityColumn(tx, tableName)
 	if err != nil {
 		return err
 	}
-	if hasIdentityColumn {
+	if setsIdentityColumn(identityColumn, columns) {
 		defer func() {
 			_, err2 := tx.Exec(fmt.Sprintf("SET IDENTITY_INSERT %s OFF", h.quoteKeyword(tableName)))
 			if err2 != nil && err == nil {
@@ -111,23 +143,29 @@ func (h *sqlserver) whileInsertOnTable(tx *sql.Tx, tableName string, fn func() e
 	return fn()
 }
 
-func (h *sqlserver) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction) (err error) {
+// setsIdentityColumn tells whether the identity column is one of the
+// inserted columns. Column names are case insensitive on SQL Server.
+func setsIdentityColumn(identityColumn string, columns []string) bool {
+	if identityColumn == "" {
+		return false
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column, identityColumn) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *sqlserver) disableReferentialIntegrity(db dbHandle, loadFn loadFunction) (err error) {
 	// ensure the triggers are re-enable after all
 	defer func() {
-		var b strings.Builder
-		for _, table := range h.tables {
-			b.WriteString(fmt.Sprintf("ALTER TABLE %s WITH CHECK CHECK CONSTRAINT ALL;", h.quoteKeyword(table)))
-		}
-		if _, err2 := db.Exec(b.String()); err2 != nil && err == nil {
+		if _, err2 := db.Exec(h.checkConstraintsSQL(true)); err2 != nil && err == nil {
 			err = err2
 		}
 	}()
 
-	var b strings.Builder
-	for _, table := range h.tables {
-		b.WriteString(fmt.Sprintf("ALTER TABLE %s NOCHECK CONSTRAINT ALL;", h.quoteKeyword(table)))
-	}
-	if _, err := db.Exec(b.String()); err != nil {
+	if _, err := db.Exec(h.checkConstraintsSQL(false)); err != nil {
 		return err
 	}
 
@@ -144,10 +182,80 @@ func (h *sqlserver) disableReferentialIntegrity(db *sql.DB, loadFn loadFunction)
 	return tx.Commit()
 }
 
-// splitter is a batchSplitter interface implementation. We need it for
-// SQL Server because commands like a `CREATE SCHEMA...` and a `CREATE TABLE...`
-// could not be executed in the same batch.
-// See https://docs.microsoft.com/en-us/previous-versions/sql/sql-server-2008-r2/ms175502(v=sql.105)#rules-for-using-batches
-func (*sqlserver) splitter() []byte {
-	return []byte("GO\n")
+func (h *sqlserver) disableReferentialIntegrityForSession(q queryable, fn func() error) (err error) {
+	defer func() {
+		if _, err2 := q.Exec(h.checkConstraintsSQL(true)); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+
+	if _, err := q.Exec(h.checkConstraintsSQL(false)); err != nil {
+		return err
+	}
+
+	return fn()
+}
+
+func (h *sqlserver) checkConstraintsSQL(check bool) string {
+	var b strings.Builder
+	for _, table := range h.tables {
+		if check {
+			b.WriteString(fmt.Sprintf("ALTER TABLE %s WITH CHECK CHECK CONSTRAINT ALL;", h.quoteKeyword(table)))
+		} else {
+			b.WriteString(fmt.Sprintf("ALTER TABLE %s NOCHECK CONSTRAINT ALL;", h.quoteKeyword(table)))
+		}
+	}
+	return b.String()
+}
+
+// splitStatements is a statementSplitter interface implementation. We need
+// it for SQL Server because commands like a `CREATE SCHEMA...` and a
+// `CREATE TABLE...` could not be executed in the same batch, so scripts are
+// split on "GO" lines, as the SQL Server tools do.
+// See https://docs.microsoft.com/en-us/sql/t-sql/language-elements/sql-server-utilities-statements-go
+func (*sqlserver) splitStatements(schema string) []string {
+	return splitSQLStatements(schema, sqlSplitOptions{nestedComments: true, goBatches: true})
+}
+
+// insertedIDClauses is an insertedIDHelper interface implementation. SQL
+// Server doesn't allow "OUTPUT" without "INTO" on tables with triggers.
+func (*sqlserver) insertedIDClauses(column string) (string, string) {
+	return " OUTPUT INSERTED." + column, ""
+}
+
+// foreignKeys is a foreignKeyLister interface implementation.
+func (*sqlserver) foreignKeys(q queryable) ([]foreignKey, error) {
+	const query = `
+		SELECT
+			OBJECT_SCHEMA_NAME(parent_object_id) + '.' + OBJECT_NAME(parent_object_id),
+			OBJECT_SCHEMA_NAME(referenced_object_id) + '.' + OBJECT_NAME(referenced_object_id)
+		FROM sys.foreign_keys
+	`
+	return queryForeignKeys(q, query)
+}
+
+// generatedColumns is a generatedColumnLister interface implementation.
+// Identity columns are inserted with "SET IDENTITY_INSERT" instead.
+func (*sqlserver) generatedColumns(q queryable) (*generatedColumns, error) {
+	const query = `
+		SELECT
+			OBJECT_SCHEMA_NAME(object_id),
+			OBJECT_NAME(object_id),
+			name,
+			CAST(CASE WHEN OBJECT_SCHEMA_NAME(object_id) = SCHEMA_NAME() THEN 1 ELSE 0 END AS BIT),
+			CAST(0 AS BIT)
+		FROM sys.computed_columns
+	`
+	return queryGeneratedColumns(q, query)
+}
+
+// analyzeTableSQL is an analyzer interface implementation.
+func (h *sqlserver) analyzeTableSQL(tableName string) string {
+	return fmt.Sprintf("UPDATE STATISTICS %s", h.quoteKeyword(tableName))
+}
+
+// savepointSQL is a savepointer interface implementation. SQL Server savepoints
+// can't be released.
+func (*sqlserver) savepointSQL(name string) (create, rollback, release string) {
+	return "SAVE TRANSACTION " + name, "ROLLBACK TRANSACTION " + name, ""
 }
@@ -3,6 +3,7 @@
 package testfixtures
 
 import (
+	"database/sql"
 	"os"
 	"testing"
 
@@ -19,6 +20,17 @@ func TestSQLServer(t *testing.T) {
 	)
 }
 
+func TestSQLServerWithNamedParams(t *testing.T) {
+	testLoader(
+		t,
+		"sqlserver",
+		os.Getenv("SQLSERVER_CONN_STRING"),
+		"testdata/schema/sqlserver.sql",
+		DangerousSkipTestDatabaseCheck(),
+		UseNamedParams(),
+	)
+}
+
 func TestDeprecatedMssql(t *testing.T) {
 	testLoader(
 		t,
@@ -28,3 +40,80 @@ func TestDeprecatedMssql(t *testing.T) {
 		DangerousSkipTestDatabaseCheck(),
 	)
 }
+
+func TestSQLServerWithSchemaIdentityTable(t *testing.T) {
+	db, err := sql.Open("sqlserver", os.Getenv("SQLSERVER_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("IF SCHEMA_ID('sales') IS NULL EXEC('CREATE SCHEMA sales')"); err != nil {
+		t.Fatalf("cannot create schema: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE [sales].[Order-Items] (id INT IDENTITY PRIMARY KEY, name NVARCHAR(255) NOT NULL)"); err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE [sales].[Order-Items]; DROP SCHEMA sales"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlserver"),
+		DangerousSkipTestDatabaseCheck(),
+		Directory("testdata/fixtures_quoted"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "sales.Order-Items", 2)
+}
+
+func TestSQLServerCaptureInsertedIDsOnIdentityTable(t *testing.T) {
+	db, err := sql.Open("sqlserver", os.Getenv("SQLSERVER_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("CREATE TABLE invoices (id INT IDENTITY PRIMARY KEY, number NVARCHAR(255) NOT NULL)"); err != nil {
+		t.Fatalf("cannot create table: %v", err)
+	}
+	defer func() {
+		if _, err := db.Exec("DROP TABLE invoices"); err != nil {
+			t.Errorf("cannot drop table: %v", err)
+		}
+	}()
+
+	l, err := New(
+		Database(db),
+		Dialect("sqlserver"),
+		DangerousSkipTestDatabaseCheck(),
+		CaptureInsertedIDs(),
+		Files("testdata/fixtures_generated_ids/invoices.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := l.Load(); err != nil {
+		t.Fatalf("cannot load fixtures: %v", err)
+	}
+	assertCount(t, l, "invoices", 2)
+
+	for _, label := range []string{"first", "second"} {
+		id, err := l.InsertedIDByLabel("invoices", label)
+		if err != nil {
+			t.Fatalf("cannot get inserted id: %v", err)
+		}
+		var number string
+		if err := db.QueryRow("SELECT number FROM invoices WHERE id = @p1", id).Scan(&number); err != nil {
+			t.Errorf("cannot find invoice %v: %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package testfixtures
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reservedKeys are the special keys of records, which are not columns.
+var reservedKeys = []string{defaultsKey, castsKey, labelKey, tagsKey, repeatKey, extendsKey}
+
+// StrictKeys makes Load check, before changing anything, that the keys of
+// the records are columns of their table, failing with the name of the
+// first one which isn't. This catches typos in the names of the columns and
+// of the reserved keys, like "_tag" instead of "_tags", which would
+// otherwise only fail when inserting the record.
+func StrictKeys(strict bool) func(*Loader) error {
+	return func(l *Loader) error {
+		l.strictKeys = strict
+		return nil
+	}
+}
+
+// checkKeys returns an error if a key of a record of the files is not a
+// column of its table. See StrictKeys.
+func (l *Loader) checkKeys(files []*fixtureFile) error {
+	columns := make(map[string][]string)
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		if _, ok := columns[tableName]; !ok {
+			tableColumns, err := l.tableColumns(tableName)
+			if err != nil {
+				return fmt.Errorf("testfixtures: could not get the columns of table %s: %w", tableName, err)
+			}
+			columns[tableName] = tableColumns
+		}
+
+		for i, record := range file.records {
+			values, ok := record.values.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, fmt.Sprint(key))
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				if key == repeatKey || hasColumn(columns[tableName], key) {
+					continue
+				}
+				msg := fmt.Sprintf(`%s of file %s has the key "%s", which is not a column of table %s`, recordName(i, record.label), file.fileName, key, tableName)
+				if strings.HasPrefix(key, "_") {
+					msg += fmt.Sprintf(" nor a reserved key (%s)", strings.Join(reservedKeys, ", "))
+				}
+				return fmt.Errorf("testfixtures: %s", msg)
+			}
+		}
+	}
+	return nil
+}
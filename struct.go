@@ -0,0 +1,104 @@
+package testfixtures
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// FixtureFromStruct generates the YAML for a fixture of the given table from
+// a slice of structs (or pointers to structs). Column names are read from the
+// "db" tag of each field, as in `db:"created_at"`. Fields tagged with `db:"-"`
+// and unexported fields are ignored, fields without a tag use the lowercased
+// field name and embedded structs have their fields inlined.
+//
+// The generated YAML declares the table name, so it should be loaded with
+// the FilesMultiTables option.
+func FixtureFromStruct(table string, rows interface{}) ([]byte, error) {
+	value := reflect.ValueOf(rows)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return nil, fmt.Errorf("testfixtures: rows must be a slice of structs, got %T", rows)
+	}
+
+	records := make([]yaml.MapSlice, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		row := reflect.Indirect(value.Index(i))
+		if row.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("testfixtures: rows must be a slice of structs, got %T", rows)
+		}
+		record, err := structToRecord(row)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return yaml.Marshal(yaml.MapSlice{{Key: table, Value: records}})
+}
+
+func structToRecord(row reflect.Value) (yaml.MapSlice, error) {
+	var record yaml.MapSlice
+
+	rowType := row.Type()
+	for i := 0; i < rowType.NumField(); i++ {
+		field := rowType.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		column := strings.Split(tag, ",")[0]
+
+		fieldValue := row.Field(i)
+		if field.Anonymous && column == "" {
+			embedded := reflect.Indirect(fieldValue)
+			if embedded.Kind() == reflect.Invalid {
+				continue
+			}
+			if embedded.Kind() == reflect.Struct && !field.Type.Implements(valuerType) {
+				embeddedRecord, err := structToRecord(embedded)
+				if err != nil {
+					return nil, err
+				}
+				record = append(record, embeddedRecord...)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if column == "" {
+			column = strings.ToLower(field.Name)
+		}
+
+		value, err := structFieldValue(fieldValue)
+		if err != nil {
+			return nil, fmt.Errorf(`testfixtures: could not get value of field "%s": %w`, field.Name, err)
+		}
+		record = append(record, yaml.MapItem{Key: column, Value: value})
+	}
+
+	return record, nil
+}
+
+func structFieldValue(value reflect.Value) (interface{}, error) {
+	if value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil, nil
+	}
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil {
+			return nil, err
+		}
+		return convertValue(v), nil
+	}
+	return convertValue(reflect.Indirect(value).Interface()), nil
+}
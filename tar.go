@@ -0,0 +1,76 @@
+package testfixtures
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+)
+
+// FilesFromTar makes Loader load the fixture files of a tar archive, like a
+// fixtures bundle distributed with a release. The table of each file is its
+// base name, so "fixtures/posts.yml" is loaded on "posts". Directories and
+// files without a recognized extension (see Extensions) are skipped.
+//
+// The archive is read once, so Reload loads the same files again.
+func FilesFromTar(r io.Reader) func(*Loader) error {
+	var files []*fixtureFile
+	return fixtureSource(func(l *Loader) ([]*fixtureFile, error) {
+		if files == nil {
+			var err error
+			if files, err = l.readTar(r); err != nil {
+				return nil, err
+			}
+		}
+
+		fixtureFiles := make([]*fixtureFile, 0, len(files))
+		for _, f := range files {
+			fixture := &fixtureFile{
+				path:     f.path,
+				fileName: f.fileName,
+				content:  f.content,
+			}
+			if err := l.processFileTemplate(fixture); err != nil {
+				return nil, err
+			}
+			fixtureFiles = append(fixtureFiles, fixture)
+		}
+		return fixtureFiles, nil
+	})
+}
+
+// readTar returns the fixture files of a tar archive, without processing
+// them as templates.
+func (l *Loader) readTar(r io.Reader) ([]*fixtureFile, error) {
+	extensions := l.extensions
+	if extensions == nil {
+		extensions = defaultExtensions
+	}
+
+	files := []*fixtureFile{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("testfixtures: could not read tar archive: %w", err)
+		}
+		if !header.FileInfo().Mode().IsRegular() || !containsString(extensions, path.Ext(header.Name)) {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf(`testfixtures: could not read file "%s" of tar archive: %w`, header.Name, err)
+		}
+		files = append(files, &fixtureFile{
+			path:     header.Name,
+			fileName: path.Base(header.Name),
+			content:  content,
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package testfixtures
+
+import "fmt"
+
+// AdminDatabase sets a connection to another database of the same server,
+// like "postgres", used by CreateTemplate and RestoreFromTemplate to create
+// and drop databases, which can't be done while connected to them.
+func AdminDatabase(db DB) func(*Loader) error {
+	return func(l *Loader) error {
+		l.adminDB = newDBHandle(db)
+		return nil
+	}
+}
+
+// CreateTemplate loads the fixtures, then creates the database name as a
+// copy of the database, to be restored with RestoreFromTemplate. A database
+// with the same name is dropped first.
+//
+// The sessions connected to the database are terminated, as PostgreSQL
+// requires when copying it, including the idle connections of the DB given
+// to Database: the next query on them fails, unless the DB is reopened.
+//
+// Only supported on PostgreSQL, with AdminDatabase. Both names must look
+// like the ones of test databases, see EnsureTestDatabase.
+func (l *Loader) CreateTemplate(name string) error {
+	dbName, err := l.templateDatabaseNames(name)
+	if err != nil {
+		return err
+	}
+	if err := l.Load(); err != nil {
+		return err
+	}
+	if err := l.helper.(templater).createFromTemplate(l.adminDB, name, dbName); err != nil {
+		return fmt.Errorf(`testfixtures: could not create template "%s": %w`, name, err)
+	}
+	return nil
+}
+
+// RestoreFromTemplate drops the database and creates it again as a copy of
+// the template created with CreateTemplate, which is faster than loading the
+// fixtures of large databases. The next Load loads all the tables.
+//
+// The sessions connected to the database are terminated, including the
+// connections of the DB given to Database: it must be reopened, or the next
+// query on each of its connections fails.
+//
+// Only supported on PostgreSQL, with AdminDatabase. Both names must look
+// like the ones of test databases, see EnsureTestDatabase.
+func (l *Loader) RestoreFromTemplate(name string) error {
+	dbName, err := l.templateDatabaseNames(name)
+	if err != nil {
+		return err
+	}
+	if err := l.helper.(templater).createFromTemplate(l.adminDB, dbName, name); err != nil {
+		return fmt.Errorf(`testfixtures: could not restore template "%s": %w`, name, err)
+	}
+	l.loadedTables = nil
+	return nil
+}
+
+// templateDatabaseNames returns the name of the database, after checking
+// templates are supported and the database and the template names look like
+// the ones of test databases.
+func (l *Loader) templateDatabaseNames(template string) (string, error) {
+	if _, ok := l.helper.(templater); !ok {
+		return "", fmt.Errorf("testfixtures: templates are not supported by this dialect")
+	}
+	if l.adminDB == nil {
+		return "", fmt.Errorf("testfixtures: templates require AdminDatabase")
+	}
+	if template == "" {
+		return "", fmt.Errorf("testfixtures: the template requires a name")
+	}
+
+	dbName, err := l.currentDatabaseName()
+	if err != nil {
+		return "", err
+	}
+	if dbName == template {
+		return "", fmt.Errorf(`testfixtures: the template can't be the database "%s"`, dbName)
+	}
+	if !l.skipTestDatabaseCheck {
+		for _, name := range []string{dbName, template} {
+			if err := l.ensureTestDatabaseName(name); err != nil {
+				return "", err
+			}
+		}
+	}
+	return dbName, nil
+}
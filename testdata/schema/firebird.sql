@@ -0,0 +1,66 @@
+-- Firebird has no "DROP TABLE IF EXISTS", so this schema is meant to be
+-- loaded on an empty database.
+
+CREATE TABLE posts (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,title VARCHAR(255) NOT NULL
+	,content BLOB SUB_TYPE TEXT NOT NULL
+	,created_at TIMESTAMP NOT NULL
+	,updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE tags (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,name VARCHAR(255) NOT NULL
+	,created_at TIMESTAMP NOT NULL
+	,updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE posts_tags (
+	post_id INTEGER NOT NULL
+	,tag_id INTEGER NOT NULL
+	,PRIMARY KEY (post_id, tag_id)
+	,FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+	,FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+CREATE TABLE comments (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,post_id INTEGER NOT NULL
+	,author_name VARCHAR(255) NOT NULL
+	,author_email VARCHAR(255) NOT NULL
+	,content BLOB SUB_TYPE TEXT NOT NULL
+	,created_at TIMESTAMP NOT NULL
+	,updated_at TIMESTAMP NOT NULL
+	,FOREIGN KEY (post_id) REFERENCES posts (id) ON DELETE CASCADE
+);
+
+CREATE TABLE votes (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,comment_id INTEGER NOT NULL
+	,created_at TIMESTAMP NOT NULL
+	,updated_at TIMESTAMP NOT NULL
+	,FOREIGN KEY (comment_id) REFERENCES comments (id) ON DELETE CASCADE
+);
+
+CREATE TABLE users (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,attributes BLOB SUB_TYPE TEXT NOT NULL
+);
+
+CREATE TABLE assets (
+	id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY
+	,data BLOB NOT NULL
+);
+
+CREATE TABLE nullables (
+	id INTEGER PRIMARY KEY
+	,text_value VARCHAR(255)
+);
+
+CREATE TABLE employees (
+	id INTEGER PRIMARY KEY
+	,name VARCHAR(255) NOT NULL
+	,manager_id INTEGER
+	,FOREIGN KEY (manager_id) REFERENCES employees (id)
+);
@@ -5,6 +5,8 @@ DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS tags;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS nullables;
+DROP TABLE IF EXISTS employees;
 
 CREATE TABLE posts (
 	id INT PRIMARY KEY AUTO_INCREMENT
@@ -57,3 +59,15 @@ CREATE TABLE assets (
 	id INT PRIMARY KEY AUTO_INCREMENT
 	,data BLOB NOT NULL
 );
+
+CREATE TABLE nullables (
+	id INT PRIMARY KEY
+	,text_value VARCHAR(255) NULL
+);
+
+CREATE TABLE employees (
+	id INT PRIMARY KEY
+	,name VARCHAR(255) NOT NULL
+	,manager_id INT NULL
+	,FOREIGN KEY (manager_id) REFERENCES employees (id)
+);
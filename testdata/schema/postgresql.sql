@@ -5,6 +5,8 @@ DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS tags;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS nullables;
+DROP TABLE IF EXISTS employees;
 
 CREATE TABLE posts (
 	id SERIAL PRIMARY KEY
@@ -57,3 +59,15 @@ CREATE TABLE assets (
 	id SERIAL PRIMARY KEY NOT NULL
 	,data BYTEA NOT NULL
 );
+
+CREATE TABLE nullables (
+	id INTEGER PRIMARY KEY NOT NULL
+	,text_value VARCHAR(255) NULL
+);
+
+CREATE TABLE employees (
+	id INTEGER PRIMARY KEY NOT NULL
+	,name VARCHAR(255) NOT NULL
+	,manager_id INTEGER NULL
+	,FOREIGN KEY (manager_id) REFERENCES employees (id)
+);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS moods;
+DROP TYPE IF EXISTS mood;
+
+CREATE TYPE mood AS ENUM ('sad', 'ok', 'happy');
+
+CREATE TABLE moods (
+	id INTEGER PRIMARY KEY NOT NULL
+	,mood mood NOT NULL
+);
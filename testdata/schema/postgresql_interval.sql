@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS durations;
+
+CREATE TABLE durations (
+	id INTEGER PRIMARY KEY NOT NULL
+	,value INTERVAL NOT NULL
+);
@@ -0,0 +1,8 @@
+DROP SCHEMA IF EXISTS fixtures_schema CASCADE;
+
+CREATE SCHEMA fixtures_schema;
+
+CREATE TABLE fixtures_schema.settings (
+	id INTEGER PRIMARY KEY NOT NULL
+	,name VARCHAR(255) NOT NULL
+);
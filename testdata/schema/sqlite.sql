@@ -7,6 +7,8 @@ DROP TABLE IF EXISTS posts;
 DROP TABLE IF EXISTS tags;
 DROP TABLE IF EXISTS users;
 DROP TABLE IF EXISTS assets;
+DROP TABLE IF EXISTS nullables;
+DROP TABLE IF EXISTS employees;
 
 CREATE TABLE posts (
 	id INT PRIMARY KEY
@@ -51,7 +53,7 @@ CREATE TABLE votes (
 );
 
 CREATE TABLE users (
-	id INT PRIMARY KEY
+	id INTEGER PRIMARY KEY
 	,attributes TEXT NOT NULL
 );
 
@@ -59,3 +61,15 @@ CREATE TABLE assets (
 	id INT PRIMARY KEY
 	,data BINARY NOT NULL
 );
+
+CREATE TABLE nullables (
+	id INT PRIMARY KEY
+	,text_value VARCHAR(255) NULL
+);
+
+CREATE TABLE employees (
+	id INT PRIMARY KEY
+	,name VARCHAR(255) NOT NULL
+	,manager_id INT NULL
+	,FOREIGN KEY (manager_id) REFERENCES employees (id)
+);
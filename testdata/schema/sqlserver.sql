@@ -10,6 +10,8 @@ IF OBJECT_ID('posts', 'U') IS NOT NULL DROP TABLE posts;
 IF OBJECT_ID('tags', 'U') IS NOT NULL DROP TABLE tags;
 IF OBJECT_ID('users', 'U') IS NOT NULL DROP TABLE users;
 IF OBJECT_ID('assets', 'U') IS NOT NULL DROP TABLE assets;
+IF OBJECT_ID('nullables', 'U') IS NOT NULL DROP TABLE nullables;
+IF OBJECT_ID('employees', 'U') IS NOT NULL DROP TABLE employees;
 
 CREATE TABLE posts (
 	id INT IDENTITY PRIMARY KEY
@@ -71,3 +73,15 @@ CREATE TABLE assets (
 	id INT IDENTITY PRIMARY KEY NOT NULL
 	,data VARBINARY(MAX) NOT NULL
 );
+
+CREATE TABLE nullables (
+	id INT PRIMARY KEY NOT NULL
+	,text_value VARCHAR(255) NULL
+);
+
+CREATE TABLE employees (
+	id INT PRIMARY KEY NOT NULL
+	,name VARCHAR(255) NOT NULL
+	,manager_id INT NULL
+	,FOREIGN KEY (manager_id) REFERENCES employees (id)
+);
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+DROP TABLE IF EXISTS metrics;
+
+CREATE TABLE metrics (
+	time TIMESTAMPTZ NOT NULL
+	,device_id INTEGER NOT NULL
+	,value DOUBLE PRECISION NOT NULL
+);
+
+-- Fixtures usually span a few hours or days. A small chunk time interval
+-- makes them spread over several chunks, so cleaning hypertables with many
+-- chunks is covered by the tests. On real databases the interval should fit
+-- the data instead: TimescaleDB recommends chunks of about 25% of the memory.
+SELECT create_hypertable('metrics', 'time', chunk_time_interval => INTERVAL '1 day');
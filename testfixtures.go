@@ -2,29 +2,105 @@ package testfixtures // import "github.com/go-testfixtures/testfixtures/v3"
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
 
+	"golang.org/x/text/encoding"
 	"gopkg.in/yaml.v2"
 )
 
 // Loader is the responsible to loading fixtures.
 type Loader struct {
-	db            *sql.DB
+	db            dbHandle
 	helper        helper
 	fixturesFiles []*fixtureFile
 
-	skipTestDatabaseCheck bool
-	location              *time.Location
+	// fixtureSources read the fixture files, see Reload.
+	fixtureSources []func(*Loader) ([]*fixtureFile, error)
+
+	scenarioPaths       map[string][]string
+	scenarios           map[string][]*fixtureFile
+	appendScenarioFiles bool
+
+	// loadedTables are the fixture files last loaded on each table.
+	loadedTables map[string][]*fixtureFile
+
+	skipTestDatabaseCheck    bool
+	testDatabaseRegexp       *regexp.Regexp
+	skipReferentialIntegrity bool
+	databaseName             string
+	concurrency              int
+	analyzeAfterLoad         bool
+	analyzeBestEffort        bool
+	continueOnError          bool
+	skipDuplicateKeysCheck   bool
+	strictYAML               bool
+	strictKeys               bool
+	rand                     *rand.Rand
+	bcryptCost               int
+	bcryptHashes             map[string]string
+	fileValues               bool
+	envValues                bool
+	labelReferences          bool
+	cascadeCleanup           bool
+	defaultEncoding          encoding.Encoding
+	fileEncodings            map[string]encoding.Encoding
+	targetSchemas            []string
+	schema                   string
+	recursive                bool
+	requireReferencedTables  bool
+	schemaFixtureFiles       map[string]map[*fixtureFile]*fixtureFile
+	clock                    func() time.Time
+	copyFrom                 bool
+	order                    map[string]int
+	dependencyOrder          bool
+	perTableTransaction      bool
+	allowMissingFiles        bool
+	allowEmpty               bool
+	mergeDirectories         bool
+	loadTags                 []string
+	captureInsertedIDs       bool
+	extensions               []string
+	nullifyBeforeDelete      map[string][]string
+	deleteWhere              map[string]string
+	columnCasts              map[string]map[string]string
+	jsonColumns              map[string]map[string]bool
+	columnTypes              map[string]map[string]string
+	upsert                   bool
+	primaryKeys              map[string][]string
+	beforeLoadSQL            []string
+	debugConstraintErrors    bool
+	progress                 func(ProgressEvent)
+	progressEveryRows        int
+	logger                   func(sql string, args []interface{})
+	afterLoadSQL             []string
+	aroundTable              map[string][]func(*sql.Tx, func() error) error
+	rollbackFn               func(*sql.Tx) error
+	contentFilters           []func(string, []byte) ([]byte, error)
+	columnEncoders           map[string]map[string]func(interface{}) (interface{}, error)
+	keepGeneratedColumns     bool
+	generatedColumns         *generatedColumns
+	adminDB                  dbHandle
+	missingFiles             []string
+	watchInterval            time.Duration
+	readFileStates           fileStates
+	uuidNamespace            *[16]byte
+	location                 *time.Location
+	nullValue                *string
 
 	template           bool
 	templateFuncs      template.FuncMap
@@ -35,22 +111,53 @@ type Loader struct {
 }
 
 type fixtureFile struct {
-	path       string
-	fileName   string
+	path     string
+	fileName string
+	fromDir  bool
+	// part is true for the files with part of the records of a table, like
+	// "posts.2.yml". See markPartFiles.
+	part bool
+	// sourcePath is the file the records were read from, when it's not
+	// path, like for the tables of the files given with FilesMultiTables.
+	sourcePath string
 	content    []byte
+	records    fixtureRecords
 	insertSQLs []insertSQL
+
+	// insertedIDs are the primary keys of the records inserted by the last
+	// load, with CaptureInsertedIDs.
+	insertedIDs []interface{}
+	// insertDuration is the time the last load took to insert the records.
+	insertDuration time.Duration
 }
 
 type insertSQL struct {
-	sql    string
-	params []interface{}
+	sql     string
+	params  []interface{}
+	label   string
+	columns []string // the column of each param
+
+	// relativeTimes are the params given relative to the time of the load,
+	// like "NOW() - 3d", by index. They are set by resolveRelativeTimes.
+	relativeTimes map[int]time.Duration
 }
 
 var (
 	testDatabaseRegexp = regexp.MustCompile("(?i)test")
 
-	errDatabaseIsRequired = fmt.Errorf("testfixtures: database is required")
-	errDialectIsRequired  = fmt.Errorf("testfixtures: dialect is required")
+	defaultExtensions = []string{".yml", ".yaml"}
+
+	// ErrNoFixtureFiles is returned (wrapped) when a directory has no fixture
+	// files, unless AllowEmpty is given.
+	ErrNoFixtureFiles = errors.New("testfixtures: no fixture files found")
+
+	// ErrNotTestDatabase is matched, with errors.Is, by the errors returned
+	// when the database doesn't look like a test one. See EnsureTestDatabase
+	// and NotTestDatabaseError.
+	ErrNotTestDatabase = errors.New("testfixtures: database does not appear to be a test database")
+
+	errDatabaseIsRequired = errors.New("testfixtures: database is required")
+	errDialectIsRequired  = errors.New("testfixtures: dialect is required")
 )
 
 // New instantiates a new Loader instance. The "Database" and "Driver"
@@ -75,20 +182,66 @@ func New(options ...func(*Loader) error) (*Loader, error) {
 		return nil, errDialectIsRequired
 	}
 
+	if _, ok := l.helper.(upserter); l.upsert && !ok {
+		return nil, fmt.Errorf("testfixtures: Upsert is not supported by this dialect")
+	}
+	if _, ok := l.helper.(insertedIDHelper); l.captureInsertedIDs && !ok {
+		return nil, fmt.Errorf("testfixtures: CaptureInsertedIDs is not supported by this dialect")
+	}
+	if _, ok := l.helper.(foreignKeyLister); l.dependencyOrder && !ok {
+		return nil, fmt.Errorf("testfixtures: DependencyOrder is not supported by this dialect")
+	}
+	if _, ok := l.helper.(foreignKeyLister); l.requireReferencedTables && !ok {
+		return nil, fmt.Errorf("testfixtures: RequireReferencedTables is not supported by this dialect")
+	}
+	if _, ok := l.helper.(foreignKeyLister); l.cascadeCleanup && !ok {
+		return nil, fmt.Errorf("testfixtures: CascadeCleanup is not supported by this dialect")
+	}
+	if l.dependencyOrder && l.order != nil {
+		return nil, fmt.Errorf("testfixtures: DependencyOrder can't be used together with Order")
+	}
+	if _, ok := l.helper.(caster); len(l.columnCasts) > 0 && !ok {
+		return nil, fmt.Errorf("testfixtures: ColumnCast is not supported by this dialect")
+	}
+	if _, ok := l.helper.(columnTyper); len(l.columnTypes) > 0 && !ok {
+		return nil, fmt.Errorf("testfixtures: ColumnType is not supported by this dialect")
+	}
+	if l.continueOnError && l.perTableTransaction {
+		return nil, fmt.Errorf("testfixtures: ContinueOnError can't be used together with PerTableTransaction")
+	}
+	if l.continueOnError && l.limitsMutations() {
+		return nil, fmt.Errorf("testfixtures: ContinueOnError is not supported by this dialect")
+	}
+	if _, ok := l.helper.(analyzer); l.analyzeAfterLoad && !ok {
+		return nil, fmt.Errorf("testfixtures: AnalyzeAfterLoad is not supported by this dialect")
+	}
+
+	if err := l.checkSchema(); err != nil {
+		return nil, err
+	}
+	if _, ok := l.helper.(copier); l.copyFrom && !ok {
+		return nil, fmt.Errorf("testfixtures: UseCopyFrom is not supported by this dialect")
+	}
+
 	if err := l.helper.init(l.db); err != nil {
 		return nil, err
 	}
+	l.loadGeneratedColumns()
+	markPartFiles(l.fixturesFiles)
+	l.overlayDirectories()
 	if err := l.buildInsertSQLs(); err != nil {
 		return nil, err
 	}
+	l.readFileStates = l.fileStates()
 
 	return l, nil
 }
 
-// Database sets an existing sql.DB instant to Loader.
-func Database(db *sql.DB) func(*Loader) error {
+// Database sets an existing database handle to Loader. It's usually a
+// *sql.DB, but can be anything implementing the DB interface.
+func Database(db DB) func(*Loader) error {
 	return func(l *Loader) error {
-		l.db = db
+		l.db = newDBHandle(db)
 		return nil
 	}
 }
@@ -109,15 +262,27 @@ func Dialect(dialect string) func(*Loader) error {
 }
 
 func helperForDialect(dialect string) (helper, error) {
+	if h, ok := customHelperForDialect(dialect); ok {
+		return h, nil
+	}
+
 	switch dialect {
-	case "postgres", "postgresql", "timescaledb", "pgx":
+	case "postgres", "postgresql", "pgx":
 		return &postgreSQL{}, nil
+	case "timescaledb":
+		return &postgreSQL{timescaleDB: true}, nil
 	case "mysql", "mariadb":
 		return &mySQL{}, nil
 	case "sqlite", "sqlite3":
 		return &sqlite{}, nil
 	case "mssql", "sqlserver":
 		return &sqlserver{}, nil
+	case "firebird", "firebirdsql":
+		return &firebird{}, nil
+	case "duckdb":
+		return &duckDB{}, nil
+	case "spanner":
+		return &spanner{}, nil
 	default:
 		return nil, fmt.Errorf(`testfixtures: unrecognized dialect "%s"`, dialect)
 	}
@@ -140,6 +305,23 @@ func UseAlterConstraint() func(*Loader) error {
 	}
 }
 
+// UseNamedParams makes Loader insert the records with "@p1", "@p2", ...
+// placeholders, binding the values with sql.Named, instead of detecting
+// whether the driver accepts "?" placeholders. This avoids values bound to the
+// wrong placeholder by some driver versions.
+//
+// Only valid for SQL Server. Returns an error otherwise.
+func UseNamedParams() func(*Loader) error {
+	return func(l *Loader) error {
+		h, ok := l.helper.(*sqlserver)
+		if !ok {
+			return fmt.Errorf("testfixtures: UseNamedParams is only valid for SQL Server databases")
+		}
+		h.namedParams = true
+		return nil
+	}
+}
+
 // UseDropConstraint If true, the constraints will be dropped
 // and recreated after loading fixtures. This is implemented mainly to support
 // CockroachDB which does not support other methods.
@@ -156,10 +338,46 @@ func UseDropConstraint() func(*Loader) error {
 	}
 }
 
+// UseDeferredConstraints makes Loader defer the checks of the foreign keys
+// to the end of the transaction loading the fixtures, with "SET CONSTRAINTS
+// ALL DEFERRED", instead of disabling the triggers, which requires SUPERUSER
+// privileges. Unlike UseAlterConstraint, the constraints are not altered, so
+// it only works when they are declared DEFERRABLE.
+//
+// Only valid for PostgreSQL. Returns an error otherwise. Not supported
+// together with PerTableTransaction.
+func UseDeferredConstraints(useDeferredConstraints bool) func(*Loader) error {
+	return func(l *Loader) error {
+		pgHelper, ok := l.helper.(*postgreSQL)
+		if !ok {
+			return fmt.Errorf("testfixtures: UseDeferredConstraints is only valid for PostgreSQL databases")
+		}
+		pgHelper.useDeferredConstraints = useDeferredConstraints
+		return nil
+	}
+}
+
+// UseTimescaleDB makes Loader aware of TimescaleDB hypertables, which are
+// cleaned with TRUNCATE instead of DELETE. It's the same as using the
+// "timescaledb" dialect, and is useful when the dialect is the name of a
+// PostgreSQL driver.
+//
+// Only valid for PostgreSQL. Returns an error otherwise.
+func UseTimescaleDB() func(*Loader) error {
+	return func(l *Loader) error {
+		pgHelper, ok := l.helper.(*postgreSQL)
+		if !ok {
+			return fmt.Errorf("testfixtures: UseTimescaleDB is only valid for PostgreSQL databases")
+		}
+		pgHelper.timescaleDB = true
+		return nil
+	}
+}
+
 // SkipResetSequences prevents Loader from reseting sequences after loading
 // fixtures.
 //
-// Only valid for PostgreSQL and MySQL. Returns an error otherwise.
+// Only valid for PostgreSQL, MySQL and Firebird. Returns an error otherwise.
 func SkipResetSequences() func(*Loader) error {
 	return func(l *Loader) error {
 		switch helper := l.helper.(type) {
@@ -167,9 +385,29 @@ func SkipResetSequences() func(*Loader) error {
 			helper.skipResetSequences = true
 		case *mySQL:
 			helper.skipResetSequences = true
+		case *firebird:
+			helper.skipResetSequences = true
 		default:
-			return fmt.Errorf("testfixtures: SkipResetSequences is valid for PostgreSQL and MySQL databases")
+			return fmt.Errorf("testfixtures: SkipResetSequences is valid for PostgreSQL, MySQL and Firebird databases")
+		}
+		return nil
+	}
+}
+
+// ResetAllSequences makes Loader reset every sequence of the database after
+// loading fixtures, including the ones of tables without fixtures, so the
+// next value follows the greatest value of the column owning the sequence,
+// like a SERIAL or IDENTITY column. Sequences not owned by a column are reset
+// to the value given with ResetSequencesTo.
+//
+// Only valid for PostgreSQL. Returns an error otherwise.
+func ResetAllSequences(resetAll bool) func(*Loader) error {
+	return func(l *Loader) error {
+		helper, ok := l.helper.(*postgreSQL)
+		if !ok {
+			return fmt.Errorf("testfixtures: ResetAllSequences is only valid for PostgreSQL databases")
 		}
+		helper.resetAllSequences = resetAll
 		return nil
 	}
 }
@@ -178,7 +416,7 @@ func SkipResetSequences() func(*Loader) error {
 //
 // Defaults to 10000.
 //
-// Only valid for PostgreSQL and MySQL. Returns an error otherwise.
+// Only valid for PostgreSQL, MySQL and Firebird. Returns an error otherwise.
 func ResetSequencesTo(value int64) func(*Loader) error {
 	return func(l *Loader) error {
 		switch helper := l.helper.(type) {
@@ -186,8 +424,10 @@ func ResetSequencesTo(value int64) func(*Loader) error {
 			helper.resetSequencesTo = value
 		case *mySQL:
 			helper.resetSequencesTo = value
+		case *firebird:
+			helper.resetSequencesTo = value
 		default:
-			return fmt.Errorf("testfixtures: ResetSequencesTo is only valid for PostgreSQL and MySQL databases")
+			return fmt.Errorf("testfixtures: ResetSequencesTo is only valid for PostgreSQL, MySQL and Firebird databases")
 		}
 		return nil
 	}
@@ -202,382 +442,1872 @@ func DangerousSkipTestDatabaseCheck() func(*Loader) error {
 	}
 }
 
-// Directory informs Loader to load YAML files from a given directory.
-func Directory(dir string) func(*Loader) error {
+// DatabaseName sets the name of the database checked by EnsureTestDatabase,
+// instead of querying it, which some drivers can't do.
+func DatabaseName(name string) func(*Loader) error {
 	return func(l *Loader) error {
-		fixtures, err := l.fixturesFromDir(dir)
-		if err != nil {
-			return err
+		if name == "" {
+			return fmt.Errorf("testfixtures: DatabaseName requires a name")
 		}
-		l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		l.databaseName = name
 		return nil
 	}
 }
 
-// Files informs Loader to load a given set of YAML files.
-func Files(files ...string) func(*Loader) error {
+// DatabaseNameRegexp sets the pattern a database name must match to be
+// considered a test database, instead of the default, which requires the
+// name to contain "test" (case-insensitive).
+func DatabaseNameRegexp(re *regexp.Regexp) func(*Loader) error {
 	return func(l *Loader) error {
-		fixtures, err := l.fixturesFromFiles(files...)
-		if err != nil {
-			return err
+		if re == nil {
+			return fmt.Errorf("testfixtures: DatabaseNameRegexp requires a non-nil regexp")
 		}
-		l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		l.testDatabaseRegexp = re
 		return nil
 	}
 }
 
-// Paths inform Loader to load a given set of YAML files and directories.
-func Paths(paths ...string) func(*Loader) error {
+// SkipReferentialIntegrity prevents Loader from disabling referential
+// integrity (foreign keys, triggers, etc) while loading fixtures. This is
+// useful if the database user doesn't have the privileges required to do so.
+//
+// Fixtures are loaded in a plain transaction, so files must be given in
+// dependency order: tables are cleaned in the reverse order and filled in the
+// given order. Note that sequences are not reset in this mode.
+func SkipReferentialIntegrity() func(*Loader) error {
 	return func(l *Loader) error {
-		fixtures, err := l.fixturesFromPaths(paths...)
-		if err != nil {
-			return err
-		}
-		l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		l.skipReferentialIntegrity = true
 		return nil
 	}
 }
 
-// Files informs Loader to load a given set of YAML files as mutiple fixtures.
-func FilesMultiTables(files ...string) func(*Loader) error {
+// NullifyBeforeDelete makes Loader set the given columns of a table to NULL
+// before cleaning it. This is useful for tables with self-referencing foreign
+// keys (like "employees.manager_id"), which some databases check row by row
+// while deleting, even when referential integrity is disabled or when it's
+// skipped with SkipReferentialIntegrity.
+//
+// Can be given more than once, for different tables.
+func NullifyBeforeDelete(table string, columns ...string) func(*Loader) error {
 	return func(l *Loader) error {
-		fixtures, err := l.fixturesFromFilesMultiTables(files...)
-		if err != nil {
-			return err
+		if len(columns) == 0 {
+			return fmt.Errorf("testfixtures: NullifyBeforeDelete requires at least one column")
 		}
-		l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		if l.nullifyBeforeDelete == nil {
+			l.nullifyBeforeDelete = make(map[string][]string)
+		}
+		l.nullifyBeforeDelete[table] = append(l.nullifyBeforeDelete[table], columns...)
 		return nil
 	}
 }
 
-// Location makes Loader use the given location by default when parsing
-// dates. If not given, by default it uses the value of time.Local.
-func Location(location *time.Location) func(*Loader) error {
+// DeleteWhere makes Loader only delete the rows of a table matching the given
+// SQL condition before loading its fixtures, instead of all of them. This is
+// useful to keep seed data shared by the tests:
+//
+//     testfixtures.DeleteWhere("users", "tenant_id <> 1")
+//
+// The condition is inserted as is in the "DELETE" statement.
+//
+// Can be given more than once, for different tables. Given again for the
+// same table, the last condition replaces the previous one.
+func DeleteWhere(table, condition string) func(*Loader) error {
 	return func(l *Loader) error {
-		l.location = location
+		if strings.TrimSpace(condition) == "" {
+			return fmt.Errorf("testfixtures: DeleteWhere requires a condition")
+		}
+		if l.deleteWhere == nil {
+			l.deleteWhere = make(map[string]string)
+		}
+		l.deleteWhere[table] = condition
 		return nil
 	}
 }
 
-// Template makes loader process each YAML file as an template using the
-// text/template package.
+// ColumnCast makes Loader cast the values of a column before inserting them,
+// by appending "::" and the given expression to the placeholder. This is
+// useful for PostgreSQL enums and other custom types, which some drivers
+// refuse to bind from a string:
 //
-// For more information on how templates work in Go please read:
-// https://golang.org/pkg/text/template/
+//     testfixtures.ColumnCast("users", "mood", "text::mood")
 //
-// If not given the YAML files are parsed as is.
-func Template() func(*Loader) error {
+// generates "$1::text::mood" instead of "$1" for the "mood" column.
+//
+// Fixture files can also give the casts of their columns in a "_casts"
+// section, which takes precedence:
+//
+//     _casts:
+//       id: uuid
+//       meta: jsonb
+//
+// Can be given more than once, for different columns. Only supported on
+// PostgreSQL and DuckDB, as other databases don't have the "::" syntax.
+func ColumnCast(table, column, castExpr string) func(*Loader) error {
 	return func(l *Loader) error {
-		l.template = true
+		if castExpr == "" {
+			return fmt.Errorf("testfixtures: ColumnCast requires a cast expression")
+		}
+		if l.columnCasts == nil {
+			l.columnCasts = make(map[string]map[string]string)
+		}
+		if l.columnCasts[table] == nil {
+			l.columnCasts[table] = make(map[string]string)
+		}
+		l.columnCasts[table][column] = castExpr
 		return nil
 	}
 }
 
-// TemplateFuncs allow choosing which functions will be available
-// when processing templates.
+// ColumnType tells Loader the type of a column, when the value can't be
+// bound as is. Lists (and objects on DuckDB) given for these columns are
+// converted to the native type of the database instead of JSON, and cast to
+// the given type:
 //
-// For more information see: https://golang.org/pkg/text/template/#Template.Funcs
-func TemplateFuncs(funcs template.FuncMap) func(*Loader) error {
+//     testfixtures.ColumnType("posts", "tags", "text[]")
+//
+// On PostgreSQL, only array types, like "text[]" or "int[]", are handled. On
+// DuckDB, list, struct and map types are.
+//
+// Only valid for PostgreSQL and DuckDB. Can be given more than once, for
+// different columns.
+func ColumnType(table, column, columnType string) func(*Loader) error {
 	return func(l *Loader) error {
-		if !l.template {
-			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateFuns() option`)
+		if strings.TrimSpace(columnType) == "" {
+			return fmt.Errorf("testfixtures: ColumnType requires a type")
 		}
-
-		l.templateFuncs = funcs
+		if l.columnTypes == nil {
+			l.columnTypes = make(map[string]map[string]string)
+		}
+		if l.columnTypes[table] == nil {
+			l.columnTypes[table] = make(map[string]string)
+		}
+		l.columnTypes[table][column] = columnType
 		return nil
 	}
 }
 
-// TemplateDelims allow choosing which delimiters will be used for templating.
-// This defaults to "{{" and "}}".
+// JSONColumns makes Loader convert every value of the given columns to JSON,
+// not only maps and lists, so a JSON column can also hold a string, a number
+// or a boolean:
 //
-// For more information see https://golang.org/pkg/text/template/#Template.Delims
-func TemplateDelims(left, right string) func(*Loader) error {
+//     testfixtures.JSONColumns("users", "attributes", "settings")
+//
+// Values matching NullValue and "RAW=" values are kept as is.
+//
+// Can be given more than once, for different tables.
+func JSONColumns(table string, columns ...string) func(*Loader) error {
 	return func(l *Loader) error {
-		if !l.template {
-			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateDelims() option`)
+		if l.jsonColumns == nil {
+			l.jsonColumns = make(map[string]map[string]bool)
+		}
+		if l.jsonColumns[table] == nil {
+			l.jsonColumns[table] = make(map[string]bool, len(columns))
+		}
+		for _, column := range columns {
+			l.jsonColumns[table][column] = true
 		}
+		return nil
+	}
+}
 
-		l.templateLeftDelim = left
-		l.templateRightDelim = right
+// Upsert makes Loader insert the records on top of the existing data instead
+// of cleaning the tables first. Records that already exist are updated, and
+// rows not present in the fixtures are kept.
+//
+// Existing records are found by their primary key, which is "id" unless set
+// with the PrimaryKey option. Supported by PostgreSQL, SQLite (3.24.0 or
+// newer) and MySQL.
+func Upsert() func(*Loader) error {
+	return func(l *Loader) error {
+		l.upsert = true
 		return nil
 	}
 }
 
-// TemplateOptions allows you to specific which text/template options will
-// be enabled when processing templates.
+// PrimaryKey sets the primary key columns of a table, for when it's not "id".
+// See Upsert.
 //
-// This defaults to "missingkey=zero". Check the available options here:
-// https://golang.org/pkg/text/template/#Template.Option
-func TemplateOptions(options ...string) func(*Loader) error {
+// Can be given more than once, for different tables.
+func PrimaryKey(table string, columns ...string) func(*Loader) error {
 	return func(l *Loader) error {
-		if !l.template {
-			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateOptions() option`)
+		if len(columns) == 0 {
+			return fmt.Errorf("testfixtures: PrimaryKey requires at least one column")
 		}
-
-		l.templateOptions = options
+		if l.primaryKeys == nil {
+			l.primaryKeys = make(map[string][]string)
+		}
+		l.primaryKeys[table] = columns
 		return nil
 	}
 }
 
-// TemplateData allows you to specify which data will be available
-// when processing templates. Data is accesible by prefixing it with a "."
-// like {{.MyKey}}.
-func TemplateData(data interface{}) func(*Loader) error {
+// BeforeLoadSQL sets SQL statements to be executed at the start of Load, in
+// the same transaction used to load the fixtures, like setting the
+// "search_path" with "SET LOCAL" or disabling a trigger.
+//
+// With PerTableTransaction, they are executed on the database session
+// before the first transaction starts.
+func BeforeLoadSQL(statements ...string) func(*Loader) error {
 	return func(l *Loader) error {
-		if !l.template {
-			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateData() option`)
-		}
+		l.beforeLoadSQL = append(l.beforeLoadSQL, statements...)
+		return nil
+	}
+}
 
-		l.templateData = data
+// AfterLoadSQL sets SQL statements to be executed at the end of Load, in
+// the same transaction used to load the fixtures. If any of them fails, the
+// transaction is rolled back.
+//
+// With PerTableTransaction, they are executed on the database session
+// after the last transaction is committed.
+func AfterLoadSQL(statements ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		l.afterLoadSQL = append(l.afterLoadSQL, statements...)
 		return nil
 	}
 }
 
-// EnsureTestDatabase returns an error if the database name does not contains
-// "test".
-func (l *Loader) EnsureTestDatabase() error {
-	dbName, err := l.helper.databaseName(l.db)
-	if err != nil {
-		return err
+// DebugConstraintErrors makes Loader add details to the InsertError returned
+// when a record violates a constraint (like a unique or a foreign key): the
+// record being inserted and the rows already in the table with its primary
+// key, see PrimaryKey, or sharing any value with it if it doesn't give the
+// key. Finding these rows is best-effort, errors doing so are ignored.
+func DebugConstraintErrors() func(*Loader) error {
+	return func(l *Loader) error {
+		l.debugConstraintErrors = true
+		return nil
 	}
-	if !testDatabaseRegexp.MatchString(dbName) {
-		return fmt.Errorf(`testfixtures: database "%s" does not appear to be a test database`, dbName)
+}
+
+// Logger sets a function to be called with each statement used to clean the
+// tables and insert the records, right before it's executed. Useful for
+// debugging. Nothing is logged by default.
+func Logger(logger func(sql string, args []interface{})) func(*Loader) error {
+	return func(l *Loader) error {
+		l.logger = logger
+		return nil
 	}
-	return nil
 }
 
-// Load wipes and after load all fixtures in the database.
-//     if err := fixtures.Load(); err != nil {
-//             ...
-//     }
-func (l *Loader) Load() error {
-	if !l.skipTestDatabaseCheck {
-		if err := l.EnsureTestDatabase(); err != nil {
-			return err
-		}
+// PerTableTransaction makes Loader use a transaction for each fixture file
+// instead of a single transaction for the whole load. This is useful for big
+// datasets, where a single transaction can exceed database limits or hold
+// locks on every table for too long.
+//
+// Referential integrity is kept disabled for the whole database session
+// while loading. Atomicity across tables is lost in this mode: if a file
+// fails to load, the tables loaded before it remain committed and a
+// *PartialLoadError will be returned.
+//
+// Not supported together with UseAlterConstraint and UseDeferredConstraints.
+func PerTableTransaction() func(*Loader) error {
+	return func(l *Loader) error {
+		l.perTableTransaction = true
+		return nil
 	}
+}
 
-	err := l.helper.disableReferentialIntegrity(l.db, func(tx *sql.Tx) error {
-		modifiedTables := make(map[string]bool, len(l.fixturesFiles))
-		for _, file := range l.fixturesFiles {
-			tableName := file.fileNameWithoutExtension()
-			modified, err := l.helper.isTableModified(tx, tableName)
-			if err != nil {
-				return err
-			}
-			modifiedTables[tableName] = modified
-		}
+// AllowMissingFiles makes Loader skip fixture files and directories that
+// don't exist instead of returning an error. The skipped paths can be
+// retrieved with MissingFiles.
+//
+// It must be given before the options that set the fixture files.
+func AllowMissingFiles() func(*Loader) error {
+	return func(l *Loader) error {
+		l.allowMissingFiles = true
+		return nil
+	}
+}
 
-		// Delete existing table data for specified fixtures before populating the data. This helps avoid
-		// DELETE CASCADE constraints when using the `UseAlterConstraint()` option.
-		for _, file := range l.fixturesFiles {
-			modified := modifiedTables[file.fileNameWithoutExtension()]
-			if !modified {
-				continue
-			}
-			if err := file.delete(tx, l.helper); err != nil {
-				return err
-			}
-		}
+// AllowEmpty makes Loader accept directories without fixture files, which
+// are otherwise an error (see ErrNoFixtureFiles).
+//
+// It must be given before the options that set the fixture files.
+func AllowEmpty() func(*Loader) error {
+	return func(l *Loader) error {
+		l.allowEmpty = true
+		return nil
+	}
+}
 
-		for _, file := range l.fixturesFiles {
-			modified := modifiedTables[file.fileNameWithoutExtension()]
-			if !modified {
-				continue
-			}
-			err := l.helper.whileInsertOnTable(tx, file.fileNameWithoutExtension(), func() error {
-				for j, i := range file.insertSQLs {
-					if _, err := tx.Exec(i.sql, i.params...); err != nil {
-						return &InsertError{
-							Err:    err,
-							File:   file.fileName,
-							Index:  j,
-							SQL:    i.sql,
-							Params: i.params,
-						}
-					}
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
+// LoadTags makes Loader only insert the records having any of the given tags
+// in their special "_tags" key, and the records without tags:
+//
+//     - id: 1
+//       name: John
+//       _tags: [premium]
+//
+// Can be given more than once, adding to the tags.
+func LoadTags(tags ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if len(tags) == 0 {
+			return fmt.Errorf("testfixtures: LoadTags requires at least one tag")
 		}
+		l.loadTags = append(l.loadTags, tags...)
 		return nil
-	})
-	if err != nil {
-		return err
 	}
-	return l.helper.afterLoad(l.db)
 }
 
-// InsertError will be returned if any error happens on database while
-// inserting the record.
-type InsertError struct {
-	Err    error
-	File   string
-	Index  int
-	SQL    string
-	Params []interface{}
+// MergeDirectories makes Loader load the files of all the directories having
+// a file for the same table, instead of only the last one.
+func MergeDirectories() func(*Loader) error {
+	return func(l *Loader) error {
+		l.mergeDirectories = true
+		return nil
+	}
 }
 
-func (e *InsertError) Error() string {
-	return fmt.Sprintf(
-		"testfixtures: error inserting record: %v, on file: %s, index: %d, sql: %s, params: %v",
-		e.Err,
-		e.File,
-		e.Index,
-		e.SQL,
-		e.Params,
+// overlayDirectories keeps only the files of the last directory having
+// files for each table, unless MergeDirectories was given. They take the
+// position of the first one, which may matter for foreign keys.
+func (l *Loader) overlayDirectories() {
+	if l.mergeDirectories {
+		return
+	}
+
+	var (
+		last    = make(map[string][]*fixtureFile)
+		lastDir = make(map[string]string)
 	)
-}
+	for _, file := range l.fixturesFiles {
+		if !file.fromDir {
+			continue
+		}
+		// The records of a table may be split in many files of the same
+		// directory.
+		tableName := file.fileNameWithoutExtension()
+		if dir := filepath.Dir(file.path); dir != lastDir[tableName] {
+			last[tableName] = nil
+			lastDir[tableName] = dir
+		}
+		last[tableName] = append(last[tableName], file)
+	}
 
-func (l *Loader) buildInterfacesSlice(records interface{}) ([]interface{}, error) {
-	switch records := records.(type) {
-	case []interface{}:
-		return records, nil
-	case map[interface{}]interface{}:
-		var result []interface{}
-		for _, record := range records {
-			result = append(result, record)
+	files := make([]*fixtureFile, 0, len(l.fixturesFiles))
+	seen := make(map[string]bool, len(last))
+	for _, file := range l.fixturesFiles {
+		tableName := file.fileNameWithoutExtension()
+		if !file.fromDir {
+			files = append(files, file)
+			continue
+		}
+		if !seen[tableName] {
+			files = append(files, last[tableName]...)
+			seen[tableName] = true
 		}
-		return result, nil
 	}
+	l.fixturesFiles = files
+}
 
-	return nil, fmt.Errorf("testfixtures: fixture is not a slice or map")
+// TablePaths returns the paths of the fixture files loaded for each table,
+// which is useful to know which directory won when using overlays.
+func (l *Loader) TablePaths() map[string][]string {
+	paths := make(map[string][]string)
+	for _, file := range l.fixturesFiles {
+		tableName := file.fileNameWithoutExtension()
+		paths[tableName] = append(paths[tableName], file.path)
+	}
+	return paths
 }
 
-func (l *Loader) buildInsertSQLs() error {
-	for _, f := range l.fixturesFiles {
-		var records interface{}
-		if err := yaml.Unmarshal(f.content, &records); err != nil {
-			return fmt.Errorf("testfixtures: could not unmarshal YAML: %w", err)
+// Extensions sets the extensions of the files loaded from directories, given
+// with or without the leading dot. Defaults to ".yml" and ".yaml".
+//
+// It must be given before the options that set the fixture files.
+func Extensions(extensions ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if len(extensions) == 0 {
+			return fmt.Errorf("testfixtures: Extensions requires at least one extension")
 		}
-
-		result, err := l.buildInterfacesSlice(records)
-		if err != nil {
-			return err
+		l.extensions = make([]string, 0, len(extensions))
+		for _, ext := range extensions {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			l.extensions = append(l.extensions, ext)
 		}
+		return nil
+	}
+}
+
+// fixtureSource makes an option adding the fixture files returned by the
+// given function. The function is kept to read the files again on Reload.
+func fixtureSource(source func(*Loader) ([]*fixtureFile, error)) func(*Loader) error {
+	return func(l *Loader) error {
+		fixtures, err := source(l)
+		if err != nil {
+			return err
+		}
+		l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		l.fixtureSources = append(l.fixtureSources, source)
+		return nil
+	}
+}
+
+// Directory informs Loader to load YAML files from a given directory.
+//
+// When many directories have a file for the same table, like a base
+// directory and an overlay one, only the file of the last directory is
+// loaded, unless MergeDirectories is given. See TablePaths.
+func Directory(dir string) func(*Loader) error {
+	return fixtureSource(func(l *Loader) ([]*fixtureFile, error) {
+		return l.fixturesFromDir(dir)
+	})
+}
+
+// Recursive makes Directory and Paths also load the files of the
+// subdirectories of the directories, like when fixtures are grouped by
+// domain. The table of a file is still given by its name, without the
+// subdirectory.
+//
+// The files are read in lexical order of their paths, so when many files are
+// found for the same table, only the last one is loaded, like with many
+// directories, unless MergeDirectories is given. Must be given before the
+// directories.
+func Recursive(recursive bool) func(*Loader) error {
+	return func(l *Loader) error {
+		l.recursive = recursive
+		return nil
+	}
+}
+
+// walkDir returns the paths of the files of a directory and of its
+// subdirectories having one of the extensions, in lexical order.
+func walkDir(dir string, extensions []string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && containsString(extensions, filepath.Ext(p)) {
+			paths = append(paths, filepath.ToSlash(p))
+		}
+		return nil
+	})
+	return paths, err
+}
+
+// Files informs Loader to load a given set of YAML files.
+func Files(files ...string) func(*Loader) error {
+	return fixtureSource(func(l *Loader) ([]*fixtureFile, error) {
+		return l.fixturesFromFiles(files...)
+	})
+}
+
+// Paths inform Loader to load a given set of YAML files and directories.
+func Paths(paths ...string) func(*Loader) error {
+	return fixtureSource(func(l *Loader) ([]*fixtureFile, error) {
+		return l.fixturesFromPaths(paths...)
+	})
+}
+
+// Files informs Loader to load a given set of YAML files as mutiple fixtures.
+func FilesMultiTables(files ...string) func(*Loader) error {
+	return fixtureSource(func(l *Loader) ([]*fixtureFile, error) {
+		return l.fixturesFromFilesMultiTables(files...)
+	})
+}
+
+// Location makes Loader use the given location by default when parsing
+// dates. If not given, by default it uses the value of time.Local.
+func Location(location *time.Location) func(*Loader) error {
+	return func(l *Loader) error {
+		l.location = location
+		return nil
+	}
+}
+
+// NullValue makes Loader insert NULL for any string value equal to the given
+// one. This is useful to force NULL on values produced by templates, where a
+// YAML null can't be easily written.
+//
+// Disabled by default, where only YAML nulls (null or ~) are inserted as NULL.
+func NullValue(value string) func(*Loader) error {
+	return func(l *Loader) error {
+		l.nullValue = &value
+		return nil
+	}
+}
+
+// StrictYAML makes Loader reject the records having the same key more than
+// once, which are otherwise silently decoded with the last value, like in:
+//
+//     - id: 1
+//       title: First post
+//       title: Second post
+//
+// The error gives the file and the line of the duplicate key. When using
+// FilesMultiTables, give it before.
+func StrictYAML() func(*Loader) error {
+	return func(l *Loader) error {
+		l.strictYAML = true
+		return nil
+	}
+}
+
+// Template makes loader process each YAML file as an template using the
+// text/template package.
+//
+// For more information on how templates work in Go please read:
+// https://golang.org/pkg/text/template/
+//
+// If not given the YAML files are parsed as is.
+func Template() func(*Loader) error {
+	return func(l *Loader) error {
+		l.template = true
+		return nil
+	}
+}
+
+// TemplateFuncs allow choosing which functions will be available
+// when processing templates.
+//
+// For more information see: https://golang.org/pkg/text/template/#Template.Funcs
+func TemplateFuncs(funcs template.FuncMap) func(*Loader) error {
+	return func(l *Loader) error {
+		if !l.template {
+			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateFuns() option`)
+		}
+
+		l.templateFuncs = funcs
+		return nil
+	}
+}
+
+// TemplateDelims allow choosing which delimiters will be used for templating.
+// This defaults to "{{" and "}}".
+//
+// For more information see https://golang.org/pkg/text/template/#Template.Delims
+func TemplateDelims(left, right string) func(*Loader) error {
+	return func(l *Loader) error {
+		if !l.template {
+			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateDelims() option`)
+		}
+
+		l.templateLeftDelim = left
+		l.templateRightDelim = right
+		return nil
+	}
+}
+
+// TemplateOptions allows you to specific which text/template options will
+// be enabled when processing templates.
+//
+// This defaults to "missingkey=zero". Check the available options here:
+// https://golang.org/pkg/text/template/#Template.Option
+func TemplateOptions(options ...string) func(*Loader) error {
+	return func(l *Loader) error {
+		if !l.template {
+			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateOptions() option`)
+		}
+
+		l.templateOptions = options
+		return nil
+	}
+}
+
+// TemplateData allows you to specify which data will be available
+// when processing templates. Data is accesible by prefixing it with a "."
+// like {{.MyKey}}.
+func TemplateData(data interface{}) func(*Loader) error {
+	return func(l *Loader) error {
+		if !l.template {
+			return fmt.Errorf(`testfixtures: the Template() options is required in order to use the TemplateData() option`)
+		}
+
+		l.templateData = data
+		return nil
+	}
+}
+
+// Reload reads the fixture files from disk again, for long-running tools
+// where they may change between loads. Files are otherwise read and parsed
+// only once, by New. If an error is returned, the previous fixtures are kept.
+func (l *Loader) Reload() error {
+	var (
+		oldFiles        = l.fixturesFiles
+		oldMissingFiles = l.missingFiles
+		oldScenarios    = l.scenarios
+	)
+	l.fixturesFiles = nil
+	l.missingFiles = nil
+
+	err := func() error {
+		for _, source := range l.fixtureSources {
+			fixtures, err := source(l)
+			if err != nil {
+				return err
+			}
+			l.fixturesFiles = append(l.fixturesFiles, fixtures...)
+		}
+		if err := l.readScenarios(); err != nil {
+			return err
+		}
+		markPartFiles(l.fixturesFiles)
+		l.overlayDirectories()
+		return l.buildInsertSQLs()
+	}()
+	if err != nil {
+		l.fixturesFiles = oldFiles
+		l.missingFiles = oldMissingFiles
+		l.scenarios = oldScenarios
+	}
+	l.readFileStates = l.fileStates()
+	return err
+}
+
+// EnsureTestDatabase returns an error if the database name does not contains
+// "test", or does not match the pattern given with DatabaseNameRegexp.
+// In-memory SQLite databases are always considered test databases.
+//
+// The name is queried from the database, unless given with DatabaseName.
+// The error is then a *NotTestDatabaseError, matching ErrNotTestDatabase.
+func (l *Loader) EnsureTestDatabase() error {
+	dbName, err := l.currentDatabaseName()
+	if err != nil {
+		return err
+	}
+	return l.ensureTestDatabaseName(dbName)
+}
+
+// currentDatabaseName returns the name of the database, given with
+// DatabaseName or queried from the database.
+func (l *Loader) currentDatabaseName() (string, error) {
+	if l.databaseName != "" {
+		return l.databaseName, nil
+	}
+	dbName, err := l.helper.databaseName(l.db)
+	if err != nil {
+		return "", fmt.Errorf("testfixtures: could not query the database name: %w", err)
+	}
+	if dbName == "" {
+		return "", fmt.Errorf("testfixtures: could not get the database name, give it with DatabaseName")
+	}
+	return dbName, nil
+}
+
+// ensureTestDatabaseName returns an error if the name doesn't look like the
+// one of a test database. See EnsureTestDatabase.
+func (l *Loader) ensureTestDatabaseName(dbName string) error {
+	if dbName == sqliteMemoryDatabaseName {
+		return nil
+	}
+	re := testDatabaseRegexp
+	if l.testDatabaseRegexp != nil {
+		re = l.testDatabaseRegexp
+	}
+	if !re.MatchString(dbName) {
+		return &NotTestDatabaseError{Database: dbName}
+	}
+	return nil
+}
+
+// Load wipes and after load all fixtures in the database.
+//     if err := fixtures.Load(); err != nil {
+//             ...
+//     }
+func (l *Loader) Load() error {
+	_, err := l.LoadWithResult()
+	return err
+}
+
+// LoadContext works like Load, but cancels the load when the context is done,
+// including the query checking the database name and the ones run by the
+// dialect to disable the referential integrity. The error of the context is
+// then returned.
+func (l *Loader) LoadContext(ctx context.Context) error {
+	_, err := l.LoadWithResultContext(ctx)
+	return err
+}
+
+// LoadResult contains information about a call to LoadWithResult.
+type LoadResult struct {
+	// FilesLoaded is the number of fixture files inserted in the database.
+	FilesLoaded int
+	// FilesSkipped is the number of fixture files not inserted, either
+	// because they have no records or because their table was not modified
+	// since the last load.
+	FilesSkipped int
+	// Rows is the total number of records inserted.
+	Rows int
+	// AnalyzeErrors are the errors of the statistics updates, when both
+	// AnalyzeAfterLoad and AnalyzeBestEffort are given.
+	AnalyzeErrors []error
+	// Duration is the time the whole load took.
+	Duration time.Duration
+	// Tables are the details of each table with fixtures, by name.
+	Tables map[string]*TableResult
+	// CascadedTables are the tables without fixtures cleaned because they
+	// reference tables with fixtures, in the order they were cleaned. See
+	// CascadeCleanup.
+	CascadedTables []string
+
+	// tables are the tables that got records.
+	tables []string
+	// fileErrors are the errors of the files that failed to load with
+	// ContinueOnError.
+	fileErrors   *FileLoadErrors
+	failedTables []string
+}
+
+// TableResult tells what was loaded on a table. See LoadResult.
+type TableResult struct {
+	// Rows is the number of records inserted.
+	Rows int
+	// Duration is the time spent inserting the records, without cleaning
+	// the table.
+	Duration time.Duration
+	// Skipped is true when the table was not loaded because it was not
+	// modified since the last load. Only PostgreSQL and MySQL check it.
+	Skipped bool
+}
+
+// table returns the result of the table of a file.
+func (r *LoadResult) table(file *fixtureFile) *TableResult {
+	if r.Tables == nil {
+		r.Tables = make(map[string]*TableResult)
+	}
+	tableName := file.fileNameWithoutExtension()
+	t, ok := r.Tables[tableName]
+	if !ok {
+		t = &TableResult{}
+		r.Tables[tableName] = t
+	}
+	return t
+}
+
+func (r *LoadResult) fileLoaded(file *fixtureFile) {
+	t := r.table(file)
+	if len(file.insertSQLs) == 0 {
+		r.FilesSkipped++
+		return
+	}
+	r.FilesLoaded++
+	r.Rows += len(file.insertSQLs)
+	t.Rows += len(file.insertSQLs)
+	t.Duration += file.insertDuration
+	if tableName := file.fileNameWithoutExtension(); !containsString(r.tables, tableName) {
+		r.tables = append(r.tables, tableName)
+	}
+}
+
+// fileSkipped records a file whose table was not modified since the last
+// load.
+func (r *LoadResult) fileSkipped(file *fixtureFile) {
+	r.FilesSkipped++
+	r.table(file).Skipped = true
+}
+
+// LoadWithResult works like Load, but also returns information about what
+// was loaded.
+func (l *Loader) LoadWithResult() (*LoadResult, error) {
+	return l.load(l.fixturesFiles)
+}
+
+// LoadWithResultContext works like LoadWithResult, but cancels the load when
+// the context is done, like LoadContext.
+func (l *Loader) LoadWithResultContext(ctx context.Context) (*LoadResult, error) {
+	// The helpers run their queries on l.db, which is bound to the context
+	// for the duration of the load.
+	db := l.db
+	l.db = withContext(ctx, db)
+	defer func() { l.db = db }()
+
+	result, err := l.load(l.fixturesFiles)
+	if err != nil && ctx.Err() != nil && !errors.Is(err, ctx.Err()) {
+		return result, fmt.Errorf("testfixtures: %w: %v", ctx.Err(), err)
+	}
+	return result, err
+}
+
+func (l *Loader) loadFiles(files []*fixtureFile) (*LoadResult, error) {
+	start := time.Now()
+	files = l.orderFiles(files)
+	if !l.skipTestDatabaseCheck {
+		if err := l.EnsureTestDatabase(); err != nil {
+			return nil, err
+		}
+	}
+	if l.dependencyOrder {
+		var err error
+		if files, err = l.sortByDependencies(files); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.requireReferencedTables {
+		if err := l.checkReferencedTables(files); err != nil {
+			return nil, err
+		}
+	}
+
+	l.resolveRelativeTimes(files)
+
+	if l.strictKeys {
+		if err := l.checkKeys(files); err != nil {
+			return nil, err
+		}
+	}
+
+	concurrent, err := l.canLoadConcurrently(files)
+	if err != nil {
+		return nil, err
+	}
+	concurrent = concurrent && l.rollbackFn == nil
+
+	var (
+		result   = &LoadResult{}
+		progress = l.newProgressTracker(files)
+	)
+	switch {
+	case concurrent:
+		err = l.loadConcurrently(files, result, progress)
+	case l.perTableTransaction:
+		err = l.loadPerTable(files, result, progress)
+	case l.limitsMutations():
+		err = l.loadInChunks(files, result, progress)
+	case l.inDependencyOrder():
+		err = l.loadWithoutReferentialIntegrity(files, result, progress)
+	default:
+		err = l.helper.disableReferentialIntegrity(l.db, func(tx *sql.Tx) error {
+			return l.loadInTransaction(tx, files, result, progress)
+		})
+	}
+	if err != nil {
+		l.completeUnknownColumns(err)
+		return nil, err
+	}
+	if err := l.helper.afterLoad(l.db); err != nil {
+		return nil, err
+	}
+	if l.analyzeAfterLoad {
+		if err := l.analyze(result); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.loadedTables == nil {
+		l.loadedTables = make(map[string][]*fixtureFile)
+	}
+	for tableName, tableFiles := range filesByTable(files) {
+		l.loadedTables[tableName] = tableFiles
+	}
+	result.Duration = time.Since(start)
+	if result.fileErrors != nil {
+		// Make the next load fill the tables of the failed files again.
+		for _, tableName := range result.failedTables {
+			delete(l.loadedTables, tableName)
+		}
+		l.completeUnknownColumns(result.fileErrors)
+		return result, result.fileErrors
+	}
+	return result, nil
+}
+
+// isTableModified reports whether a table must be loaded. Besides the
+// helper's check, it is when its fixture files are not the ones last loaded,
+// like when switching scenarios.
+func (l *Loader) isTableModified(q queryable, tableName string, tableFiles []*fixtureFile) (bool, error) {
+	if !sameFixtureFiles(l.loadedTables[tableName], tableFiles) {
+		return true, nil
+	}
+	return l.helper.isTableModified(q, tableName)
+}
+
+// filesByTable returns the fixture files of each table.
+func filesByTable(files []*fixtureFile) map[string][]*fixtureFile {
+	tables := make(map[string][]*fixtureFile, len(files))
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		tables[tableName] = append(tables[tableName], file)
+	}
+	return tables
+}
+
+func sameFixtureFiles(a, b []*fixtureFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *Loader) loadInTransaction(tx *sql.Tx, files []*fixtureFile, result *LoadResult, progress *progressTracker) error {
+	if err := execStatements(tx, "before load", l.beforeLoadSQL); err != nil {
+		return err
+	}
+
+	var (
+		tables         = filesByTable(files)
+		modifiedTables = make(map[string]bool, len(files))
+	)
+	for _, file := range files {
+		tableName := file.fileNameWithoutExtension()
+		modified, err := l.isTableModified(tx, tableName, tables[tableName])
+		if err != nil {
+			return err
+		}
+		modifiedTables[tableName] = modified
+		if !modified {
+			progress.skipped(file)
+		}
+	}
+
+	if l.cascadeCleanup {
+		if err := l.cleanDependentTables(tx, files, result); err != nil {
+			return err
+		}
+	}
+
+	// Delete existing table data for specified fixtures before populating the data. This helps avoid
+	// DELETE CASCADE constraints when using the `UseAlterConstraint()` option.
+	//
+	// When referential integrity is not disabled, files are expected to be
+	// ordered by dependency, so tables are cleaned in the reverse order.
+	cleanFiles := firstFilePerTable(files)
+	for i := range cleanFiles {
+		file := cleanFiles[i]
+		if l.inDependencyOrder() {
+			file = cleanFiles[len(cleanFiles)-1-i]
+		}
+		modified := modifiedTables[file.fileNameWithoutExtension()]
+		if !modified || l.upsert {
+			continue
+		}
+		if err := l.deleteFile(tx, file); err != nil {
+			return err
+		}
+		progress.cleaned(file.fileNameWithoutExtension())
+	}
+
+	for _, file := range files {
+		modified := modifiedTables[file.fileNameWithoutExtension()]
+		if !modified {
+			result.fileSkipped(file)
+			continue
+		}
+		if !l.continueOnError {
+			if err := l.insertFile(tx, file, progress); err != nil {
+				return err
+			}
+		} else {
+			fileErr, err := l.insertFileInSavepoint(tx, file, progress)
+			if err != nil {
+				return err
+			}
+			if fileErr != nil {
+				result.fileFailed(file, fileErr)
+				continue
+			}
+		}
+		result.fileLoaded(file)
+	}
+	if err := execStatements(tx, "after load", l.afterLoadSQL); err != nil {
+		return err
+	}
+	return l.rollback(tx, result)
+}
+
+func execStatements(q queryable, kind string, statements []string) error {
+	for _, statement := range statements {
+		if _, err := q.Exec(statement); err != nil {
+			return fmt.Errorf(`testfixtures: could not execute %s SQL "%s": %w`, kind, statement, err)
+		}
+	}
+	return nil
+}
+
+// limitsMutations tells whether the fixtures must be loaded in more than one
+// transaction, because of the limits of the database. See mutationLimiter.
+func (l *Loader) limitsMutations() bool {
+	_, ok := l.helper.(mutationLimiter)
+	return ok
+}
+
+// inDependencyOrder tells whether referential integrity is kept while
+// loading, so the files are expected to be given in dependency order.
+func (l *Loader) inDependencyOrder() bool {
+	_, ok := l.helper.(orderedHelper)
+	return ok || l.skipReferentialIntegrity
+}
+
+func (l *Loader) loadWithoutReferentialIntegrity(files []*fixtureFile, result *LoadResult, progress *progressTracker) error {
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := l.loadInTransaction(tx, files, result, progress); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (l *Loader) loadPerTable(files []*fixtureFile, result *LoadResult, progress *progressTracker) error {
+	h, ok := l.helper.(sessionHelper)
+	if !ok && !l.inDependencyOrder() {
+		return fmt.Errorf("testfixtures: PerTableTransaction is not supported by this dialect")
+	}
+
+	ctx := context.Background()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var (
+		tables          = filesByTable(files)
+		committedTables []string
+	)
+	loadFn := func() error {
+		if err := execStatements(connQueryable{conn}, "before load", l.beforeLoadSQL); err != nil {
+			return err
+		}
+		if l.cascadeCleanup {
+			if err := l.cleanDependentTablesInTransaction(ctx, conn, files, result); err != nil {
+				return err
+			}
+		}
+		// The table is checked and cleaned with its first file only, so
+		// the next files of the table add their records to it.
+		modifiedTables := make(map[string]bool, len(tables))
+		for _, file := range files {
+			tableName := file.fileNameWithoutExtension()
+			modified, checked := modifiedTables[tableName]
+			if !checked || modified {
+				var err error
+				modified, err = l.loadFileInTransaction(ctx, conn, file, tables[tableName], !checked, progress)
+				if err != nil {
+					return err
+				}
+				modifiedTables[tableName] = modified
+			}
+			if !modified {
+				progress.skipped(file)
+				result.fileSkipped(file)
+				continue
+			}
+			result.fileLoaded(file)
+			if !containsString(committedTables, tableName) {
+				committedTables = append(committedTables, tableName)
+			}
+		}
+		return execStatements(connQueryable{conn}, "after load", l.afterLoadSQL)
+	}
+	if l.inDependencyOrder() {
+		err = loadFn()
+	} else {
+		err = h.disableReferentialIntegrityForSession(connQueryable{conn}, loadFn)
+	}
+	if err != nil && len(committedTables) > 0 {
+		return &PartialLoadError{Err: err, CommittedTables: committedTables}
+	}
+	return err
+}
+
+// loadFileInTransaction inserts the records of a file in its own
+// transaction. With first, the table is checked and cleaned before, unless
+// it was not modified since the last load.
+func (l *Loader) loadFileInTransaction(ctx context.Context, conn *sql.Conn, file *fixtureFile, tableFiles []*fixtureFile, first bool, progress *progressTracker) (modified bool, err error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if first {
+		modified, err = l.isTableModified(tx, file.fileNameWithoutExtension(), tableFiles)
+		if err != nil || !modified {
+			return false, err
+		}
+	}
+
+	if first && !l.upsert {
+		if err := l.deleteFile(tx, file); err != nil {
+			return false, err
+		}
+		progress.cleaned(file.fileNameWithoutExtension())
+	}
+	if err := l.insertFile(tx, file, progress); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (l *Loader) insertFile(tx *sql.Tx, file *fixtureFile, progress *progressTracker) error {
+	tableName := file.fileNameWithoutExtension()
+	start := time.Now()
+	defer func() { file.insertDuration = time.Since(start) }()
+	err := l.whileInsertOnTable(tx, file, func() error {
+		if l.copyFrom && l.canCopy(file) {
+			return l.copyFile(tx, file, progress)
+		}
+
+		stmts := &insertStatements{
+			tx:          tx,
+			insertedIDs: l.insertedIDMode(),
+			primaryKey:  l.primaryKey(tableName)[0],
+		}
+		stmts.binder, _ = l.helper.(paramBinder)
+		defer func() { _ = stmts.close() }()
+
+		for j := range file.insertSQLs {
+			if err := l.insert(tx, stmts, file.insertSQLs, j); err != nil {
+				return l.insertError(tx, file, j, err)
+			}
+			progress.rowInserted(tableName)
+		}
+		file.insertedIDs = stmts.ids
+		return stmts.close()
+	})
+	if err != nil {
+		return err
+	}
+	progress.tableInserted(tableName)
+	return nil
+}
+
+// insertError returns the error of the j-th insert of a file, with the
+// details helping to fix it.
+func (l *Loader) insertError(q queryable, file *fixtureFile, j int, err error) *InsertError {
+	var (
+		tableName = file.fileNameWithoutExtension()
+		i         = file.insertSQLs[j]
+	)
+	insertErr := &InsertError{
+		Err:    err,
+		File:   file.fileName,
+		Index:  j,
+		Label:  i.label,
+		SQL:    i.sql,
+		Params: i.params,
+	}
+	if l.debugConstraintErrors && isConstraintError(err) {
+		insertErr.Record = i.record()
+		insertErr.ExistingRows = l.conflictingRows(q, tableName, i)
+	}
+	if column, ok := unknownColumn(err); ok {
+		// The other unknown columns are looked for once the load is over.
+		// See completeUnknownColumns.
+		insertErr.Table = tableName
+		insertErr.UnknownColumns = []string{column}
+		insertErr.recordColumns = i.columns
+	}
+	return insertErr
+}
+
+// insert executes the j-th insert of a file.
+func (l *Loader) insert(tx *sql.Tx, stmts *insertStatements, inserts []insertSQL, j int) error {
+	if l.logger != nil {
+		l.logger(inserts[j].sql, inserts[j].params)
+	}
+	if !l.debugConstraintErrors || !l.abortsTransactionOnError() {
+		return stmts.exec(inserts, j)
+	}
+
+	// Keep the transaction usable for the diagnostic queries if the
+	// insert fails.
+	if _, err := tx.Exec("SAVEPOINT testfixtures_insert"); err != nil {
+		return err
+	}
+	if err := stmts.exec(inserts, j); err != nil {
+		_, _ = tx.Exec("ROLLBACK TO SAVEPOINT testfixtures_insert")
+		return err
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT testfixtures_insert")
+	return err
+}
 
-		f.insertSQLs = make([]insertSQL, 0, len(result))
+// insertStatements prepares the statements shared by consecutive inserts of
+// a file, so the database parses them only once. Inserts share a statement
+// when their records have the same columns.
+type insertStatements struct {
+	tx   *sql.Tx
+	sql  string
+	stmt *sql.Stmt
+
+	// binder binds the params, if the helper is a paramBinder.
+	binder paramBinder
+
+	// insertedIDs tells how to get the primary keys of the inserted records,
+	// which are kept in ids. See CaptureInsertedIDs.
+	insertedIDs insertedIDMode
+	primaryKey  string
+	ids         []interface{}
+}
 
-		for _, record := range result {
-			recordMap, ok := record.(map[interface{}]interface{})
-			if !ok {
-				return fmt.Errorf("testfixtures: could not cast record: not a map[interface{}]interface{}")
-			}
+// exec executes the j-th insert. Its statement is prepared if the next insert
+// shares it, otherwise it's executed directly.
+func (s *insertStatements) exec(inserts []insertSQL, j int) error {
+	i := inserts[j]
+	if s.stmt != nil && s.sql != i.sql {
+		if err := s.close(); err != nil {
+			return err
+		}
+	}
+	if s.stmt == nil && j+1 < len(inserts) && inserts[j+1].sql == i.sql {
+		stmt, err := s.tx.Prepare(i.sql)
+		if err != nil {
+			return err
+		}
+		s.sql, s.stmt = i.sql, stmt
+	}
+
+	if s.insertedIDs == insertedIDReturned {
+		var id interface{}
+		if err := s.queryRow(i).Scan(&id); err != nil {
+			return err
+		}
+		s.ids = append(s.ids, normalizeInsertedID(id))
+		return nil
+	}
+
+	var (
+		result sql.Result
+		err    error
+	)
+	if s.stmt != nil {
+		result, err = s.stmt.Exec(s.args(i)...)
+	} else {
+		result, err = s.tx.Exec(i.sql, s.args(i)...)
+	}
+	if err != nil || s.insertedIDs != insertedIDLastInsertID {
+		return err
+	}
+	id, err := i.lastInsertID(result, s.primaryKey)
+	if err != nil {
+		return err
+	}
+	s.ids = append(s.ids, id)
+	return nil
+}
+
+func (s *insertStatements) queryRow(i insertSQL) *sql.Row {
+	if s.stmt != nil {
+		return s.stmt.QueryRow(s.args(i)...)
+	}
+	return s.tx.QueryRow(i.sql, s.args(i)...)
+}
+
+// args returns the params of an insert as bound by the helper.
+func (s *insertStatements) args(i insertSQL) []interface{} {
+	if s.binder == nil {
+		return i.params
+	}
+	return s.binder.bindParams(i.params)
+}
+
+func (s *insertStatements) close() error {
+	if s.stmt == nil {
+		return nil
+	}
+	err := s.stmt.Close()
+	s.stmt = nil
+	return err
+}
+
+// PartialLoadError will be returned when using PerTableTransaction if any
+// error happens after some tables were already committed.
+type PartialLoadError struct {
+	Err             error
+	CommittedTables []string
+}
+
+func (e *PartialLoadError) Error() string {
+	return fmt.Sprintf(
+		"testfixtures: %v (tables already committed: %s)",
+		e.Err,
+		strings.Join(e.CommittedTables, ", "),
+	)
+}
+
+func (e *PartialLoadError) Unwrap() error {
+	return e.Err
+}
+
+// NotTestDatabaseError is returned when the name of the database doesn't
+// look like a test one. It matches ErrNotTestDatabase with errors.Is.
+type NotTestDatabaseError struct {
+	Database string
+}
+
+func (e *NotTestDatabaseError) Error() string {
+	return fmt.Sprintf(`testfixtures: database "%s" does not appear to be a test database`, e.Database)
+}
+
+// Is reports whether target is ErrNotTestDatabase.
+func (e *NotTestDatabaseError) Is(target error) bool {
+	return target == ErrNotTestDatabase
+}
+
+// InsertError will be returned if any error happens on database while
+// inserting the record.
+type InsertError struct {
+	Err    error
+	File   string
+	Index  int
+	Label  string
+	SQL    string
+	Params []interface{}
+
+	// Record and ExistingRows are only set when using DebugConstraintErrors.
+	// ExistingRows are the rows of the table with the primary key of the
+	// record, or sharing a value with it if it doesn't give the key.
+	Record       map[string]interface{}
+	ExistingRows []map[string]interface{}
+
+	// Table and UnknownColumns are only set when the record has columns
+	// missing from the table, like after a migration dropped them.
+	Table          string
+	UnknownColumns []string
+
+	// recordColumns are the columns of the record, until the unknown ones
+	// are set by completeUnknownColumns.
+	recordColumns []string
+}
+
+func (e *InsertError) Error() string {
+	var label string
+	if e.Label != "" {
+		label = fmt.Sprintf(", label: %s", e.Label)
+	}
+	var debug string
+	if e.Record != nil {
+		debug = fmt.Sprintf(", record: %v, existing rows: %v", e.Record, e.ExistingRows)
+	}
+	if len(e.UnknownColumns) > 0 {
+		return fmt.Sprintf(
+			`testfixtures: file %s has columns not in table "%s": %s (index: %d%s): %v`,
+			e.File,
+			e.Table,
+			strings.Join(e.UnknownColumns, ", "),
+			e.Index,
+			label,
+			e.Err,
+		)
+	}
+	return fmt.Sprintf(
+		"testfixtures: error inserting record: %v, on file: %s, index: %d%s, sql: %s, params: %v%s",
+		e.Err,
+		e.File,
+		e.Index,
+		label,
+		e.SQL,
+		e.Params,
+		debug,
+	)
+}
+
+// FixtureError is returned by New and Reload when fixture files are invalid.
+// All the files are checked, so they can be fixed at once.
+type FixtureError struct {
+	// Files are the invalid files, in the order they were given.
+	Files []string
+	// Errors are the errors of each invalid file.
+	Errors []error
+}
+
+func (e *FixtureError) Error() string {
+	errs := make([]string, len(e.Files))
+	for i, file := range e.Files {
+		errs[i] = fmt.Sprintf("%s: %v", file, e.Errors[i])
+	}
+	return fmt.Sprintf("testfixtures: invalid fixture files:\n%s", strings.Join(errs, "\n"))
+}
+
+// Unwrap returns the error of the first invalid file.
+func (e *FixtureError) Unwrap() error {
+	return e.Errors[0]
+}
+
+// add adds the error of an invalid file.
+func (e *FixtureError) add(f *fixtureFile, err error) {
+	file := f.path
+	if file == "" {
+		file = f.fileName
+	}
+	e.Files = append(e.Files, file)
+	e.Errors = append(e.Errors, err)
+}
+
+// unmarshalRecords reads the records of the file, unless already read.
+func (l *Loader) unmarshalRecords(f *fixtureFile) error {
+	if f.records != nil {
+		return nil
+	}
+	unmarshal := yaml.Unmarshal
+	if l.strictYAML {
+		unmarshal = yaml.UnmarshalStrict
+	}
+	if err := unmarshal(f.content, &f.records); err != nil {
+		return fmt.Errorf("testfixtures: could not unmarshal YAML: %w", err)
+	}
+	return nil
+}
+
+// buildInsertSQLs parses all the fixture files, before anything is done on
+// the database.
+func (l *Loader) buildInsertSQLs() error {
+	files := append([]*fixtureFile{}, l.fixturesFiles...)
+	scenarioNames := make([]string, 0, len(l.scenarios))
+	for name := range l.scenarios {
+		scenarioNames = append(scenarioNames, name)
+	}
+	sort.Strings(scenarioNames)
+	for _, name := range scenarioNames {
+		files = append(files, l.scenarios[name]...)
+	}
+
+	// The records of all the files are read first, as they may extend the
+	// records of other files.
+	var (
+		fixtureErr = &FixtureError{}
+		unreadable = make(map[*fixtureFile]error)
+	)
+	for _, f := range files {
+		if err := l.unmarshalRecords(f); err != nil {
+			unreadable[f] = err
+		}
+	}
+	index := newRecordIndex(files)
+	for _, f := range files {
+		err, ok := unreadable[f]
+		if !ok {
+			err = index.resolveFile(f)
+		}
+		if err == nil && l.labelReferences {
+			err = index.resolveReferences(f, l.primaryKey)
+		}
+		if err == nil {
+			err = l.buildFileInsertSQLs(f)
+		}
+		if err != nil {
+			fixtureErr.add(f, err)
+		}
+	}
+	if len(fixtureErr.Errors) > 0 {
+		return fixtureErr
+	}
+	if err := l.checkTargetSchemas(files); err != nil {
+		return err
+	}
+	// The files of each schema are built again from the new records.
+	l.schemaFixtureFiles = nil
+	if !l.skipDuplicateKeysCheck {
+		return l.checkDuplicateKeys()
+	}
+	return nil
+}
+
+func (l *Loader) buildFileInsertSQLs(f *fixtureFile) error {
+	if err := l.unmarshalRecords(f); err != nil {
+		return err
+	}
+	if err := newRecordIndex([]*fixtureFile{f}).resolveFile(f); err != nil {
+		return err
+	}
+
+	f.insertSQLs = make([]insertSQL, 0, len(f.records))
+
+	for j, record := range f.records {
+		if l.loadTags != nil && !record.hasAnyTag(l.loadTags) {
+			continue
+		}
+
+		recordMaps, err := record.expand()
+		if err != nil {
+			return err
+		}
 
-			sql, values, err := l.buildInsertSQL(f, recordMap)
+		for _, recordMap := range recordMaps {
+			sql, values, columns, err := l.buildInsertSQL(f, recordMap)
+			var encodeErr *encodeError
+			if errors.As(err, &encodeErr) {
+				encodeErr.Record = recordName(j, record.label)
+			}
 			if err != nil {
 				return err
 			}
 
-			f.insertSQLs = append(f.insertSQLs, insertSQL{sql, values})
+			var relativeTimes map[int]time.Duration
+			for k, value := range values {
+				if d, ok := value.(relativeTime); ok {
+					if relativeTimes == nil {
+						relativeTimes = make(map[int]time.Duration)
+					}
+					relativeTimes[k] = time.Duration(d)
+					values[k] = l.now().Add(time.Duration(d))
+				}
+			}
+
+			f.insertSQLs = append(f.insertSQLs, insertSQL{sql, values, record.label, columns, relativeTimes})
 		}
 	}
 
 	return nil
 }
 
+// fileNameWithoutExtension returns the name of the table of the file, which
+// is the name of the file without its extension, and without its part number
+// if it's a part. See markPartFiles.
 func (f *fixtureFile) fileNameWithoutExtension() string {
-	return strings.Replace(f.fileName, filepath.Ext(f.fileName), "", 1)
+	name := strings.Replace(f.fileName, filepath.Ext(f.fileName), "", 1)
+	if f.part {
+		name, _ = splitPartNumber(name)
+	}
+	return name
+}
+
+// insertedColumns returns the columns set by at least one record of the
+// file.
+func (f *fixtureFile) insertedColumns() []string {
+	var columns []string
+	for _, i := range f.insertSQLs {
+		for _, column := range i.columns {
+			if !containsString(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	return columns
 }
 
-func (f *fixtureFile) delete(tx *sql.Tx, h helper) error {
-	if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", h.quoteKeyword(f.fileNameWithoutExtension()))); err != nil {
-		return fmt.Errorf(`testfixtures: could not clean table "%s": %w`, f.fileNameWithoutExtension(), err)
+func (l *Loader) deleteFile(tx *sql.Tx, file *fixtureFile) error {
+	tableName := file.fileNameWithoutExtension()
+
+	if nullColumns := l.nullifyBeforeDelete[l.optionsTableName(tableName)]; len(nullColumns) > 0 {
+		assignments := make([]string, 0, len(nullColumns))
+		for _, column := range nullColumns {
+			assignments = append(assignments, fmt.Sprintf("%s = NULL", l.helper.quoteKeyword(column)))
+		}
+		query := fmt.Sprintf("UPDATE %s SET %s", l.helper.quoteKeyword(tableName), strings.Join(assignments, ", "))
+		if condition, ok := l.deleteWhere[l.optionsTableName(tableName)]; ok {
+			query += " WHERE " + condition
+		}
+		if _, err := l.exec(tx, query); err != nil {
+			return fmt.Errorf(`testfixtures: could not nullify columns of table "%s": %w`, tableName, err)
+		}
+	}
+
+	if _, err := l.exec(tx, l.cleanTableSQL(tableName)); err != nil {
+		return fmt.Errorf(`testfixtures: could not clean table "%s": %w`, tableName, err)
 	}
 	return nil
 }
 
-func (l *Loader) buildInsertSQL(f *fixtureFile, record map[interface{}]interface{}) (sqlStr string, values []interface{}, err error) {
+// cleanTableSQL returns the statement deleting the rows of a table before
+// loading its fixtures.
+func (l *Loader) cleanTableSQL(tableName string) string {
+	if condition, ok := l.deleteWhere[l.optionsTableName(tableName)]; ok {
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", l.helper.quoteKeyword(tableName), condition)
+	}
+	if c, ok := l.helper.(tableCleaner); ok {
+		return c.cleanTableSQL(tableName)
+	}
+	return fmt.Sprintf("DELETE FROM %s", l.helper.quoteKeyword(tableName))
+}
+
+// exec executes the statement in the transaction, sending it to the logger
+// first if one was given.
+func (l *Loader) exec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	if l.logger != nil {
+		l.logger(query, args)
+	}
+	return tx.Exec(query, args...)
+}
+
+func (l *Loader) buildInsertSQL(f *fixtureFile, record map[interface{}]interface{}) (sqlStr string, values []interface{}, columns []string, err error) {
 	var (
+		tableName  = f.fileNameWithoutExtension()
 		sqlColumns = make([]string, 0, len(record))
 		sqlValues  = make([]string, 0, len(record))
+		casts      = l.columnCasts[l.optionsTableName(tableName)]
+		fileCasts  = f.records.casts()
+		jsonCols   = l.jsonColumns[l.optionsTableName(tableName)]
+		types      = l.columnTypes[l.optionsTableName(tableName)]
+		encoders   = l.columnEncoders[l.optionsTableName(tableName)]
+		computed   = l.computedColumns(tableName)
+		identity   = l.identityColumns(tableName)
+		overriding bool
 		i          = 1
 	)
-	for key, value := range record {
+	keys := make([]string, 0, len(record))
+	for key := range record {
 		keyStr, ok := key.(string)
 		if !ok {
 			err = fmt.Errorf("testfixtures: record map key is not a string")
 			return
 		}
+		keys = append(keys, keyStr)
+	}
+	// Sorted, so records with the same columns share the same statement.
+	sort.Strings(keys)
+
+	for _, keyStr := range keys {
+		value := record[keyStr]
+		if computed[keyStr] {
+			continue
+		}
+		if identity[keyStr] {
+			overriding = true
+		}
 
 		sqlColumns = append(sqlColumns, l.helper.quoteKeyword(keyStr))
 
-		// if string, try convert to SQL or time
-		// if map or array, convert to json
-		switch v := value.(type) {
-		case string:
-			if strings.HasPrefix(v, "RAW=") {
-				sqlValues = append(sqlValues, strings.TrimPrefix(v, "RAW="))
-				continue
-			}
-			if b, err := l.tryHexStringToBytes(v); err == nil {
-				value = b
-			} else if t, err := l.tryStrToDate(v); err == nil {
-				value = t
-			}
-		case []interface{}, map[interface{}]interface{}:
-			var bytes []byte
-			bytes, err = json.Marshal(recursiveToJSON(v))
+		// Values of columns with an encoder are only converted by it.
+		encode, hasEncoder := encoders[keyStr]
+		if hasEncoder {
+			value, err = encode(value)
 			if err != nil {
+				err = &encodeError{Column: keyStr, Err: err}
 				return
 			}
-			value = string(bytes)
+		} else {
+			// if string, try convert to SQL, interval or time
+			// if map or array, or in a JSON column, convert to json
+			switch v := value.(type) {
+			case string:
+				if l.nullValue != nil && v == *l.nullValue {
+					value = nil
+					break
+				}
+				if strings.HasPrefix(v, "RAW=") {
+					sqlValues = append(sqlValues, strings.TrimPrefix(v, "RAW="))
+					continue
+				}
+				if l.envValues && strings.HasPrefix(v, envValuePrefix) {
+					value, err = envValue(v)
+					if err != nil {
+						return
+					}
+					break
+				}
+				if l.fileValues && isFileValue(v) {
+					value, err = f.readFileValue(v)
+					if err != nil {
+						return
+					}
+					break
+				}
+				if jsonCols[keyStr] {
+					value, err = toJSONString(v)
+					if err != nil {
+						return
+					}
+					break
+				}
+				if relativeTimeRegexp.MatchString(v) {
+					value, err = tryStrToRelativeTime(v)
+					if err != nil {
+						return
+					}
+					break
+				}
+				if strings.HasPrefix(v, durationPrefix) {
+					value, err = tryStrToInterval(v)
+					if err != nil {
+						return
+					}
+					break
+				}
+				if b, err := l.tryHexStringToBytes(v); err == nil {
+					value = b
+				} else if t, err := l.tryStrToDate(v); err == nil {
+					value = t
+				}
+			case []interface{}, map[interface{}]interface{}:
+				if columnType, ok := types[keyStr]; ok {
+					value, err = l.helper.(columnTyper).typedValue(v, columnType)
+				} else {
+					value, err = toJSONString(v)
+				}
+				if err != nil {
+					return
+				}
+			default:
+				if jsonCols[keyStr] && v != nil {
+					value, err = toJSONString(v)
+					if err != nil {
+						return
+					}
+				}
+			}
 		}
 
-		switch l.helper.paramType() {
-		case paramTypeDollar:
-			sqlValues = append(sqlValues, fmt.Sprintf("$%d", i))
-		case paramTypeQuestion:
-			sqlValues = append(sqlValues, "?")
-		case paramTypeAtSign:
-			sqlValues = append(sqlValues, fmt.Sprintf("@p%d", i))
+		placeholder := l.placeholder(i)
+		cast, ok := fileCasts[keyStr]
+		if !ok {
+			cast, ok = casts[keyStr]
 		}
-
+		if !ok {
+			cast, ok = types[keyStr]
+		}
+		if ok {
+			h, isCaster := l.helper.(caster)
+			if !isCaster {
+				err = fmt.Errorf(`testfixtures: can't cast column "%s" of table "%s": casts are not supported by this dialect`, keyStr, tableName)
+				return
+			}
+			placeholder = h.castPlaceholder(placeholder, cast)
+		}
+		sqlValues = append(sqlValues, placeholder)
 		values = append(values, value)
+		columns = append(columns, keyStr)
 		i++
 	}
 
+	var beforeValues, atEnd string
+	if l.captureInsertedIDs {
+		beforeValues, atEnd = l.helper.(insertedIDHelper).insertedIDClauses(l.quotedPrimaryKey(tableName)[0])
+	}
+	if overriding {
+		beforeValues += " OVERRIDING SYSTEM VALUE"
+	}
+
 	sqlStr = fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s)",
-		l.helper.quoteKeyword(f.fileNameWithoutExtension()),
+		"INSERT INTO %s (%s)%s VALUES (%s)",
+		l.helper.quoteKeyword(tableName),
 		strings.Join(sqlColumns, ", "),
+		beforeValues,
 		strings.Join(sqlValues, ", "),
 	)
+	if l.upsert {
+		sqlStr += " " + l.helper.(upserter).upsertClause(l.quotedPrimaryKey(tableName), sqlColumns)
+	}
+	sqlStr += atEnd
 	return
 }
 
+// quotedPrimaryKey returns the quoted primary key columns of a table. See
+// PrimaryKey.
+func (l *Loader) quotedPrimaryKey(tableName string) []string {
+	columns := l.primaryKey(tableName)
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = l.helper.quoteKeyword(column)
+	}
+	return quoted
+}
+
+// primaryKey returns the primary key columns of a table. See PrimaryKey.
+func (l *Loader) primaryKey(tableName string) []string {
+	if columns, ok := l.primaryKeys[l.optionsTableName(tableName)]; ok {
+		return columns
+	}
+	return []string{"id"}
+}
+
+// bindParams returns the params of a query as bound by the helper. See
+// paramBinder.
+func (l *Loader) bindParams(params []interface{}) []interface{} {
+	if h, ok := l.helper.(paramBinder); ok {
+		return h.bindParams(params)
+	}
+	return params
+}
+
+// placeholder returns the placeholder of the i-th (starting at 1) param of
+// a query.
+func (l *Loader) placeholder(i int) string {
+	switch l.helper.paramType() {
+	case paramTypeDollar:
+		return fmt.Sprintf("$%d", i)
+	case paramTypeAtSign:
+		return fmt.Sprintf("@p%d", i)
+	case paramTypeCustom:
+		return l.helper.(*customHelper).placeholder(i)
+	default:
+		return "?"
+	}
+}
+
 func (l *Loader) fixturesFromDir(dir string) ([]*fixtureFile, error) {
 	fileinfos, err := ioutil.ReadDir(dir)
+	if l.skipMissingFile(dir, err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf(`testfixtures: could not stat directory "%s": %w`, dir, err)
+		return nil, fmt.Errorf(`testfixtures: could not read directory "%s" (%s): %w`, dir, absPath(dir), err)
 	}
 
 	files := make([]*fixtureFile, 0, len(fileinfos))
 
+	extensions := l.extensions
+	if extensions == nil {
+		extensions = defaultExtensions
+	}
+
+	var paths []string
 	for _, fileinfo := range fileinfos {
 		fileExt := filepath.Ext(fileinfo.Name())
-		if !fileinfo.IsDir() && (fileExt == ".yml" || fileExt == ".yaml") {
-			fixture := &fixtureFile{
-				path:     path.Join(dir, fileinfo.Name()),
-				fileName: fileinfo.Name(),
-			}
-			fixture.content, err = ioutil.ReadFile(fixture.path)
-			if err != nil {
-				return nil, fmt.Errorf(`testfixtures: could not read file "%s": %w`, fixture.path, err)
-			}
-			if err := l.processFileTemplate(fixture); err != nil {
-				return nil, err
-			}
-			files = append(files, fixture)
+		if !fileinfo.IsDir() && containsString(extensions, fileExt) {
+			paths = append(paths, path.Join(dir, fileinfo.Name()))
+		}
+	}
+	if l.recursive {
+		if paths, err = walkDir(dir, extensions); err != nil {
+			return nil, fmt.Errorf(`testfixtures: could not read directory "%s" (%s): %w`, dir, absPath(dir), err)
+		}
+	}
+
+	for _, fixturePath := range paths {
+		fixture := &fixtureFile{
+			path:     fixturePath,
+			fileName: path.Base(fixturePath),
+			fromDir:  true,
+		}
+		fixture.content, err = ioutil.ReadFile(fixture.path)
+		if err != nil {
+			return nil, fmt.Errorf(`testfixtures: could not read file "%s": %w`, fixture.path, err)
+		}
+		if err := l.processFileTemplate(fixture); err != nil {
+			return nil, err
 		}
+		files = append(files, fixture)
+	}
+	if len(files) == 0 && !l.allowEmpty {
+		return nil, fmt.Errorf(
+			`%w in directory "%s" (%s, extensions: %s)`,
+			ErrNoFixtureFiles,
+			dir,
+			absPath(dir),
+			strings.Join(extensions, ", "),
+		)
 	}
 	return files, nil
 }
 
+// absPath returns the absolute path of a path, for error messages.
+func absPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	return abs
+}
+
+// skipMissingFile reports whether the error is about a missing path that
+// should be skipped, recording it if so.
+func (l *Loader) skipMissingFile(path string, err error) bool {
+	if !l.allowMissingFiles || !errors.Is(err, os.ErrNotExist) {
+		return false
+	}
+	l.missingFiles = append(l.missingFiles, path)
+	return true
+}
+
+// MissingFiles returns the fixture files and directories that were skipped
+// because they don't exist. See AllowMissingFiles.
+func (l *Loader) MissingFiles() []string {
+	return l.missingFiles
+}
+
 func (l *Loader) fixturesFromFiles(fileNames ...string) ([]*fixtureFile, error) {
 	var (
 		fixtureFiles = make([]*fixtureFile, 0, len(fileNames))
@@ -590,6 +2320,9 @@ func (l *Loader) fixturesFromFiles(fileNames ...string) ([]*fixtureFile, error)
 			fileName: filepath.Base(f),
 		}
 		fixture.content, err = ioutil.ReadFile(fixture.path)
+		if l.skipMissingFile(fixture.path, err) {
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf(`testfixtures: could not read file "%s": %w`, fixture.path, err)
 		}
@@ -615,8 +2348,11 @@ func (l *Loader) fixturesFromPaths(paths ...string) ([]*fixtureFile, error) {
 
 	for _, p := range paths {
 		f, err := os.Stat(p)
+		if l.skipMissingFile(p, err) {
+			continue
+		}
 		if err != nil {
-			return nil, fmt.Errorf(`testfixtures: could not stat path "%s": %w`, p, err)
+			return nil, fmt.Errorf(`testfixtures: could not stat path "%s" (%s): %w`, p, absPath(p), err)
 		}
 
 		fixtures, err := fixtureExtractor(p, f.IsDir())
@@ -642,6 +2378,9 @@ func (l *Loader) fixturesFromFilesMultiTables(fileNames ...string) ([]*fixtureFi
 		)
 
 		content, err = ioutil.ReadFile(f)
+		if l.skipMissingFile(f, err) {
+			continue
+		}
 		if err != nil {
 			return nil, fmt.Errorf(`testfixtures: could not read file "%s": %w`, f, err)
 		}
@@ -650,39 +2389,44 @@ func (l *Loader) fixturesFromFilesMultiTables(fileNames ...string) ([]*fixtureFi
 		if err != nil {
 			return nil, err
 		}
-
-		var data interface{}
-		if err := yaml.Unmarshal(content, &data); err != nil {
-			return nil, fmt.Errorf("testfixtures: could not unmarshal YAML: %w", err)
-		}
-
-		tables, ok := data.(map[interface{}]interface{})
-		if !ok {
-			return nil, fmt.Errorf("testfixtures: could not cast tables: not a map[interface{}]interface{}")
+		content, err = l.filterContent(f, content)
+		if err != nil {
+			return nil, err
 		}
 
-		for table, records := range tables {
-			tableName, ok := table.(string)
-			if !ok {
-				return nil, fmt.Errorf("testfixtures: could not cast tableName: not a string")
-			}
-
-			result, err := l.buildInterfacesSlice(records)
-			if err != nil {
-				return nil, err
+		// A file may contain multiple YAML documents separated by "---".
+		// Records of tables declared in more than one document are merged.
+		var (
+			tableNames   []string
+			tableRecords = make(map[string]fixtureRecords)
+			decoder      = yaml.NewDecoder(bytes.NewReader(content))
+		)
+		decoder.SetStrict(l.strictYAML)
+		for {
+			var tables fixtureTables
+			if err := decoder.Decode(&tables); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return nil, fmt.Errorf(`testfixtures: could not unmarshal YAML file "%s": %w`, f, err)
 			}
 
-			var content []byte
-			if content, err = yaml.Marshal(result); err != nil {
-				return nil, fmt.Errorf("testfixtures: could not marshal YAML: %w", err)
+			for _, table := range tables {
+				if _, ok := tableRecords[table.name]; !ok {
+					tableNames = append(tableNames, table.name)
+				}
+				tableRecords[table.name] = append(tableRecords[table.name], table.records...)
 			}
+		}
 
+		for _, tableName := range tableNames {
 			file := tableName + ".yml"
 			path := filepath.Join(filepath.Dir(f), file)
 			fixtureFiles = append(fixtureFiles, &fixtureFile{
-				path:     path,
-				fileName: file,
-				content:  content,
+				path:       path,
+				fileName:   file,
+				sourcePath: f,
+				records:    tableRecords[tableName],
 			})
 		}
 	}
@@ -691,21 +2435,27 @@ func (l *Loader) fixturesFromFilesMultiTables(fileNames ...string) ([]*fixtureFi
 }
 
 func (l *Loader) processFileTemplate(f *fixtureFile) error {
-	if !l.template {
-		return nil
+	if err := l.decodeFile(f); err != nil {
+		return err
 	}
 
 	var err error
-	f.content, err = l.processTemplate(f.content)
-	if err != nil {
-		return fmt.Errorf(`textfixtures: error on parsing template in %s: %w`, f.fileName, err)
+	if l.template {
+		f.content, err = l.processTemplate(f.content)
+		if err != nil {
+			return fmt.Errorf(`textfixtures: error on parsing template in %s: %w`, f.fileName, err)
+		}
 	}
 
-	return nil
+	f.content, err = l.filterContent(f.path, f.content)
+	return err
 }
 
 func (l *Loader) processTemplate(content []byte) ([]byte, error) {
 	t := template.New("").
+		Funcs(l.fakeFuncs()).
+		Funcs(l.hashFuncs()).
+		Funcs(l.uuidFuncs()).
 		Funcs(l.templateFuncs).
 		Delims(l.templateLeftDelim, l.templateRightDelim).
 		Option(l.templateOptions...)
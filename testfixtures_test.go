@@ -1,15 +1,28 @@
 package testfixtures
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"text/template"
 	"time"
 
 	_ "github.com/joho/godotenv/autoload"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/text/encoding/charmap"
+	"gopkg.in/yaml.v2"
 )
 
 func TestFixtureFile(t *testing.T) {
@@ -20,6 +33,719 @@ func TestFixtureFile(t *testing.T) {
 	}
 }
 
+func TestFixtureRecordsOrder(t *testing.T) {
+	content := []byte(`
+zeta:
+  id: 1
+alpha:
+  id: 2
+parent_of_zeta:
+  id: 3
+beta:
+  id: 4
+`)
+	var records fixtureRecords
+	if err := yaml.Unmarshal(content, &records); err != nil {
+		t.Fatalf("cannot unmarshal records: %v", err)
+	}
+
+	expected := []string{"zeta", "alpha", "parent_of_zeta", "beta"}
+	if len(records) != len(expected) {
+		t.Fatalf("should have %d records, but has %d", len(expected), len(records))
+	}
+	for i, record := range records {
+		if record.label != expected[i] {
+			t.Errorf("record %d should have label %s, but has %s", i, expected[i], record.label)
+		}
+	}
+}
+
+func TestFixtureDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "Slice",
+			content: `
+- _defaults:
+    status: active
+    tenant_id: 1
+- id: 1
+- id: 2
+  status: inactive
+`,
+		},
+		{
+			name: "Map",
+			content: `
+_defaults:
+  status: active
+  tenant_id: 1
+first:
+  id: 1
+second:
+  id: 2
+  status: inactive
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var records fixtureRecords
+			if err := yaml.Unmarshal([]byte(test.content), &records); err != nil {
+				t.Fatalf("cannot unmarshal records: %v", err)
+			}
+
+			expected := []map[interface{}]interface{}{
+				{"id": 1, "status": "active", "tenant_id": 1},
+				{"id": 2, "status": "inactive", "tenant_id": 1},
+			}
+			if len(records) != len(expected) {
+				t.Fatalf("should have %d records, but has %d", len(expected), len(records))
+			}
+			for i, record := range records {
+				if !reflect.DeepEqual(record.values, expected[i]) {
+					t.Errorf("record %d should be %v, but is %v", i, expected[i], record.values)
+				}
+			}
+		})
+	}
+
+	t.Run("NotAMap", func(t *testing.T) {
+		for _, content := range []string{
+			"_defaults: 1\nfirst:\n  id: 1\n",
+			"_defaults: ~\nfirst:\n  id: 1\n",
+			"- _defaults: ~\n- id: 1\n",
+			"- _defaults:\n- id: 1\n",
+		} {
+			var records fixtureRecords
+			if err := yaml.Unmarshal([]byte(content), &records); err == nil {
+				t.Errorf("should return an error when _defaults is not a map, got records %v", records)
+			}
+		}
+	})
+}
+
+func TestFixtureLabels(t *testing.T) {
+	content := `
+first:
+  _label: go_post
+  id: 1
+second:
+  id: 2
+`
+	var records fixtureRecords
+	if err := yaml.Unmarshal([]byte(content), &records); err != nil {
+		t.Fatalf("cannot unmarshal records: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("should have 2 records, but has %d", len(records))
+	}
+	if records[0].label != "go_post" || records[1].label != "second" {
+		t.Errorf("unexpected labels: %s, %s", records[0].label, records[1].label)
+	}
+	if expected := map[interface{}]interface{}{"id": 1}; !reflect.DeepEqual(records[0].values, expected) {
+		t.Errorf("_label should not be a column, got %v", records[0].values)
+	}
+
+	t.Run("Slice", func(t *testing.T) {
+		var records fixtureRecords
+		if err := yaml.Unmarshal([]byte("- _label: go_post\n  id: 1\n- id: 2\n"), &records); err != nil {
+			t.Fatalf("cannot unmarshal records: %v", err)
+		}
+		if records[0].label != "go_post" || records[1].label != "" {
+			t.Errorf("unexpected labels: %s, %s", records[0].label, records[1].label)
+		}
+	})
+
+	t.Run("NotAString", func(t *testing.T) {
+		var records fixtureRecords
+		if err := yaml.Unmarshal([]byte("- _label: [1]\n  id: 1\n"), &records); err == nil {
+			t.Error("should return an error when _label is not a string")
+		}
+	})
+}
+
+func TestLoadTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  []func(*Loader) error
+		expected int
+	}{
+		{"All", nil, 4},
+		{"Premium", []func(*Loader) error{LoadTags("premium")}, 3},
+		{"PremiumOrAdmin", []func(*Loader) error{LoadTags("premium", "admin")}, 4},
+		{"Unknown", []func(*Loader) error{LoadTags("unknown")}, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Loader{helper: NewMockHelper("test")}
+			options := append(test.options, Files("testdata/fixtures_record_tags/users.yml"))
+			for _, option := range options {
+				if err := option(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := l.buildInsertSQLs(); err != nil {
+				t.Fatal(err)
+			}
+			insertSQLs := l.fixturesFiles[0].insertSQLs
+			if len(insertSQLs) != test.expected {
+				t.Errorf("expected %d records, got %d", test.expected, len(insertSQLs))
+			}
+			for _, i := range insertSQLs {
+				if containsString(i.columns, tagsKey) {
+					t.Errorf("%s should not be a column", tagsKey)
+				}
+			}
+		})
+	}
+
+	t.Run("NotAList", func(t *testing.T) {
+		var records fixtureRecords
+		if err := yaml.Unmarshal([]byte("- id: 1\n  _tags: premium\n"), &records); err == nil {
+			t.Error("should return an error when _tags is not a list")
+		}
+	})
+}
+
+func TestLabelReferences(t *testing.T) {
+	newFile := func(fileName, content string) *fixtureFile {
+		f := &fixtureFile{fileName: fileName, content: []byte(content)}
+		if err := yaml.Unmarshal(f.content, &f.records); err != nil {
+			t.Fatalf("cannot unmarshal records: %v", err)
+		}
+		return f
+	}
+	l := &Loader{primaryKeys: map[string][]string{"tags": {"code"}}}
+
+	posts := newFile("posts.yml", `
+go_post:
+  id: 10
+copy:
+  _extends: go_post
+  title: Copy
+`)
+	tags := newFile("tags.yml", "- _label: go\n  code: GO\n")
+	comments := newFile("comments.yml", `
+- id: 1
+  post_id: ref:posts.copy
+  tag: ref:tags.go
+  author: not a ref:posts.go_post
+`)
+
+	index := newRecordIndex([]*fixtureFile{posts, tags, comments})
+	if err := index.resolveReferences(comments, l.primaryKey); err != nil {
+		t.Fatalf("cannot resolve references: %v", err)
+	}
+	expected := map[interface{}]interface{}{"id": 1, "post_id": 10, "tag": "GO", "author": "not a ref:posts.go_post"}
+	if !reflect.DeepEqual(comments.records[0].values, expected) {
+		t.Errorf("expected %v, got %v", expected, comments.records[0].values)
+	}
+
+	t.Run("SameTable", func(t *testing.T) {
+		f := newFile("employees.yml", "boss:\n  id: 1\nemployee:\n  id: 2\n  manager_id: ref:boss\n")
+		if err := newRecordIndex([]*fixtureFile{f}).resolveReferences(f, l.primaryKey); err != nil {
+			t.Fatalf("cannot resolve references: %v", err)
+		}
+		if managerID := f.records[1].values.(map[interface{}]interface{})["manager_id"]; managerID != 1 {
+			t.Errorf("expected manager 1, got %v", managerID)
+		}
+	})
+
+	t.Run("UnknownLabel", func(t *testing.T) {
+		f := newFile("comments.yml", "- post_id: ref:posts.unknown\n")
+		err := newRecordIndex([]*fixtureFile{posts, f}).resolveReferences(f, l.primaryKey)
+		if err == nil || !strings.Contains(err.Error(), `unknown record "posts.unknown"`) {
+			t.Errorf("expected an unknown record error, got %v", err)
+		}
+	})
+
+	t.Run("MissingPrimaryKey", func(t *testing.T) {
+		users := newFile("users.yml", "john:\n  name: John\n")
+		f := newFile("comments.yml", "- user_id: ref:users.john\n")
+		err := newRecordIndex([]*fixtureFile{users, f}).resolveReferences(f, l.primaryKey)
+		if err == nil || !strings.Contains(err.Error(), `has no "id" value`) {
+			t.Errorf("expected a missing primary key error, got %v", err)
+		}
+	})
+}
+
+func TestRepeatRecords(t *testing.T) {
+	content := []byte(`
+- _repeat: 3
+  id: "@seq"
+  code: "@seq:100"
+  name: Name @index
+- id: 10
+  name: Name @index
+`)
+	var records fixtureRecords
+	if err := yaml.Unmarshal(content, &records); err != nil {
+		t.Fatalf("cannot unmarshal records: %v", err)
+	}
+
+	var actual []map[interface{}]interface{}
+	for _, record := range records {
+		expanded, err := record.expand()
+		if err != nil {
+			t.Fatalf("cannot expand record: %v", err)
+		}
+		actual = append(actual, expanded...)
+	}
+
+	expected := []map[interface{}]interface{}{
+		{"id": 1, "code": 100, "name": "Name 1"},
+		{"id": 2, "code": 101, "name": "Name 2"},
+		{"id": 3, "code": 102, "name": "Name 3"},
+		{"id": 10, "name": "Name @index"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	t.Run("MapForm", func(t *testing.T) {
+		record := fixtureRecord{label: "first", values: map[interface{}]interface{}{"_repeat": 2}}
+		if _, err := record.expand(); err == nil {
+			t.Error("should return an error for _repeat on a map form record")
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		record := fixtureRecord{values: map[interface{}]interface{}{"_repeat": "many"}}
+		if _, err := record.expand(); err == nil {
+			t.Error("should return an error for a non-integer _repeat")
+		}
+	})
+}
+
+func TestExtends(t *testing.T) {
+	newLoader := func(contents map[string]string) *Loader {
+		l := &Loader{helper: &postgreSQL{}}
+		names := make([]string, 0, len(contents))
+		for name := range contents {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			l.fixturesFiles = append(l.fixturesFiles, &fixtureFile{
+				path:     "testdata/" + name,
+				fileName: name,
+				content:  []byte(contents[name]),
+			})
+		}
+		return l
+	}
+
+	l := newLoader(map[string]string{
+		"users.yml": `
+_defaults:
+  role: user
+base:
+  name: Base
+  role: member
+  active: true
+admin:
+  _extends: base
+  name: Admin
+  role: admin
+`,
+		"admins.yml": `
+_defaults:
+  active: false
+root:
+  _extends: users.admin
+  name: Root
+`,
+	})
+	if err := l.buildInsertSQLs(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]interface{}{
+		"base":  {"name": "Base", "role": "member", "active": true},
+		"admin": {"name": "Admin", "role": "admin", "active": true},
+		// The _defaults of the file don't override the extended record.
+		"root": {"name": "Root", "role": "admin", "active": true},
+	}
+	for _, f := range l.fixturesFiles {
+		for _, i := range f.insertSQLs {
+			if record := i.record(); !reflect.DeepEqual(record, expected[i.label]) {
+				t.Errorf("expected %s to be %v, got %v", i.label, expected[i.label], record)
+			}
+			if strings.Contains(i.sql, extendsKey) {
+				t.Errorf("expected %s not to be a column, got %s", extendsKey, i.sql)
+			}
+		}
+	}
+
+	for name, test := range map[string]struct {
+		content string
+		labels  []string
+	}{
+		"Cycle": {
+			content: "a:\n  _extends: b\n  id: 1\nb:\n  _extends: a\n  id: 2\n",
+			labels:  []string{`"a" -> "b" -> "a"`},
+		},
+		"Unknown": {
+			content: "a:\n  _extends: missing\n  id: 1\n",
+			labels:  []string{`"a"`, `"missing"`},
+		},
+		"NotALabel": {
+			content: "a:\n  _extends: 1\n  id: 1\n",
+			labels:  []string{`"a"`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := newLoader(map[string]string{"users.yml": test.content})
+			err := l.buildInsertSQLs()
+			var fixtureErr *FixtureError
+			if !errors.As(err, &fixtureErr) {
+				t.Fatalf("expected a *FixtureError, got %v", err)
+			}
+			for _, label := range test.labels {
+				if !strings.Contains(err.Error(), label) {
+					t.Errorf("expected the error to contain %s, got %v", label, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowMissingFiles(t *testing.T) {
+	paths := []string{
+		"testdata/fixtures/posts.yml",
+		"testdata/fixtures/missing.yml",
+		"testdata/fixtures/comments.yml",
+	}
+
+	t.Run("Strict", func(t *testing.T) {
+		l := &Loader{}
+		if err := Files(paths...)(l); err == nil {
+			t.Error("should return an error for a missing file")
+		}
+	})
+
+	t.Run("Allowed", func(t *testing.T) {
+		l := &Loader{}
+		for _, option := range []func(*Loader) error{
+			AllowMissingFiles(),
+			Files(paths...),
+			Paths("testdata/missing_directory"),
+			Directory("testdata/missing_directory"),
+		} {
+			if err := option(l); err != nil {
+				t.Fatalf("should not return an error for missing files: %v", err)
+			}
+		}
+		if len(l.fixturesFiles) != 2 {
+			t.Errorf("should have loaded 2 files, got %d", len(l.fixturesFiles))
+		}
+		expected := []string{
+			"testdata/fixtures/missing.yml",
+			"testdata/missing_directory",
+			"testdata/missing_directory",
+		}
+		if !reflect.DeepEqual(l.MissingFiles(), expected) {
+			t.Errorf("expected missing files %v, got %v", expected, l.MissingFiles())
+		}
+	})
+}
+
+func TestExtensions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"posts.yml", "tags.yaml", "users.json", "README.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		options  []func(*Loader) error
+		expected []string
+	}{
+		{"Default", nil, []string{"posts.yml", "tags.yaml"}},
+		{"Custom", []func(*Loader) error{Extensions("json", ".yml")}, []string{"posts.yml", "users.json"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Loader{}
+			for _, option := range append(test.options, Directory(dir)) {
+				if err := option(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var names []string
+			for _, f := range l.fixturesFiles {
+				names = append(names, f.fileName)
+			}
+			if !reflect.DeepEqual(names, test.expected) {
+				t.Errorf("expected files %v, got %v", test.expected, names)
+			}
+		})
+	}
+
+	t.Run("NoFiles", func(t *testing.T) {
+		l := &Loader{}
+		if err := Directory(t.TempDir())(l); !errors.Is(err, ErrNoFixtureFiles) {
+			t.Errorf("should return ErrNoFixtureFiles for a directory without fixtures, got %v", err)
+		}
+	})
+
+	t.Run("AllowEmpty", func(t *testing.T) {
+		l := &Loader{}
+		if err := AllowEmpty()(l); err != nil {
+			t.Fatal(err)
+		}
+		if err := Directory(t.TempDir())(l); err != nil {
+			t.Errorf("should not return an error for a directory without fixtures: %v", err)
+		}
+	})
+
+	t.Run("MissingDirectory", func(t *testing.T) {
+		abs, err := filepath.Abs("testdata/fixture")
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := &Loader{}
+		err = Directory("testdata/fixture")(l)
+		if err == nil || !strings.Contains(err.Error(), abs) {
+			t.Errorf("should return an error with the absolute path, got %v", err)
+		}
+	})
+}
+
+func TestScenarioFiles(t *testing.T) {
+	var (
+		posts     = &fixtureFile{fileName: "posts.yml"}
+		tags      = &fixtureFile{fileName: "tags.yml"}
+		users     = &fixtureFile{fileName: "users.yml"}
+		moreTags  = &fixtureFile{fileName: "tags.yml"}
+		moreUsers = &fixtureFile{fileName: "users.yml"}
+	)
+	l := &Loader{
+		fixturesFiles: []*fixtureFile{posts, tags, users},
+		scenarios: map[string][]*fixtureFile{
+			"scenario": {moreUsers, moreTags},
+		},
+	}
+
+	files, err := l.scenarioFiles("scenario")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []*fixtureFile{posts, moreTags, moreUsers}; !sameFixtureFiles(files, expected) {
+		t.Errorf("scenario files should replace the files of the same table")
+	}
+
+	l.appendScenarioFiles = true
+	files, err = l.scenarioFiles("scenario")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []*fixtureFile{posts, tags, users, moreUsers, moreTags}; !sameFixtureFiles(files, expected) {
+		t.Errorf("scenario files should be appended with AppendScenarioFiles")
+	}
+
+	if _, err := l.scenarioFiles("missing"); err == nil {
+		t.Error("should return an error for an unknown scenario")
+	}
+}
+
+func TestInvalidFixtureFiles(t *testing.T) {
+	l := &Loader{helper: NewMockHelper("test")}
+	if err := Directory("testdata/fixtures_invalid")(l); err != nil {
+		t.Fatal(err)
+	}
+
+	err := l.buildInsertSQLs()
+	var fixtureErr *FixtureError
+	if !errors.As(err, &fixtureErr) {
+		t.Fatalf("should return a FixtureError, got %v", err)
+	}
+	expected := []string{"testdata/fixtures_invalid/posts.yml", "testdata/fixtures_invalid/tags.yml"}
+	if !reflect.DeepEqual(fixtureErr.Files, expected) {
+		t.Errorf("expected invalid files %v, got %v", expected, fixtureErr.Files)
+	}
+}
+
+func TestOverlayDirectories(t *testing.T) {
+	newLoader := func(options ...func(*Loader) error) *Loader {
+		l := &Loader{}
+		for _, option := range options {
+			if err := option(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		l.overlayDirectories()
+		return l
+	}
+
+	l := newLoader(
+		Directory("testdata/fixtures_dirs/fixtures2"),
+		Directory("testdata/fixtures_overlay"),
+	)
+	expected := map[string][]string{
+		"tags":  {"testdata/fixtures_overlay/tags.yml"},
+		"users": {"testdata/fixtures_dirs/fixtures2/users.yml"},
+	}
+	if !reflect.DeepEqual(l.TablePaths(), expected) {
+		t.Errorf("expected table paths %v, got %v", expected, l.TablePaths())
+	}
+	if l.fixturesFiles[0].fileName != "tags.yml" {
+		t.Errorf("the overlay file should take the position of the replaced one")
+	}
+
+	l = newLoader(
+		MergeDirectories(),
+		Directory("testdata/fixtures_dirs/fixtures2"),
+		Directory("testdata/fixtures_overlay"),
+	)
+	if paths := l.TablePaths()["tags"]; len(paths) != 2 {
+		t.Errorf("should load the files of both directories with MergeDirectories, got %v", paths)
+	}
+}
+
+func TestRecursiveDirectory(t *testing.T) {
+	newLoader := func(options ...func(*Loader) error) *Loader {
+		l := &Loader{}
+		for _, option := range options {
+			if err := option(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		l.overlayDirectories()
+		return l
+	}
+
+	l := newLoader(Recursive(true), Directory("testdata/fixtures_recursive"))
+	expected := map[string][]string{
+		"comments": {"testdata/fixtures_recursive/blog/comments.yml"},
+		"posts":    {"testdata/fixtures_recursive/posts.yml"},
+		"tags":     {"testdata/fixtures_recursive/taxonomy/tags.yml"},
+	}
+	if !reflect.DeepEqual(l.TablePaths(), expected) {
+		t.Errorf("expected table paths %v, got %v", expected, l.TablePaths())
+	}
+
+	l = newLoader(Recursive(true), MergeDirectories(), Directory("testdata/fixtures_recursive"))
+	if paths, expected := l.TablePaths()["tags"], []string{
+		"testdata/fixtures_recursive/blog/tags.yml",
+		"testdata/fixtures_recursive/taxonomy/tags.yml",
+	}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected the files of all the subdirectories in order %v, got %v", expected, paths)
+	}
+
+	l = newLoader(Directory("testdata/fixtures_recursive"))
+	if expected := map[string][]string{"posts": {"testdata/fixtures_recursive/posts.yml"}}; !reflect.DeepEqual(l.TablePaths(), expected) {
+		t.Errorf("expected only the top level files without Recursive, got %v", l.TablePaths())
+	}
+}
+
+func TestEmptyFixtureFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"Empty", ""},
+		{"Whitespace", "\n  \n"},
+		{"OnlyComments", "# no records yet\n"},
+		{"Null", "~\n"},
+		{"EmptySlice", "[]\n"},
+		{"EmptyMap", "{}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Loader{
+				helper: NewMockHelper("test"),
+				fixturesFiles: []*fixtureFile{
+					{fileName: "posts.yml", content: []byte(test.content)},
+				},
+			}
+			if err := l.buildInsertSQLs(); err != nil {
+				t.Errorf("should not return an error for an empty file: %v", err)
+			}
+			if len(l.fixturesFiles[0].insertSQLs) != 0 {
+				t.Errorf("should not have records for an empty file")
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tags.yml")
+	if err := ioutil.WriteFile(path, []byte("- id: 1\n  name: Go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &Loader{helper: NewMockHelper("test")}
+	if err := Files(path)(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.buildInsertSQLs(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("- id: 1\n  name: Go\n- id: 2\n  name: SQL\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.fixturesFiles[0].insertSQLs) != 1 {
+		t.Errorf("should keep the parsed records until Reload is called")
+	}
+	if err := l.Reload(); err != nil {
+		t.Fatalf("failed to reload: %v", err)
+	}
+	if len(l.fixturesFiles[0].insertSQLs) != 2 {
+		t.Errorf("should have read the changed file, got %d records", len(l.fixturesFiles[0].insertSQLs))
+	}
+
+	if err := ioutil.WriteFile(path, []byte("- id: [\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Reload(); err == nil {
+		t.Error("should return an error for an invalid file")
+	}
+	if len(l.fixturesFiles) != 1 || len(l.fixturesFiles[0].insertSQLs) != 2 {
+		t.Errorf("should keep the previous fixtures when Reload fails")
+	}
+}
+
+func TestInsertErrorLabel(t *testing.T) {
+	err := &InsertError{Err: fmt.Errorf("duplicated key"), File: "posts.yml", Label: "first_post"}
+	if !strings.Contains(err.Error(), "label: first_post") {
+		t.Errorf("error should contain the label, but was: %s", err.Error())
+	}
+}
+
+func TestUnknownColumn(t *testing.T) {
+	tests := []struct {
+		err      string
+		expected string
+	}{
+		{`pq: column "color" of relation "tags" does not exist`, "color"},
+		{`ERROR: column "color" of relation "tags" does not exist (SQLSTATE 42703)`, "color"},
+		{`Error 1054: Unknown column 'color' in 'field list'`, "color"},
+		{`table tags has no column named color`, "color"},
+		{`mssql: Invalid column name 'color'.`, "color"},
+	}
+	for _, test := range tests {
+		column, ok := unknownColumn(errors.New(test.err))
+		if !ok || column != test.expected {
+			t.Errorf("expected column %s for error %q, got %q", test.expected, test.err, column)
+		}
+	}
+
+	if _, ok := unknownColumn(errors.New("UNIQUE constraint failed: tags.id")); ok {
+		t.Error("should not match other errors")
+	}
+}
+
 func TestRequiredOptions(t *testing.T) {
 	t.Run("DatabaseIsRequired", func(t *testing.T) {
 		_, err := New()
@@ -49,35 +775,22 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		return
 	}
 
-	schema, err := ioutil.ReadFile(schemaFilePath)
-	if err != nil {
-		t.Errorf("cannot read schema file: %v", err)
-		return
-	}
-	helper, err := helperForDialect(dialect)
+	schemaLoader, err := New(append(
+		[]func(*Loader) error{
+			Database(db),
+			Dialect(dialect),
+		},
+		additionalOptions...,
+	)...)
 	if err != nil {
-		t.Errorf("cannot get helper: %v", err)
+		t.Errorf("failed to create Loader: %v", err)
 		return
 	}
-	if err := helper.init(db); err != nil {
-		t.Errorf("cannot init helper: %v", err)
+	if err := schemaLoader.LoadSchemaFile(schemaFilePath); err != nil {
+		t.Errorf("cannot load schema: %v", err)
 		return
 	}
 
-	var batches [][]byte
-	if h, ok := helper.(batchSplitter); ok {
-		batches = append(batches, bytes.Split(schema, h.splitter())...)
-	} else {
-		batches = append(batches, schema)
-	}
-
-	for _, b := range batches {
-		if _, err = db.Exec(string(b)); err != nil {
-			t.Errorf("cannot load schema: %v", err)
-			return
-		}
-	}
-
 	t.Run("LoadFromDirectory", func(t *testing.T) {
 		options := append(
 			[]func(*Loader) error{
@@ -109,6 +822,56 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		assertFixturesLoaded(t, l)
 	})
 
+	t.Run("LoadFromConfig", func(t *testing.T) {
+		templateData := map[string]interface{}{
+			"PostIds": []int{1, 2},
+			"TagIds":  []int{1, 2, 3},
+		}
+		l, err := NewFromConfig(Config{
+			Database:     db,
+			Dialect:      dialect,
+			Template:     true,
+			TemplateData: templateData,
+			Directories:  []string{"testdata/fixtures"},
+			Options:      additionalOptions,
+		})
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+
+		// The statements are the ones of the matching options.
+		optionsLoader, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(templateData),
+				Directory("testdata/fixtures"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(l.TablePaths(), optionsLoader.TablePaths()) {
+			t.Errorf("expected the files %v, got %v", optionsLoader.TablePaths(), l.TablePaths())
+		}
+		for i, file := range l.fixturesFiles {
+			for j, insertSQL := range file.insertSQLs {
+				if expected := optionsLoader.fixturesFiles[i].insertSQLs[j].sql; insertSQL.sql != expected {
+					t.Errorf("expected %q, got %q", expected, insertSQL.sql)
+				}
+			}
+		}
+
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		assertFixturesLoaded(t, l)
+	})
+
 	t.Run("LoadFromDirectory-Multiple", func(t *testing.T) {
 		options := append(
 			[]func(*Loader) error{
@@ -135,6 +898,27 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		assertFixturesLoaded(t, l)
 	})
 
+	t.Run("LoadFromDirectory-Overlay", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_dirs/fixtures2"),
+				Directory("testdata/fixtures_overlay"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 1)
+	})
+
 	t.Run("LoadFromFiles", func(t *testing.T) {
 		options := append(
 			[]func(*Loader) error{
@@ -167,7 +951,48 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		assertFixturesLoaded(t, l)
 	})
 
-	t.Run("LoadFromFiles-Multiple", func(t *testing.T) {
+	t.Run("LoadFromTar", func(t *testing.T) {
+		archive := tarArchive(
+			t,
+			"testdata/fixtures/posts.yml",
+			"testdata/fixtures/comments.yml",
+			"testdata/fixtures/tags.yml",
+			"testdata/fixtures/posts_tags.yml",
+			"testdata/fixtures/users.yml",
+			"testdata/fixtures/assets.yml",
+		)
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				FilesFromTar(archive),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if len(l.fixturesFiles) != 6 {
+			t.Errorf("expected 6 fixture files, got %d", len(l.fixturesFiles))
+		}
+		if err := l.Reload(); err != nil {
+			t.Errorf("cannot reload fixtures: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		assertFixturesLoaded(t, l)
+	})
+
+	t.Run("LoadFromFiles-Multiple", func(t *testing.T) {
 		options := append(
 			[]func(*Loader) error{
 				Database(db),
@@ -232,6 +1057,80 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		assertFixturesLoaded(t, l)
 	})
 
+	t.Run("LoadFromFiles-MultiTables-MultiDocuments", func(t *testing.T) {
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				FilesMultiTables(
+					"testdata/fixtures_multi_tables/posts_comments_documents.yml",
+					"testdata/fixtures_multi_tables/tags.yml",
+					"testdata/fixtures_multi_tables/users.yml",
+					"testdata/fixtures_multi_tables/posts_tags.yml",
+					"testdata/fixtures_multi_tables/assets.yml",
+				),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		assertFixturesLoaded(t, l)
+	})
+
+	t.Run("LoadFromFiles-SameTable", func(t *testing.T) {
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				// Both files fill the posts table, which is cleaned once.
+				FilesMultiTables(
+					"testdata/fixtures_same_table/posts_a.yml",
+					"testdata/fixtures_same_table/posts_b.yml",
+				),
+				Files(
+					"testdata/fixtures/comments.yml",
+					"testdata/fixtures/tags.yml",
+					"testdata/fixtures/users.yml",
+					"testdata/fixtures/posts_tags.yml",
+					"testdata/fixtures/assets.yml",
+				),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		assertFixturesLoaded(t, l)
+		var title string
+		if err := l.db.QueryRow("SELECT title FROM posts WHERE id = 1").Scan(&title); err != nil {
+			t.Errorf("cannot query posts: %v", err)
+		}
+		if title != "Post 1" {
+			t.Errorf("expected the post of the first file, got %q", title)
+		}
+	})
+
 	t.Run("LoadFromDirectoryAndFiles", func(t *testing.T) {
 		options := append(
 			[]func(*Loader) error{
@@ -350,6 +1249,98 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		assertFixturesLoaded(t, l)
 	})
 
+	t.Run("LoadWithSkipReferentialIntegrity", func(t *testing.T) {
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				SkipReferentialIntegrity(),
+				Files(
+					"testdata/fixtures/assets.yml",
+					"testdata/fixtures/users.yml",
+					"testdata/fixtures/tags.yml",
+					"testdata/fixtures/posts.yml",
+					"testdata/fixtures/posts_tags.yml",
+					"testdata/fixtures/comments.yml",
+				),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+		}
+		assertFixturesLoaded(t, l)
+	})
+
+	t.Run("LoadWithResult", func(t *testing.T) {
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files(
+					"testdata/fixtures/posts.yml",
+					"testdata/fixtures/comments.yml",
+					"testdata/fixtures_empty/votes.yml",
+				),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		result, err := l.LoadWithResult()
+		if err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if result.FilesLoaded != 2 || result.FilesSkipped != 1 || result.Rows != 6 {
+			t.Errorf("unexpected load result: %+v", result)
+		}
+		assertCount(t, l, "votes", 0)
+		if posts := result.Tables["posts"]; posts == nil || posts.Rows != 2 || posts.Skipped {
+			t.Errorf("unexpected result for posts: %+v", posts)
+		}
+		if votes := result.Tables["votes"]; votes == nil || votes.Rows != 0 {
+			t.Errorf("unexpected result for votes: %+v", votes)
+		}
+		if result.Duration <= 0 || result.Duration < result.Tables["posts"].Duration {
+			t.Errorf("unexpected durations: %v, %v", result.Duration, result.Tables["posts"].Duration)
+		}
+
+		// Tables not modified since the last load are skipped on databases
+		// that support checksums.
+		result, err = l.LoadWithResult()
+		if err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		switch l.helper.(type) {
+		case *postgreSQL, *mySQL:
+			if result.FilesLoaded != 0 || result.FilesSkipped != 3 {
+				t.Errorf("unexpected load result: %+v", result)
+			}
+			if posts := result.Tables["posts"]; posts == nil || !posts.Skipped || posts.Rows != 0 {
+				t.Errorf("unexpected result for posts: %+v", posts)
+			}
+		default:
+			if result.FilesLoaded != 2 || result.FilesSkipped != 1 {
+				t.Errorf("unexpected load result: %+v", result)
+			}
+		}
+	})
+
 	t.Run("GenerateAndLoad", func(t *testing.T) {
 		dir, err := ioutil.TempDir(os.TempDir(), "testfixtures_test")
 		if err != nil {
@@ -388,99 +1379,3199 @@ func testLoader(t *testing.T, dialect, connStr, schemaFilePath string, additiona
 		}
 	})
 
-	t.Run("InsertAfterLoad", func(t *testing.T) {
-		// This test was originally written to catch a bug where it
-		// wasn't possible to insert a record on PostgreSQL due
-		// sequence issues.
+	t.Run("GenerateWithMaxRows", func(t *testing.T) {
+		dir, err := ioutil.TempDir(os.TempDir(), "testfixtures_test")
+		if err != nil {
+			t.Errorf("cannot create temp dir: %v", err)
+			return
+		}
+		dumper, err := NewDumper(
+			DumpDatabase(db),
+			DumpDialect(dialect),
+			DumpDirectory(dir),
+			DumpTables("comments"),
+			DumpMaxRows(1),
+		)
+		if err != nil {
+			t.Errorf("could not create dumper: %v", err)
+			return
+		}
+		if err := dumper.Dump(); err != nil {
+			t.Errorf("cannot generate fixtures: %v", err)
+			return
+		}
 
-		var sql string
-		switch helper.paramType() {
-		case paramTypeDollar:
-			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES ($1, $2, $3, $4)"
-		case paramTypeQuestion:
-			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES (?, ?, ?, ?)"
-		case paramTypeAtSign:
-			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES (@p1, @p2, @p3, @p4)"
-		default:
-			panic("unrecognized param type")
+		options := append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory(dir),
+			},
+			additionalOptions...,
+		)
+		l, err := New(options...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Error(err)
 		}
+		assertCount(t, l, "comments", 1)
+	})
 
-		_, err = db.Exec(sql, "Post title", "Post content", time.Now(), time.Now())
+	t.Run("LoadWithWrappedDatabase", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(wrappedDB{db}),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				Directory("testdata/fixtures"),
+			},
+			additionalOptions...,
+		)...)
 		if err != nil {
-			t.Errorf("cannot insert post: %v", err)
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		err = l.Load()
+		if l.perTableTransaction {
+			if !errors.Is(err, errConnNotSupported) {
+				t.Errorf("expected errConnNotSupported, got %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
 		}
+		assertFixturesLoaded(t, l)
 	})
-}
 
-func assertFixturesLoaded(t *testing.T, l *Loader) { //nolint
-	assertCount(t, l, "posts", 2)
-	assertCount(t, l, "comments", 4)
-	assertCount(t, l, "tags", 3)
-	assertCount(t, l, "posts_tags", 6)
-	assertCount(t, l, "users", 2)
-	assertCount(t, l, "assets", 1)
-}
+	t.Run("Clean", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				Directory("testdata/fixtures"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if err := l.Clean(); err != nil {
+			t.Errorf("cannot clean tables: %v", err)
+			return
+		}
+		for _, table := range []string{"posts", "comments", "tags", "posts_tags", "users", "assets"} {
+			assertCount(t, l, table, 0)
+		}
 
-func assertCount(t *testing.T, l *Loader, table string, expectedCount int) { //nolint
-	count := 0
-	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s", l.helper.quoteKeyword(table))
+		// The tables are filled again by the next load.
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertFixturesLoaded(t, l)
+	})
+
+	t.Run("LoadWithRecordingDatabase", func(t *testing.T) {
+		recorder := &recordingDB{DB: wrappedDB{db}}
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(recorder),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		err = l.Load()
+		if l.perTableTransaction {
+			if !errors.Is(err, errConnNotSupported) {
+				t.Errorf("expected errConnNotSupported, got %v", err)
+			}
+			return
+		}
+		if err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 3)
+		if !containsString(recorder.calls, "BeginTx") {
+			t.Errorf("expected the transaction to be begun through the wrapper, got calls %v", recorder.calls)
+		}
+	})
+
+	t.Run("LoadWithBeforeAndAfterLoadSQL", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				AfterLoadSQL("DELETE FROM tags WHERE id = 3"),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 2)
+
+		l, err = New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				BeforeLoadSQL("DELETE FROM tags"),
+				AfterLoadSQL("DELETE FROM tags WHERE id = 1", "SELECT * FROM missing_table"),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err == nil {
+			t.Error("should return an error when an after load statement fails")
+		}
+		if !l.perTableTransaction {
+			// The whole load should have been rolled back.
+			assertCount(t, l, "tags", 2)
+		}
+	})
+
+	t.Run("LoadWithMixedColumns", func(t *testing.T) {
+		var inserts int
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Logger(func(sql string, args []interface{}) {
+					if strings.HasPrefix(sql, "INSERT") {
+						inserts++
+					}
+				}),
+				Files("testdata/fixtures_mixed_columns/nullables.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if inserts != 5 {
+			t.Errorf("expected 5 inserts, got %d", inserts)
+		}
+		assertCount(t, l, "nullables", 5)
+		assertTextValue(t, l, 2, sql.NullString{String: "two", Valid: true})
+		assertTextValue(t, l, 3, sql.NullString{})
+		assertTextValue(t, l, 5, sql.NullString{String: "five", Valid: true})
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if err := l.Verify(); err != nil {
+			t.Errorf("should verify the loaded fixtures: %v", err)
+		}
+
+		if _, err := db.Exec("DELETE FROM tags WHERE id = 1"); err != nil {
+			t.Errorf("cannot delete tag: %v", err)
+			return
+		}
+		if err := l.Verify(); err == nil {
+			t.Error("should return an error when a row is missing")
+		}
+	})
+
+	t.Run("CompareTable", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		l.AssertTableMatchesFixture(t, "tags")
+
+		if _, err := db.Exec("UPDATE tags SET name = 'Changed' WHERE id = 1"); err != nil {
+			t.Errorf("cannot update tag: %v", err)
+			return
+		}
+		if _, err := db.Exec("DELETE FROM tags WHERE id = 2"); err != nil {
+			t.Errorf("cannot delete tag: %v", err)
+			return
+		}
+		diff, err := l.CompareTable("tags")
+		if err != nil {
+			t.Errorf("cannot compare table: %v", err)
+			return
+		}
+		lines := strings.Split(diff, "\n")
+		if len(lines) != 2 || lines[0] != "  1: name: - Go + Changed" || !strings.HasPrefix(lines[1], "- {") {
+			t.Errorf("unexpected diff:\n%s", diff)
+		}
+
+		tb := &recordingTB{TB: t}
+		l.AssertTableMatchesFixture(tb, "tags")
+		if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], diff) {
+			t.Errorf("expected the assertion to fail with the diff, got %v", tb.errors)
+		}
+	})
+
+	t.Run("LoadWithTags", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				LoadTags("premium"),
+				Files("testdata/fixtures_record_tags/users.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		// John has no tags, Jane and Joe are premium.
+		assertCount(t, l, "users", 3)
+	})
+
+	t.Run("CaptureInsertedIDs", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				CaptureInsertedIDs(),
+				Files("testdata/fixtures_generated_ids/users.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		ids := make(map[string]bool)
+		for _, label := range []string{"john", "jane"} {
+			id, err := l.InsertedIDByLabel("users", label)
+			if err != nil {
+				t.Errorf("cannot get inserted id: %v", err)
+				return
+			}
+			var attributes string
+			query := fmt.Sprintf("SELECT attributes FROM users WHERE id = %v", id)
+			if err := l.db.QueryRow(query).Scan(&attributes); err != nil {
+				t.Errorf("cannot find user %v: %v", id, err)
+				return
+			}
+			ids[fmt.Sprint(id)] = true
+		}
+		for i := 0; i < 2; i++ {
+			id, err := l.InsertedID("users", i)
+			if err != nil {
+				t.Errorf("cannot get inserted id: %v", err)
+				return
+			}
+			if !ids[fmt.Sprint(id)] {
+				t.Errorf("unexpected inserted id %v", id)
+			}
+		}
+		if _, err := l.InsertedID("users", 2); err == nil {
+			t.Error("should return an error for a record that was not inserted")
+		}
+	})
+
+	t.Run("LoadScenario", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+				Scenarios(map[string][]string{
+					"rust": {"testdata/fixtures_scenarios/tags.yml"},
+				}),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 3)
+
+		if err := l.LoadScenario("rust"); err != nil {
+			t.Errorf("cannot load scenario: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 1)
+
+		// The base fixtures must be loaded again, even if the table was not
+		// modified since the scenario was loaded.
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 3)
+
+		if err := l.LoadScenario("missing"); err == nil {
+			t.Error("should return an error for an unknown scenario")
+		}
+	})
+
+	t.Run("LoadWithDeleteWhere", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				DeleteWhere("tags", "id < 10"),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		seed := "INSERT INTO tags (id, name, created_at, updated_at) SELECT 10, 'Seed', created_at, updated_at FROM tags WHERE id = 1"
+		if dialect == "sqlserver" || dialect == "mssql" {
+			seed = "SET IDENTITY_INSERT tags ON; " + seed + "; SET IDENTITY_INSERT tags OFF"
+		}
+		if _, err := db.Exec(seed); err != nil {
+			t.Errorf("cannot insert seed tag: %v", err)
+			return
+		}
+		defer func() {
+			if _, err := db.Exec("DELETE FROM tags WHERE id = 10"); err != nil {
+				t.Errorf("cannot delete seed tag: %v", err)
+			}
+		}()
+
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 4)
+	})
+
+	t.Run("LoadWithUpsert", func(t *testing.T) {
+		if _, ok := schemaLoader.helper.(upserter); !ok {
+			t.Skip("upsert is not supported by this dialect")
+		}
+
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		for _, statement := range []string{
+			"INSERT INTO tags (id, name, created_at, updated_at) SELECT 4, 'Rust', created_at, updated_at FROM tags WHERE id = 1",
+			"UPDATE tags SET name = 'Changed' WHERE id = 1",
+		} {
+			if _, err := db.Exec(statement); err != nil {
+				t.Errorf("cannot change tags: %v", err)
+				return
+			}
+		}
+
+		l, err = New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Upsert(),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 4)
+
+		var name string
+		if err := db.QueryRow("SELECT name FROM tags WHERE id = 1").Scan(&name); err != nil {
+			t.Errorf("cannot query tag: %v", err)
+		}
+		if name != "Go" {
+			t.Errorf("expected the fixture to be updated back to Go, got %s", name)
+		}
+	})
+
+	t.Run("LoadWithLogger", func(t *testing.T) {
+		var statements []string
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Logger(func(sql string, args []interface{}) {
+					statements = append(statements, sql)
+				}),
+				Files("testdata/fixtures/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		table := l.helper.quoteKeyword("tags")
+		if len(statements) != 4 {
+			t.Errorf("expected 4 statements, got %v", statements)
+			return
+		}
+		if statements[0] != "DELETE FROM "+table {
+			t.Errorf("expected a DELETE statement, got %s", statements[0])
+		}
+		for _, statement := range statements[1:] {
+			if !strings.HasPrefix(statement, "INSERT INTO "+table) {
+				t.Errorf("expected an INSERT statement, got %s", statement)
+			}
+		}
+	})
+
+	t.Run("LoadContext", func(t *testing.T) {
+		for _, skipCheck := range []bool{false, true} {
+			options := []func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures/tags.yml"),
+			}
+			if skipCheck {
+				options = append(options, DangerousSkipTestDatabaseCheck())
+			}
+			l, err := New(append(options, additionalOptions...)...)
+			if err != nil {
+				t.Errorf("failed to create Loader: %v", err)
+				return
+			}
+
+			ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+			err = l.LoadContext(ctx)
+			cancel()
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected context.DeadlineExceeded, got %v", err)
+			}
+
+			if err := l.LoadContext(context.Background()); err != nil {
+				t.Errorf("cannot load fixtures: %v", err)
+			}
+			assertCount(t, l, "tags", 3)
+		}
+	})
+
+	t.Run("LoadWithStrictKeys", func(t *testing.T) {
+		newLoader := func(file string) *Loader {
+			l, err := New(append(
+				[]func(*Loader) error{
+					Database(db),
+					Dialect(dialect),
+					StrictKeys(true),
+					Files(file),
+				},
+				additionalOptions...,
+			)...)
+			if err != nil {
+				t.Fatalf("failed to create Loader: %v", err)
+			}
+			return l
+		}
+
+		l := newLoader("testdata/fixtures/tags.yml")
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		err := newLoader("testdata/fixtures_strict_keys/tags.yml").Load()
+		if err == nil || !strings.Contains(err.Error(), `"_tag"`) {
+			t.Errorf("expected an error about the _tag key, got %v", err)
+		}
+		// Nothing was changed.
+		assertCount(t, l, "tags", 3)
+	})
+
+	t.Run("LoadWithRelativeTimes", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Clock(func() time.Time { return time.Date(2020, 1, 15, 12, 0, 0, 0, time.UTC) }),
+				Files("testdata/fixtures_relative_time/posts.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "posts", 2)
+
+		var count int
+		if err := l.db.QueryRow("SELECT COUNT(*) FROM posts WHERE created_at < updated_at").Scan(&count); err != nil {
+			t.Errorf("cannot query posts: %v", err)
+			return
+		}
+		if count != 2 {
+			t.Errorf("expected the posts to be created before being updated, got %d", count)
+		}
+	})
+
+	t.Run("LoadWithFileValues", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				FileValues(),
+				Directory("testdata/fixtures_file_values"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		var content string
+		if err := l.db.QueryRow("SELECT content FROM posts WHERE id = 1").Scan(&content); err != nil {
+			t.Errorf("cannot query posts: %v", err)
+			return
+		}
+		if !strings.Contains(content, "<p>Post content</p>") {
+			t.Errorf("expected the content of the referenced file, got %q", content)
+		}
+		var data []byte
+		if err := l.db.QueryRow("SELECT data FROM assets WHERE id = 1").Scan(&data); err != nil {
+			t.Errorf("cannot query assets: %v", err)
+			return
+		}
+		if !bytes.HasPrefix(data, []byte{0x00, 0x01, 0xfe, 0xff}) {
+			t.Errorf("expected the bytes of the referenced file, got %x", data)
+		}
+	})
+
+	t.Run("LoadWithFileEncoding", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				FileEncoding(charmap.ISO8859_1, "posts"),
+				Files("testdata/fixtures_latin1/posts.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		var title, content string
+		if err := l.db.QueryRow("SELECT title, content FROM posts WHERE id = 1").Scan(&title, &content); err != nil {
+			t.Errorf("cannot query posts: %v", err)
+			return
+		}
+		if title != "Café crème" || content != "Ça a été très apprécié à Zürich" {
+			t.Errorf("expected the accented characters to be kept, got %q and %q", title, content)
+		}
+	})
+
+	t.Run("LoadWithContinueOnError", func(t *testing.T) {
+		if schemaLoader.perTableTransaction {
+			t.Skip("ContinueOnError can't be used together with PerTableTransaction")
+		}
+
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				ContinueOnError(true),
+				SkipDuplicateKeysCheck(),
+				Files(
+					"testdata/fixtures/posts.yml",
+					"testdata/fixtures_duplicates/tags.yml",
+					"testdata/fixtures/comments.yml",
+				),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		result, err := l.LoadWithResult()
+		var fileErrs *FileLoadErrors
+		if !errors.As(err, &fileErrs) {
+			t.Errorf("expected a FileLoadErrors, got %v", err)
+			return
+		}
+		if expected := []string{"testdata/fixtures_duplicates/tags.yml"}; !reflect.DeepEqual(fileErrs.Files, expected) {
+			t.Errorf("expected failed files %v, got %v", expected, fileErrs.Files)
+		}
+		if result.FilesLoaded != 2 {
+			t.Errorf("expected 2 files loaded, got %d", result.FilesLoaded)
+		}
+		assertCount(t, l, "posts", 2)
+		assertCount(t, l, "tags", 0)
+		assertCount(t, l, "comments", 4)
+	})
+
+	t.Run("LoadWithAnalyzeAfterLoad", func(t *testing.T) {
+		var statements []string
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				AnalyzeAfterLoad(),
+				Logger(func(sql string, args []interface{}) {
+					statements = append(statements, sql)
+				}),
+				Files("testdata/fixtures/tags.yml", "testdata/fixtures_empty/votes.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		result, err := l.LoadWithResult()
+		if err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if len(result.AnalyzeErrors) != 0 {
+			t.Errorf("expected no analyze errors, got %v", result.AnalyzeErrors)
+		}
+
+		// Only the tables that got records are analyzed.
+		expected := l.helper.(analyzer).analyzeTableSQL("tags")
+		if last := statements[len(statements)-1]; last != expected {
+			t.Errorf("expected the last statement to be %q, got %q", expected, last)
+		}
+		for _, statement := range statements {
+			if statement == l.helper.(analyzer).analyzeTableSQL("votes") {
+				t.Errorf("expected the empty table not to be analyzed")
+			}
+		}
+		assertCount(t, l, "tags", 3)
+	})
+
+	t.Run("LoadWithProgress", func(t *testing.T) {
+		var events []ProgressEvent
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Progress(func(event ProgressEvent) {
+					events = append(events, event)
+				}),
+				ProgressEveryRows(2),
+				Files(
+					"testdata/fixtures/posts.yml",
+					"testdata/fixtures/comments.yml",
+				),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		var cleanups, inserts []string
+		for _, event := range events {
+			switch event.Phase {
+			case ProgressCleanup:
+				cleanups = append(cleanups, event.Table)
+			case ProgressInsert:
+				inserts = append(inserts, fmt.Sprintf("%s:%d", event.Table, event.RowsDone))
+			}
+			if event.TotalRows != 6 {
+				t.Errorf("expected 6 total rows, got %d", event.TotalRows)
+			}
+		}
+		if len(cleanups) != 2 {
+			t.Errorf("expected a cleanup event per table, got %v", cleanups)
+		}
+		// posts has 2 records and comments 4: an event every 2 rows plus
+		// one at the end of each table.
+		expected := []string{"posts:2", "posts:2", "comments:4", "comments:6", "comments:6"}
+		if !reflect.DeepEqual(inserts, expected) {
+			t.Errorf("expected insert events %v, got %v", expected, inserts)
+		}
+	})
+
+	t.Run("LoadWithDebugConstraintErrors", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				DebugConstraintErrors(),
+				SkipDuplicateKeysCheck(),
+				Files("testdata/fixtures_conflicts/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		err = l.Load()
+		var insertErr *InsertError
+		if !errors.As(err, &insertErr) {
+			t.Errorf("expected an InsertError, got %v", err)
+			return
+		}
+		if insertErr.Record["name"] != "Duplicated" {
+			t.Errorf("expected the duplicated record, got %v", insertErr.Record)
+		}
+		// The row with the same created_at but another id doesn't conflict.
+		if len(insertErr.ExistingRows) != 1 || insertErr.ExistingRows[0]["name"] != "Go" {
+			t.Errorf("expected the existing row, got %v", insertErr.ExistingRows)
+		}
+	})
+
+	t.Run("LoadWithLabelReferences", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				LabelReferences(),
+				Directory("testdata/fixtures_label_references"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		var postID int
+		if err := l.db.QueryRow("SELECT post_id FROM comments WHERE id = 1").Scan(&postID); err != nil {
+			t.Errorf("cannot query comment: %v", err)
+			return
+		}
+		if postID != 20 {
+			t.Errorf("expected the comment to reference post 20, got %d", postID)
+		}
+	})
+
+	t.Run("LoadWithUnknownColumns", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Files("testdata/fixtures_unknown_columns/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		err = l.Load()
+		var insertErr *InsertError
+		if !errors.As(err, &insertErr) {
+			t.Errorf("expected an InsertError, got %v", err)
+			return
+		}
+		if expected := []string{"color", "deprecated"}; !reflect.DeepEqual(insertErr.UnknownColumns, expected) {
+			t.Errorf("expected unknown columns %v, got %v", expected, insertErr.UnknownColumns)
+		}
+		if !strings.Contains(err.Error(), "tags.yml has columns not in table") {
+			t.Errorf("error should name the file and the columns, but was: %v", err)
+		}
+	})
+
+	t.Run("LoadWithUnknownColumnsOnSingleConnection", func(t *testing.T) {
+		// The columns of the table must not be queried besides the
+		// transaction of the load, which holds the only connection.
+		singleDB, err := sql.Open(dialect, connStr)
+		if err != nil {
+			t.Errorf("failed to open database: %v", err)
+			return
+		}
+		defer singleDB.Close()
+		singleDB.SetMaxOpenConns(1)
+
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(singleDB),
+				Dialect(dialect),
+				Files("testdata/fixtures_unknown_columns/tags.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err = l.LoadContext(ctx)
+		if ctx.Err() != nil {
+			t.Fatalf("load should not wait for a second connection: %v", err)
+		}
+		var insertErr *InsertError
+		if !errors.As(err, &insertErr) {
+			t.Errorf("expected an InsertError, got %v", err)
+			return
+		}
+		if expected := []string{"color", "deprecated"}; !reflect.DeepEqual(insertErr.UnknownColumns, expected) {
+			t.Errorf("expected unknown columns %v, got %v", expected, insertErr.UnknownColumns)
+		}
+	})
+
+	t.Run("LoadSelfReferencingWithNullifyBeforeDelete", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				SkipReferentialIntegrity(),
+				NullifyBeforeDelete("employees", "manager_id"),
+				Files("testdata/fixtures_self_reference/employees.yml"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		// Loading twice makes sure the rows referencing each other
+		// can be deleted.
+		for i := 0; i < 2; i++ {
+			if err := l.Load(); err != nil {
+				t.Errorf("cannot load fixtures: %v", err)
+				return
+			}
+		}
+		assertCount(t, l, "employees", 3)
+	})
+
+	t.Run("LoadWithDefaults", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_defaults"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 3)
+
+		// created_at is only given by _defaults, and updated_at is
+		// overridden by one record.
+		var count int
+		if err := l.db.QueryRow("SELECT COUNT(*) FROM tags WHERE created_at = updated_at").Scan(&count); err != nil {
+			t.Errorf("cannot query tags: %v", err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 tags with the default times, got %d", count)
+		}
+	})
+
+	t.Run("LoadWithExtends", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_extends"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "posts", 3)
+		assertCount(t, l, "comments", 2)
+	})
+
+	t.Run("LoadWithContentFilter", func(t *testing.T) {
+		t.Setenv("TESTFIXTURES_TAG_NAME", "Golang")
+		var names []string
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				ContentFilter(func(name string, content []byte) ([]byte, error) {
+					names = append(names, name)
+					return []byte(os.ExpandEnv(string(content))), nil
+				}),
+				Directory("testdata/fixtures_content_filter"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if len(names) != 1 || filepath.ToSlash(names[0]) != "testdata/fixtures_content_filter/tags.yml" {
+			t.Errorf("expected the filter to be given the path of the file, got %v", names)
+		}
+
+		var name string
+		if err := l.db.QueryRow("SELECT name FROM tags").Scan(&name); err != nil {
+			t.Errorf("cannot query tags: %v", err)
+		}
+		if name != "Golang" {
+			t.Errorf("expected the name from the environment, got %q", name)
+		}
+
+		_, err = New(
+			Database(db),
+			Dialect(dialect),
+			ContentFilter(func(string, []byte) ([]byte, error) { return nil, errors.New("boom") }),
+			Directory("testdata/fixtures_content_filter"),
+		)
+		if err == nil || !strings.Contains(err.Error(), "boom") {
+			t.Errorf("expected the error of the filter, got %v", err)
+		}
+	})
+
+	t.Run("LoadAndRollback", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Template(),
+				TemplateData(map[string]interface{}{
+					"PostIds": []int{1, 2},
+					"TagIds":  []int{1, 2, 3},
+				}),
+				Directory("testdata/fixtures"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		called := false
+		assertPosts := func(tx *sql.Tx) error {
+			called = true
+			var count int
+			if err := tx.QueryRow("SELECT COUNT(*) FROM posts").Scan(&count); err != nil {
+				return err
+			}
+			if count != 2 {
+				t.Errorf("expected the posts in the transaction, got %d", count)
+			}
+			return nil
+		}
+		if l.perTableTransaction || l.limitsMutations() {
+			if err := l.LoadAndRollback(assertPosts); err == nil {
+				t.Error("expected LoadAndRollback to be rejected")
+			}
+			return
+		}
+
+		if err := l.Clean(); err != nil {
+			t.Errorf("cannot clean tables: %v", err)
+			return
+		}
+		if err := l.LoadAndRollback(assertPosts); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		if !called {
+			t.Error("expected the function to be called")
+		}
+		for _, table := range []string{"posts", "comments", "tags", "posts_tags", "users"} {
+			assertCount(t, l, table, 0)
+		}
+
+		errBoom := errors.New("boom")
+		err = l.LoadAndRollback(func(*sql.Tx) error { return errBoom })
+		if !errors.Is(err, errBoom) {
+			t.Errorf("expected the error of the function, got %v", err)
+		}
+		assertCount(t, l, "posts", 0)
+
+		// The tables are filled again by the next load.
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertFixturesLoaded(t, l)
+	})
+
+	t.Run("LoadWithRepeat", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_repeat"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 101)
+	})
+
+	t.Run("LoadFromStruct", func(t *testing.T) {
+		type tag struct {
+			ID        int       `db:"id"`
+			Name      string    `db:"name"`
+			CreatedAt time.Time `db:"created_at"`
+			UpdatedAt time.Time `db:"updated_at"`
+		}
+		now := time.Date(2020, 12, 31, 23, 59, 59, 0, time.UTC)
+		content, err := FixtureFromStruct("tags", []tag{
+			{ID: 1, Name: "Go", CreatedAt: now, UpdatedAt: now},
+			{ID: 2, Name: "SQL", CreatedAt: now, UpdatedAt: now},
+		})
+		if err != nil {
+			t.Errorf("cannot generate fixture: %v", err)
+			return
+		}
+
+		dir, err := ioutil.TempDir(os.TempDir(), "testfixtures_test")
+		if err != nil {
+			t.Errorf("cannot create temp dir: %v", err)
+			return
+		}
+		path := filepath.Join(dir, "tags.yml")
+		if err := ioutil.WriteFile(path, content, 0600); err != nil {
+			t.Errorf("cannot write fixture: %v", err)
+			return
+		}
+
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				FilesMultiTables(path),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertCount(t, l, "tags", 2)
+	})
+
+	t.Run("GenerateAndLoadNulls", func(t *testing.T) {
+		l, err := New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_nulls"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+
+		dir, err := ioutil.TempDir(os.TempDir(), "testfixtures_test")
+		if err != nil {
+			t.Errorf("cannot create temp dir: %v", err)
+			return
+		}
+		dumper, err := NewDumper(
+			DumpDatabase(db),
+			DumpDialect(dialect),
+			DumpDirectory(dir),
+			DumpTables("nullables"),
+		)
+		if err != nil {
+			t.Errorf("could not create dumper: %v", err)
+			return
+		}
+		if err := dumper.Dump(); err != nil {
+			t.Errorf("cannot generate fixtures: %v", err)
+			return
+		}
+
+		l, err = New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory(dir),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertTextValue(t, l, 1, sql.NullString{})
+		assertTextValue(t, l, 2, sql.NullString{String: "", Valid: true})
+		assertTextValue(t, l, 3, sql.NullString{String: "NULL", Valid: true})
+
+		l, err = New(append(
+			[]func(*Loader) error{
+				Database(db),
+				Dialect(dialect),
+				Directory("testdata/fixtures_nulls"),
+				NullValue("NULL"),
+			},
+			additionalOptions...,
+		)...)
+		if err != nil {
+			t.Errorf("failed to create Loader: %v", err)
+			return
+		}
+		if err := l.Load(); err != nil {
+			t.Errorf("cannot load fixtures: %v", err)
+			return
+		}
+		assertTextValue(t, l, 3, sql.NullString{})
+	})
+
+	t.Run("InsertAfterLoad", func(t *testing.T) {
+		// This test was originally written to catch a bug where it
+		// wasn't possible to insert a record on PostgreSQL due
+		// sequence issues.
+
+		var sql string
+		switch schemaLoader.helper.paramType() {
+		case paramTypeDollar:
+			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES ($1, $2, $3, $4)"
+		case paramTypeQuestion:
+			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES (?, ?, ?, ?)"
+		case paramTypeAtSign:
+			sql = "INSERT INTO posts (title, content, created_at, updated_at) VALUES (@p1, @p2, @p3, @p4)"
+		default:
+			panic("unrecognized param type")
+		}
+
+		_, err = db.Exec(sql, "Post title", "Post content", time.Now(), time.Now())
+		if err != nil {
+			t.Errorf("cannot insert post: %v", err)
+		}
+	})
+}
+
+// wrappedDB is a DB other than *sql.DB, as the handles of connection pool
+// wrappers usually are.
+type wrappedDB struct {
+	db *sql.DB
+}
+
+func (w wrappedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return w.db.ExecContext(ctx, query, args...)
+}
+
+func (w wrappedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return w.db.QueryContext(ctx, query, args...)
+}
+
+func (w wrappedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return w.db.QueryRowContext(ctx, query, args...)
+}
+
+func (w wrappedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return w.db.BeginTx(ctx, opts)
+}
+
+// recordingDB records the methods called on a DB, like an instrumented
+// wrapper would trace them.
+type recordingDB struct {
+	DB
+	calls []string
+}
+
+func (r *recordingDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.calls = append(r.calls, "ExecContext")
+	return r.DB.ExecContext(ctx, query, args...)
+}
+
+func (r *recordingDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	r.calls = append(r.calls, "QueryContext")
+	return r.DB.QueryContext(ctx, query, args...)
+}
+
+func (r *recordingDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	r.calls = append(r.calls, "QueryRowContext")
+	return r.DB.QueryRowContext(ctx, query, args...)
+}
+
+func (r *recordingDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	r.calls = append(r.calls, "BeginTx")
+	return r.DB.BeginTx(ctx, opts)
+}
+
+var (
+	_ DB = &sql.DB{}
+	_ DB = wrappedDB{}
+	_ DB = &recordingDB{}
+	// Types embedding *sql.DB, like *sqlx.DB, are accepted as well.
+	_ DB = struct{ *sql.DB }{}
+)
+
+func assertFixturesLoaded(t *testing.T, l *Loader) { //nolint
+	assertCount(t, l, "posts", 2)
+	assertCount(t, l, "comments", 4)
+	assertCount(t, l, "tags", 3)
+	assertCount(t, l, "posts_tags", 6)
+	assertCount(t, l, "users", 2)
+	assertCount(t, l, "assets", 1)
+}
+
+func assertCount(t *testing.T, l *Loader, table string, expectedCount int) { //nolint
+	count := 0
+	sql := fmt.Sprintf("SELECT COUNT(*) FROM %s", l.helper.quoteKeyword(table))
+
+	row := l.db.QueryRow(sql)
+	if err := row.Scan(&count); err != nil {
+		t.Errorf("cannot query table: %v", err)
+	}
+
+	if count != expectedCount {
+		t.Errorf("%s should have %d, but has %d", table, expectedCount, count)
+	}
+}
+
+func assertTextValue(t *testing.T, l *Loader, id int, expected sql.NullString) { //nolint
+	var value sql.NullString
+	sql := fmt.Sprintf("SELECT text_value FROM nullables WHERE id = %d", id)
+
+	if err := l.db.QueryRow(sql).Scan(&value); err != nil {
+		t.Errorf("cannot query table: %v", err)
+	}
+
+	if value != expected {
+		t.Errorf("nullables %d should have %+v, but has %+v", id, expected, value)
+	}
+}
+
+func TestFixtureFromStruct(t *testing.T) {
+	type base struct {
+		ID int `db:"id"`
+	}
+	type user struct {
+		base
+		Name     string         `db:"name"`
+		Email    *string        `db:"email"`
+		Bio      sql.NullString `db:"bio"`
+		Password string         `db:"-"`
+		Age      int
+		internal string
+	}
+
+	content, err := FixtureFromStruct("users", []*user{
+		{base: base{ID: 1}, Name: "John", Bio: sql.NullString{String: "Gopher", Valid: true}, Password: "secret", Age: 30},
+	})
+	if err != nil {
+		t.Fatalf("cannot generate fixture: %v", err)
+	}
+	expected := `users:
+- id: 1
+  name: John
+  email: null
+  bio: Gopher
+  age: 30
+`
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	if _, err := FixtureFromStruct("users", user{}); err == nil {
+		t.Error("expected an error when rows is not a slice")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		script   string
+		expected []string
+	}{
+		{
+			name:     "Simple",
+			dialect:  "postgres",
+			script:   "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			expected: []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			name:     "Comments",
+			dialect:  "postgres",
+			script:   "-- a; comment\nDROP TABLE a; /* b; */\n-- trailing",
+			expected: []string{"-- a; comment\nDROP TABLE a"},
+		},
+		{
+			name:     "NestedComments",
+			dialect:  "postgres",
+			script:   "/* outer /* inner; */ still; a comment */ SELECT 1; SELECT 2",
+			expected: []string{"/* outer /* inner; */ still; a comment */ SELECT 1", "SELECT 2"},
+		},
+		{
+			name:     "Quotes",
+			dialect:  "postgres",
+			script:   `INSERT INTO a VALUES ('a;''b', "c;d"); SELECT 1`,
+			expected: []string{`INSERT INTO a VALUES ('a;''b', "c;d")`, "SELECT 1"},
+		},
+		{
+			name:    "DollarQuotes",
+			dialect: "postgres",
+			script:  "CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE SQL;\nCREATE FUNCTION g() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;",
+			expected: []string{
+				"CREATE FUNCTION f() RETURNS INT AS $$ SELECT 1; $$ LANGUAGE SQL",
+				"CREATE FUNCTION g() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql",
+			},
+		},
+		{
+			name:     "MySQLBackslashEscapes",
+			dialect:  "mysql",
+			script:   "INSERT INTO a VALUES ('a\\';b', `c;d`); # a; comment\nSELECT 1",
+			expected: []string{"INSERT INTO a VALUES ('a\\';b', `c;d`)", "# a; comment\nSELECT 1"},
+		},
+		{
+			name:    "MySQLDelimiter",
+			dialect: "mysql",
+			script:  "DROP PROCEDURE IF EXISTS p;\nDELIMITER //\nCREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END//\nDELIMITER ;\nCALL p();\n",
+			expected: []string{
+				"DROP PROCEDURE IF EXISTS p",
+				"CREATE PROCEDURE p() BEGIN SELECT 1; SELECT 2; END",
+				"CALL p()",
+			},
+		},
+		{
+			name:    "SQLServerGO",
+			dialect: "sqlserver",
+			script:  "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\nGO\ngo\nCREATE SCHEMA s;\n  GO  \r\nSELECT 1",
+			expected: []string{
+				"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);",
+				"CREATE SCHEMA s;",
+				"SELECT 1",
+			},
+		},
+		{
+			name:    "SQLServerGOInsideStringsAndComments",
+			dialect: "sqlserver",
+			script:  "INSERT INTO a VALUES ('\nGO\n');\n/*\nGO\n*/\nSELECT 1\nGO\nSELECT 2\n-- GO\n",
+			expected: []string{
+				"INSERT INTO a VALUES ('\nGO\n');\n/*\nGO\n*/\nSELECT 1",
+				"SELECT 2\n-- GO",
+			},
+		},
+		{
+			name:     "SQLite",
+			dialect:  "sqlite3",
+			script:   "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			expected: []string{"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statements, err := SplitStatements(test.dialect, []byte(test.script))
+			if err != nil {
+				t.Fatalf("cannot split statements: %v", err)
+			}
+			actual := make([]string, 0, len(statements))
+			for _, statement := range statements {
+				actual = append(actual, string(statement))
+			}
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+
+	if _, err := SplitStatements("unknown", nil); err == nil {
+		t.Error("should return an error for an unknown dialect")
+	}
+}
+
+func TestPostgreSQLCleanTableSQL(t *testing.T) {
+	h := &postgreSQL{timescaleDB: true, hypertables: map[string]bool{"public.metrics": true}}
+	tests := []struct {
+		table    string
+		expected string
+	}{
+		{"metrics", `TRUNCATE TABLE "metrics"`},
+		{"public.metrics", `TRUNCATE TABLE "public"."metrics"`},
+		{"posts", `DELETE FROM "posts"`},
+	}
+
+	for _, test := range tests {
+		if actual := h.cleanTableSQL(test.table); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+}
+
+func TestColumnCast(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}}
+	if err := ColumnCast("users", "mood", "text::mood")(l); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixtureFile{fileName: "users.yml"}
+	sqlStr, _, _, err := l.buildInsertSQL(f, map[interface{}]interface{}{"mood": "happy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "users" ("mood") VALUES ($1::text::mood)`; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+
+	f = &fixtureFile{fileName: "posts.yml"}
+	sqlStr, _, _, err = l.buildInsertSQL(f, map[interface{}]interface{}{"mood": "happy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "posts" ("mood") VALUES ($1)`; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+}
+
+func TestFileCasts(t *testing.T) {
+	for _, content := range []string{
+		"- _casts:\n    id: uuid\n    meta: jsonb\n- id: 5a8e7c1e-3f0e-4d6a-9c3b-2f6f1b2c3d4e\n  meta: '{}'\n  name: Go\n",
+		"_casts:\n  id: uuid\n  meta: jsonb\ngolang:\n  id: 5a8e7c1e-3f0e-4d6a-9c3b-2f6f1b2c3d4e\n  meta: '{}'\n  name: Go\n",
+	} {
+		l := &Loader{helper: &postgreSQL{}}
+		if err := ColumnCast("tags", "meta", "json")(l); err != nil {
+			t.Fatal(err)
+		}
+		f := &fixtureFile{fileName: "tags.yml", content: []byte(content)}
+		if err := l.buildFileInsertSQLs(f); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.insertSQLs) != 1 {
+			t.Fatalf("expected 1 record, got %d", len(f.insertSQLs))
+		}
+		expected := `INSERT INTO "tags" ("id", "meta", "name") VALUES ($1::uuid, $2::jsonb, $3)`
+		if sqlStr := f.insertSQLs[0].sql; sqlStr != expected {
+			t.Errorf("expected %s, got %s", expected, sqlStr)
+		}
+	}
+
+	l := &Loader{helper: &sqlite{}}
+	f := &fixtureFile{fileName: "tags.yml", content: []byte("- _casts:\n    id: uuid\n- id: 1\n")}
+	if err := l.buildFileInsertSQLs(f); err == nil || !strings.Contains(err.Error(), "not supported by this dialect") {
+		t.Errorf("expected an error for SQLite, got %v", err)
+	}
+
+	f = &fixtureFile{fileName: "tags.yml", content: []byte("- _casts: uuid\n- id: 1\n")}
+	if err := l.buildFileInsertSQLs(f); err == nil {
+		t.Error("expected an error for invalid casts")
+	}
+}
+
+func TestColumnType(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}}
+	if err := ColumnType("posts", "tags", "text[]")(l); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixtureFile{fileName: "posts.yml"}
+	record := map[interface{}]interface{}{
+		"tags":       []interface{}{"go", `say "hi"`, `back\slash`, nil},
+		"attributes": []interface{}{"go"},
+	}
+	sqlStr, values, _, err := l.buildInsertSQL(f, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "posts" ("attributes", "tags") VALUES ($1, $2::text[])`; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+	if expected := `["go"]`; values[0] != expected {
+		t.Errorf("expected %s, got %v", expected, values[0])
+	}
+	if expected := `{"go","say \"hi\"","back\\slash",NULL}`; values[1] != expected {
+		t.Errorf("expected %s, got %v", expected, values[1])
+	}
+
+	nested, err := pgArrayLiteral([]interface{}{[]interface{}{1, 2}, []interface{}{3, 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{{"1","2"},{"3","4"}}`; nested != expected {
+		t.Errorf("expected %s, got %s", expected, nested)
+	}
+
+	if err := ColumnType("posts", "title", "text")(l); err != nil {
+		t.Fatal(err)
+	}
+	record = map[interface{}]interface{}{"title": []interface{}{"go"}}
+	if _, _, _, err := l.buildInsertSQL(f, record); err == nil {
+		t.Error("should return an error for a list in a column that is not an array")
+	}
+	if _, err := New(DangerousSkipTestDatabaseCheck(), Database(&sql.DB{}), Dialect("mysql"), ColumnType("posts", "tags", "text[]")); err == nil {
+		t.Error("should return an error for other databases than PostgreSQL")
+	}
+}
+
+func TestDuckDBColumnType(t *testing.T) {
+	l := &Loader{helper: &duckDB{}}
+	if err := ColumnType("users", "tags", "VARCHAR[]")(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := ColumnType("users", "address", "STRUCT(city VARCHAR, zip INTEGER)")(l); err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fixtureFile{fileName: "users.yml"}
+	record := map[interface{}]interface{}{
+		"tags":    []interface{}{"go", "it's", nil, []interface{}{1, true}},
+		"address": map[interface{}]interface{}{"zip": 75001, "city": "Paris"},
+	}
+	sqlStr, values, _, err := l.buildInsertSQL(f, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "users" ("address", "tags") VALUES (?::STRUCT(city VARCHAR, zip INTEGER), ?::VARCHAR[])`; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+	if expected := `{'city': 'Paris', 'zip': 75001}`; values[0] != expected {
+		t.Errorf("expected %s, got %v", expected, values[0])
+	}
+	if expected := `['go', 'it''s', NULL, [1, true]]`; values[1] != expected {
+		t.Errorf("expected %s, got %v", expected, values[1])
+	}
+}
+
+func TestDuckDBDependencyOrder(t *testing.T) {
+	h, err := helperForDialect("duckdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{helper: h}
+	if !l.inDependencyOrder() {
+		t.Error("DuckDB fixtures should be loaded in dependency order")
+	}
+	if l := (&Loader{helper: &postgreSQL{}}); l.inDependencyOrder() {
+		t.Error("PostgreSQL fixtures should not require dependency order")
+	}
+}
+
+func TestJSONColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{
+			name:     "NestedMap",
+			value:    map[interface{}]interface{}{"address": map[interface{}]interface{}{"city": "Paris"}},
+			expected: `{"address":{"city":"Paris"}}`,
+		},
+		{
+			name:     "NonStringKeys",
+			value:    map[interface{}]interface{}{1: "one", true: []interface{}{1, "two"}},
+			expected: `{"1":"one","true":[1,"two"]}`,
+		},
+		{
+			name:     "String",
+			value:    "guest",
+			expected: `"guest"`,
+		},
+		{
+			name:     "Number",
+			value:    42,
+			expected: `42`,
+		},
+		{
+			name:     "Null",
+			value:    nil,
+			expected: nil,
+		},
+	}
+
+	l := &Loader{helper: &postgreSQL{}}
+	if err := JSONColumns("users", "attributes")(l); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := &fixtureFile{fileName: "users.yml"}
+			_, values, _, err := l.buildInsertSQL(f, map[interface{}]interface{}{"attributes": test.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if values[0] != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, values[0])
+			}
+		})
+	}
+
+	t.Run("OtherColumns", func(t *testing.T) {
+		f := &fixtureFile{fileName: "users.yml"}
+		_, values, _, err := l.buildInsertSQL(f, map[interface{}]interface{}{"name": "guest"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if values[0] != "guest" {
+			t.Errorf(`expected "guest", got %v`, values[0])
+		}
+	})
+}
+
+func TestUpsert(t *testing.T) {
+	tests := []struct {
+		name     string
+		helper   helper
+		options  []func(*Loader) error
+		record   map[interface{}]interface{}
+		expected string
+	}{
+		{
+			name:     "PostgreSQL",
+			helper:   &postgreSQL{},
+			record:   map[interface{}]interface{}{"name": "Go"},
+			expected: `INSERT INTO "tags" ("name") VALUES ($1) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			name:     "SQLite",
+			helper:   &sqlite{},
+			record:   map[interface{}]interface{}{"name": "Go"},
+			expected: `INSERT INTO "tags" ("name") VALUES (?) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name"`,
+		},
+		{
+			name:     "MySQL",
+			helper:   &mySQL{},
+			record:   map[interface{}]interface{}{"name": "Go"},
+			expected: "INSERT INTO `tags` (`name`) VALUES (?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)",
+		},
+		{
+			name:     "PrimaryKey",
+			helper:   &postgreSQL{},
+			options:  []func(*Loader) error{PrimaryKey("tags", "post_id", "tag_id")},
+			record:   map[interface{}]interface{}{"post_id": 1},
+			expected: `INSERT INTO "tags" ("post_id") VALUES ($1) ON CONFLICT ("post_id", "tag_id") DO NOTHING`,
+		},
+		{
+			name:     "MySQLOnlyPrimaryKey",
+			helper:   &mySQL{},
+			record:   map[interface{}]interface{}{"id": 1},
+			expected: "INSERT INTO `tags` (`id`) VALUES (?) ON DUPLICATE KEY UPDATE `id` = `id`",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Loader{helper: test.helper}
+			for _, option := range append([]func(*Loader) error{Upsert()}, test.options...) {
+				if err := option(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "tags.yml"}, test.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sqlStr != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, sqlStr)
+			}
+		})
+	}
+}
+
+func TestCaptureInsertedIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		helper   helper
+		options  []func(*Loader) error
+		expected string
+	}{
+		{
+			name:     "PostgreSQL",
+			helper:   &postgreSQL{},
+			expected: `INSERT INTO "tags" ("name") VALUES ($1) RETURNING "id"`,
+		},
+		{
+			name:     "SQLite",
+			helper:   &sqlite{},
+			expected: `INSERT INTO "tags" ("name") VALUES (?) RETURNING "id"`,
+		},
+		{
+			name:     "MySQL",
+			helper:   &mySQL{},
+			expected: "INSERT INTO `tags` (`name`) VALUES (?)",
+		},
+		{
+			name:     "SQLServer",
+			helper:   &sqlserver{},
+			expected: "INSERT INTO [tags] ([name]) OUTPUT INSERTED.[id] VALUES (?)",
+		},
+		{
+			name:     "PrimaryKey",
+			helper:   &postgreSQL{},
+			options:  []func(*Loader) error{PrimaryKey("tags", "code")},
+			expected: `INSERT INTO "tags" ("name") VALUES ($1) RETURNING "code"`,
+		},
+		{
+			name:     "Upsert",
+			helper:   &postgreSQL{},
+			options:  []func(*Loader) error{Upsert()},
+			expected: `INSERT INTO "tags" ("name") VALUES ($1) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name" RETURNING "id"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l := &Loader{helper: test.helper}
+			for _, option := range append([]func(*Loader) error{CaptureInsertedIDs()}, test.options...) {
+				if err := option(l); err != nil {
+					t.Fatal(err)
+				}
+			}
+			record := map[interface{}]interface{}{"name": "Go"}
+			sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "tags.yml"}, record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sqlStr != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, sqlStr)
+			}
+		})
+	}
+
+	t.Run("RequiresOption", func(t *testing.T) {
+		l := &Loader{helper: &postgreSQL{}}
+		if _, err := l.InsertedID("tags", 0); err == nil {
+			t.Error("should return an error without CaptureInsertedIDs")
+		}
+	})
+}
+
+func TestSetsIdentityColumn(t *testing.T) {
+	tests := []struct {
+		name           string
+		identityColumn string
+		columns        []string
+		expected       bool
+	}{
+		{name: "NoIdentityColumn", columns: []string{"id", "name"}},
+		{name: "IDOmitted", identityColumn: "id", columns: []string{"name"}},
+		{name: "IDSet", identityColumn: "id", columns: []string{"id", "name"}, expected: true},
+		{name: "CaseInsensitive", identityColumn: "ID", columns: []string{"id"}, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := setsIdentityColumn(test.identityColumn, test.columns); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestStrToInterval(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"DURATION=90m", "01:30:00"},
+		{"DURATION=-1h30m", "-01:30:00"},
+		{"DURATION=36h1.5s", "36:00:01.500000"},
+		{"DURATION=0s", "00:00:00"},
+	}
+
+	for _, test := range tests {
+		actual, err := tryStrToInterval(test.value)
+		if err != nil {
+			t.Errorf("cannot convert %s: %v", test.value, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+
+	if _, err := tryStrToInterval("DURATION=1 hour"); err == nil {
+		t.Error("should return an error for an invalid duration")
+	}
+	if _, err := tryStrToInterval("1h"); err == nil {
+		t.Error("should return an error for a string without the prefix")
+	}
+}
+
+func TestRegisterHelper(t *testing.T) {
+	RegisterHelper("fakedb", func() Helper { return FakeHelper{} })
+	defer RegisterHelper("fakedb", nil)
+
+	h, err := helperForDialect("fakedb")
+	if err != nil {
+		t.Fatalf("should find the registered dialect: %v", err)
+	}
+
+	l := &Loader{helper: h}
+	sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "tags.yml"}, map[interface{}]interface{}{"name": "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "INSERT INTO [tags] ([name]) VALUES (:1)"; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+
+	RegisterHelper("fakedb", nil)
+	if _, err := helperForDialect("fakedb"); err == nil {
+		t.Error("should not find an unregistered dialect")
+	}
+}
+
+func TestDeleteWhere(t *testing.T) {
+	tests := []struct {
+		helper   helper
+		table    string
+		expected string
+	}{
+		{&postgreSQL{}, "public.users", `DELETE FROM "public"."users" WHERE tenant_id <> 1`},
+		{&postgreSQL{}, "posts", `DELETE FROM "posts"`},
+		{&mySQL{}, "fixtures.users", "DELETE FROM `fixtures`.`users` WHERE tenant_id <> 1"},
+		{&sqlite{}, "users", `DELETE FROM "users" WHERE tenant_id <> 1`},
+		{&sqlserver{}, "dbo.users", `DELETE FROM [dbo].[users] WHERE tenant_id <> 1`},
+	}
+
+	for _, test := range tests {
+		l := &Loader{helper: test.helper}
+		for _, table := range []string{"public.users", "fixtures.users", "users", "dbo.users"} {
+			if err := DeleteWhere(table, "tenant_id <> 1")(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if actual := l.cleanTableSQL(test.table); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+
+	if err := DeleteWhere("users", " ")(&Loader{}); err == nil {
+		t.Error("should return an error for an empty condition")
+	}
+
+	l := &Loader{helper: &postgreSQL{}}
+	for _, condition := range []string{"tenant_id = 2", "NOT seed"} {
+		if err := DeleteWhere("users", condition)(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := `DELETE FROM "users" WHERE NOT seed`
+	if actual := l.cleanTableSQL("users"); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestQuoteKeyword(t *testing.T) {
+	tests := []struct {
+		helper   helper
+		keyword  string
+		expected string
+	}{
+		{&postgreSQL{}, `posts_tags`, `"posts_tags"`},
+		{&postgreSQL{}, `test_schema.posts_tags`, `"test_schema"."posts_tags"`},
+		{&postgreSQL{}, `Sales.Order-Items`, `"Sales"."Order-Items"`},
+		{&postgreSQL{}, `my"table`, `"my""table"`},
+		{&sqlserver{}, `posts_tags`, `[posts_tags]`},
+		{&sqlserver{}, `test_schema.posts_tags`, `[test_schema].[posts_tags]`},
+		{&sqlserver{}, `sales.Order-Items`, `[sales].[Order-Items]`},
+		{&sqlserver{}, `my]table`, `[my]]table]`},
+		{&mySQL{}, `posts_tags`, "`posts_tags`"},
+		{&mySQL{}, `test_schema.posts_tags`, "`test_schema`.`posts_tags`"},
+		{&sqlite{}, `posts_tags`, `"posts_tags"`},
+		{&sqlite{}, `main.posts_tags`, `"main"."posts_tags"`},
+		{&firebird{}, `posts_tags`, `"POSTS_TAGS"`},
+		{&firebird{}, `RDB$ADMIN`, `"RDB$ADMIN"`},
+		{&firebird{}, `OrderItems`, `"OrderItems"`},
+	}
+
+	for _, test := range tests {
+		actual := test.helper.quoteKeyword(test.keyword)
+
+		if test.expected != actual {
+			t.Errorf("TestQuoteKeyword keyword %s should have escaped to %s. Received %s instead", test.keyword, test.expected, actual)
+		}
+	}
+}
+
+func TestFirebirdConstraintsSQL(t *testing.T) {
+	h := &firebird{
+		constraints: []fbConstraint{
+			{
+				tableName:         "COMMENTS",
+				constraintName:    "INTEG_12",
+				columns:           []string{"POST_ID"},
+				referencedTable:   "POSTS",
+				referencedColumns: []string{"ID"},
+				updateRule:        "RESTRICT",
+				deleteRule:        "CASCADE",
+			},
+			{
+				tableName:         "POSTS_TAGS",
+				constraintName:    "FK_POSTS_TAGS",
+				columns:           []string{"POST_ID", "TAG_ID"},
+				referencedTable:   "TAGGINGS",
+				referencedColumns: []string{"POST_ID", "TAG_ID"},
+				updateRule:        "SET NULL",
+				deleteRule:        "RESTRICT",
+			},
+		},
+	}
+
+	expectedDrop := []string{
+		`ALTER TABLE "COMMENTS" DROP CONSTRAINT "INTEG_12"`,
+		`ALTER TABLE "POSTS_TAGS" DROP CONSTRAINT "FK_POSTS_TAGS"`,
+	}
+	if actual := h.dropConstraintsSQL(); !reflect.DeepEqual(actual, expectedDrop) {
+		t.Errorf("expected %v, got %v", expectedDrop, actual)
+	}
+
+	expectedCreate := []string{
+		`ALTER TABLE "COMMENTS" ADD CONSTRAINT "INTEG_12" FOREIGN KEY ("POST_ID") REFERENCES "POSTS" ("ID") ON UPDATE NO ACTION ON DELETE CASCADE`,
+		`ALTER TABLE "POSTS_TAGS" ADD CONSTRAINT "FK_POSTS_TAGS" FOREIGN KEY ("POST_ID", "TAG_ID") REFERENCES "TAGGINGS" ("POST_ID", "TAG_ID") ON UPDATE SET NULL ON DELETE NO ACTION`,
+	}
+	if actual := h.createConstraintsSQL(); !reflect.DeepEqual(actual, expectedCreate) {
+		t.Errorf("expected %v, got %v", expectedCreate, actual)
+	}
+
+	for _, dialect := range []string{"firebird", "firebirdsql"} {
+		h, err := helperForDialect(dialect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := h.(*firebird); !ok {
+			t.Errorf("expected a Firebird helper for %s, got %T", dialect, h)
+		}
+	}
+}
+
+func TestSpanner(t *testing.T) {
+	h, err := helperForDialect("spanner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &Loader{helper: h}
+
+	if expected := "DELETE FROM Singers WHERE true"; l.cleanTableSQL("Singers") != expected {
+		t.Errorf("expected %s, got %s", expected, l.cleanTableSQL("Singers"))
+	}
+	sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "Singers.yml"}, map[interface{}]interface{}{"FirstName": "Marc", "SingerId": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "INSERT INTO Singers (FirstName, SingerId) VALUES (@p1, @p2)"; sqlStr != expected {
+		t.Errorf("expected %s, got %s", expected, sqlStr)
+	}
+	if !l.limitsMutations() || !l.inDependencyOrder() {
+		t.Error("Spanner fixtures should be loaded in chunks and in dependency order")
+	}
+}
+
+func TestSQLServerQuotedObjectName(t *testing.T) {
+	h := &sqlserver{}
+	tests := []struct {
+		tableName string
+		expected  string
+	}{
+		{`posts`, `[posts]`},
+		{`sales.Order-Items`, `[sales].[Order-Items]`},
+		{`sales.John's orders`, `[sales].[John''s orders]`},
+	}
+
+	for _, test := range tests {
+		if actual := h.quotedObjectName(test.tableName); actual != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, actual)
+		}
+	}
+}
+
+func TestEnsureTestDatabase(t *testing.T) {
+	tests := []struct {
+		name           string
+		isTestDatabase bool
+	}{
+		{"db_test", true},
+		{"dbTEST", true},
+		{"testdb", true},
+		{"production", false},
+		{"productionTestCopy", true},
+		{"t_e_s_t", false},
+		{"ТESТ", false}, // cyrillic T
+		{sqliteMemoryDatabaseName, true},
+	}
+
+	for _, it := range tests {
+		var (
+			mockedHelper = NewMockHelper(it.name)
+			l            = &Loader{helper: mockedHelper}
+			err          = l.EnsureTestDatabase()
+		)
+		if err != nil && it.isTestDatabase {
+			t.Errorf("EnsureTestDatabase() should return nil for name = %s", it.name)
+		}
+		if err == nil && !it.isTestDatabase {
+			t.Errorf("EnsureTestDatabase() should return error for name = %s", it.name)
+		}
+	}
+
+	t.Run("ExplicitName", func(t *testing.T) {
+		for _, h := range []helper{NewMockHelper(""), &spanner{}} {
+			l := &Loader{helper: h}
+			if err := l.EnsureTestDatabase(); err == nil {
+				t.Errorf("should return an error when the name can't be queried, with %T", h)
+			}
+			if err := DatabaseName("singers_test")(l); err != nil {
+				t.Fatalf("cannot set database name: %v", err)
+			}
+			if err := l.EnsureTestDatabase(); err != nil {
+				t.Errorf("should accept the explicit name, with %T: %v", h, err)
+			}
+		}
+
+		l := &Loader{helper: NewMockHelper("db_test")}
+		if err := DatabaseName("production")(l); err != nil {
+			t.Fatalf("cannot set database name: %v", err)
+		}
+		if err := l.EnsureTestDatabase(); err == nil {
+			t.Error("should check the explicit name instead of the queried one")
+		}
+	})
+
+	t.Run("QueryError", func(t *testing.T) {
+		queryErr := errors.New("connection refused")
+		l := &Loader{helper: &MockHelper{dbName: "", dbNameErr: queryErr}}
+		err := l.EnsureTestDatabase()
+		if !errors.Is(err, queryErr) {
+			t.Errorf("expected the error of the query, got %v", err)
+		}
+		if err != nil && strings.Contains(err.Error(), "test database") {
+			t.Errorf("expected the error not to be about the name, got %v", err)
+		}
+	})
+
+	t.Run("ErrNotTestDatabase", func(t *testing.T) {
+		for name, l := range map[string]*Loader{
+			"Load":   {helper: NewMockHelper("production")},
+			"Schema": {helper: NewMockHelper("production"), schema: "tenant"},
+		} {
+			err := l.Load()
+			if !errors.Is(err, ErrNotTestDatabase) {
+				t.Errorf("%s: expected ErrNotTestDatabase, got %v", name, err)
+			}
+			var notTestErr *NotTestDatabaseError
+			if !errors.As(err, &notTestErr) {
+				t.Fatalf("%s: expected a *NotTestDatabaseError, got %T", name, err)
+			}
+			if notTestErr.Database != "production" {
+				t.Errorf("%s: expected the database name, got %q", name, notTestErr.Database)
+			}
+		}
+
+		l := &Loader{helper: NewMockHelper("production")}
+		if err := l.Clean(); !errors.Is(err, ErrNotTestDatabase) {
+			t.Errorf("Clean: expected ErrNotTestDatabase, got %v", err)
+		}
+		l = &Loader{helper: &MockHelper{dbNameErr: errors.New("connection refused")}}
+		if err := l.Load(); errors.Is(err, ErrNotTestDatabase) {
+			t.Errorf("expected a query error not to match ErrNotTestDatabase, got %v", err)
+		}
+	})
+
+	t.Run("CustomRegexp", func(t *testing.T) {
+		re := regexp.MustCompile(`^ci_.*_db$`)
+		for name, isTestDatabase := range map[string]bool{
+			"ci_fixtures_db": true,
+			"db_test":        false,
+			"ci_production":  false,
+		} {
+			l := &Loader{helper: NewMockHelper(name)}
+			if err := DatabaseNameRegexp(re)(l); err != nil {
+				t.Fatalf("cannot set regexp: %v", err)
+			}
+			err := l.EnsureTestDatabase()
+			if err != nil && isTestDatabase {
+				t.Errorf("EnsureTestDatabase() should return nil for name = %s", name)
+			}
+			if err == nil && !isTestDatabase {
+				t.Errorf("EnsureTestDatabase() should return error for name = %s", name)
+			}
+		}
+	})
+}
+
+func TestTemplateDatabaseNames(t *testing.T) {
+	l := &Loader{helper: NewMockHelper("db_test"), adminDB: &sql.DB{}}
+	if err := l.RestoreFromTemplate("template_test"); err == nil || !strings.Contains(err.Error(), "not supported by this dialect") {
+		t.Errorf("expected an error for an unsupported dialect, got %v", err)
+	}
+
+	l = &Loader{helper: &postgreSQL{}, databaseName: "db_test"}
+	if err := l.CreateTemplate("template_test"); err == nil || !strings.Contains(err.Error(), "AdminDatabase") {
+		t.Errorf("expected an error without AdminDatabase, got %v", err)
+	}
+
+	l.adminDB = &sql.DB{}
+	for _, template := range []string{"", "db_test", "production"} {
+		if _, err := l.templateDatabaseNames(template); err == nil {
+			t.Errorf("expected an error for template %q", template)
+		}
+	}
+	if _, err := l.templateDatabaseNames("production"); !errors.Is(err, ErrNotTestDatabase) {
+		t.Errorf("expected ErrNotTestDatabase, got %v", err)
+	}
+	if dbName, err := l.templateDatabaseNames("template_test"); err != nil || dbName != "db_test" {
+		t.Errorf("expected the database name, got %q, %v", dbName, err)
+	}
+
+	l.databaseName = "production"
+	if _, err := l.templateDatabaseNames("template_test"); !errors.Is(err, ErrNotTestDatabase) {
+		t.Errorf("expected ErrNotTestDatabase for the database, got %v", err)
+	}
+}
+
+func TestLintDirectory(t *testing.T) {
+	issues, err := LintDirectory("testdata/fixtures_lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []LintIssue{
+		{File: "testdata/fixtures_lint/comments.yml", Message: "record 3 has the same id as record 1: 1"},
+		{File: "testdata/fixtures_lint/posts.yml", Message: `duplicate label "first"`},
+		{File: "testdata/fixtures_lint/tags.yml", Line: 2, Message: "did not find expected ',' or ']'"},
+		{File: "testdata/fixtures_lint/users.yml", Message: "no records"},
+		{File: "testdata/fixtures_lint/votes.yml", Message: "record 2 is not a map"},
+		{File: "testdata/fixtures_lint/votes.yml", Message: "record 3 has the non-string key 1"},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Errorf("expected issues:\n%v\ngot:\n%v", expected, issues)
+	}
+
+	if _, err := LintDirectory("testdata/no_such_directory"); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+}
+
+func TestLint(t *testing.T) {
+	l := &Loader{
+		primaryKeys: map[string][]string{"posts_tags": {"post_id", "tag_id"}},
+		fixturesFiles: []*fixtureFile{
+			{
+				path:     "posts_tags.yml",
+				fileName: "posts_tags.yml",
+				content:  []byte("- post_id: 1\n  tag_id: 1\n- post_id: 1\n  tag_id: 2\n- post_id: 1\n  tag_id: 1\n"),
+			},
+		},
+	}
+	expected := []LintIssue{
+		{File: "posts_tags.yml", Message: "record 3 has the same post_id, tag_id as record 1: 1, 1"},
+	}
+	if issues := l.Lint(); !reflect.DeepEqual(issues, expected) {
+		t.Errorf("expected issues %v, got %v", expected, issues)
+	}
+}
+
+func TestCheckDuplicateKeys(t *testing.T) {
+	newLoader := func() *Loader {
+		return &Loader{
+			helper:      &postgreSQL{},
+			primaryKeys: map[string][]string{"posts_tags": {"post_id", "tag_id"}},
+			fixturesFiles: []*fixtureFile{
+				{
+					path:     "fixtures/posts.yml",
+					fileName: "posts.yml",
+					content:  []byte("- id: 1\n  title: First\n- id: 2\n  title: Second\n"),
+				},
+				{
+					path:     "overlay/posts.yml",
+					fileName: "posts.yml",
+					content:  []byte("- id: 3\n  title: Third\n- id: 1\n  title: Duplicated\n"),
+				},
+				{
+					path:     "fixtures/posts_tags.yml",
+					fileName: "posts_tags.yml",
+					content:  []byte("- post_id: 1\n  tag_id: 1\n- post_id: 1\n  tag_id: 2\n- post_id: 1\n  tag_id: 1\n"),
+				},
+			},
+		}
+	}
+
+	err := newLoader().buildInsertSQLs()
+	var duplicateErr *DuplicateKeyError
+	if !errors.As(err, &duplicateErr) {
+		t.Fatalf("expected a DuplicateKeyError, got %v", err)
+	}
+	expected := []DuplicateKey{
+		{Table: "posts", Key: "1", File: "fixtures/posts.yml", Index: 0, OtherFile: "overlay/posts.yml", OtherIndex: 1},
+		{Table: "posts_tags", Key: "1, 1", File: "fixtures/posts_tags.yml", Index: 0, OtherFile: "fixtures/posts_tags.yml", OtherIndex: 2},
+	}
+	if !reflect.DeepEqual(duplicateErr.Duplicates, expected) {
+		t.Errorf("expected duplicates %v, got %v", expected, duplicateErr.Duplicates)
+	}
+
+	l := newLoader()
+	l.skipDuplicateKeysCheck = true
+	if err := l.buildInsertSQLs(); err != nil {
+		t.Errorf("expected no error with SkipDuplicateKeysCheck, got %v", err)
+	}
+}
+
+func TestStrictYAML(t *testing.T) {
+	l := &Loader{helper: NewMockHelper("test")}
+	if err := Directory("testdata/fixtures_strict")(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.buildInsertSQLs(); err != nil {
+		t.Fatalf("expected the duplicate key to be accepted, got %v", err)
+	}
+
+	l = &Loader{helper: NewMockHelper("test")}
+	for _, option := range []func(*Loader) error{StrictYAML(), Directory("testdata/fixtures_strict")} {
+		if err := option(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := l.buildInsertSQLs()
+	if err == nil {
+		t.Fatal("expected an error for the duplicate key")
+	}
+	for _, expected := range []string{"testdata/fixtures_strict/posts.yml", `line 4: key "title" already set in map`} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected the error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+// tarArchive returns a tar archive of the files in a "fixtures" directory,
+// with a file to be skipped.
+func tarArchive(t *testing.T, files ...string) io.Reader {
+	var (
+		buf bytes.Buffer
+		tw  = tar.NewWriter(&buf)
+	)
+	write := func(header *tar.Header, content []byte) {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(&tar.Header{Name: "fixtures/", Typeflag: tar.TypeDir, Mode: 0o755}, nil)
+	write(&tar.Header{Name: "fixtures/README.md", Mode: 0o644, Size: 6}, []byte("# Test"))
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(&tar.Header{Name: "fixtures/" + filepath.Base(file), Mode: 0o644, Size: int64(len(content))}, content)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestTemplateFakeFuncs(t *testing.T) {
+	const content = `{{uuid4}}|{{randomName}}|{{randomEmail}}|{{randomInt 5 7}}|{{randomDate "2020-01-01" "2020-01-31"}}|{{loremWords 3}}`
+
+	process := func(options ...func(*Loader) error) []string {
+		l := &Loader{
+			templateLeftDelim:  "{{",
+			templateRightDelim: "}}",
+		}
+		for _, option := range append([]func(*Loader) error{Template()}, options...) {
+			if err := option(l); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result, err := l.processTemplate([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(string(result), "|")
+	}
+
+	values := process(Seed(42))
+	if again := process(Seed(42)); !reflect.DeepEqual(values, again) {
+		t.Errorf("expected the same values with the same seed, got %v and %v", values, again)
+	}
+	if other := process(Seed(43)); reflect.DeepEqual(values, other) {
+		t.Errorf("expected other values with another seed, got %v", other)
+	}
+
+	checks := []struct {
+		name  string
+		value string
+		re    string
+	}{
+		{"uuid4", values[0], `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{"randomName", values[1], `^[A-Z][a-z]+ [A-Z][a-z]+$`},
+		{"randomEmail", values[2], `^[a-z]+\.[a-z]+\d+@example\.com$`},
+		{"randomInt", values[3], `^[5-7]$`},
+		{"randomDate", values[4], `^2020-01-([012]\d|3[01]) \d\d:\d\d:\d\d$`},
+		{"loremWords", values[5], `^[a-z]+ [a-z]+ [a-z]+$`},
+	}
+	for _, check := range checks {
+		if !regexp.MustCompile(check.re).MatchString(check.value) {
+			t.Errorf("%s: %q doesn't match %s", check.name, check.value, check.re)
+		}
+	}
 
-	row := l.db.QueryRow(sql)
-	if err := row.Scan(&count); err != nil {
-		t.Errorf("cannot query table: %v", err)
+	// The functions given with TemplateFuncs take precedence.
+	values = process(Seed(42), TemplateFuncs(template.FuncMap{
+		"randomName": func() string { return "John Doe" },
+	}))
+	if values[1] != "John Doe" {
+		t.Errorf("expected the function given with TemplateFuncs to be used, got %q", values[1])
 	}
+}
 
-	if count != expectedCount {
-		t.Errorf("%s should have %d, but has %d", table, expectedCount, count)
+func TestTemplateHashFuncs(t *testing.T) {
+	l := &Loader{
+		templateLeftDelim:  "{{",
+		templateRightDelim: "}}",
+	}
+	for _, option := range []func(*Loader) error{Template(), BcryptCost(bcrypt.MinCost)} {
+		if err := option(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const content = `{{bcrypt "secret"}}|{{bcrypt "secret"}}|{{bcrypt 5 "secret"}}|{{sha256 "secret"}}|{{hmacSHA256 "key" "secret"}}`
+	result, err := l.processTemplate([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := strings.Split(string(result), "|")
+
+	for _, hash := range values[:3] {
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("secret")); err != nil {
+			t.Errorf("%q is not a bcrypt hash of the plaintext: %v", hash, err)
+		}
+	}
+	if values[0] != values[1] {
+		t.Errorf("expected the same hash for the same plaintext, got %q and %q", values[0], values[1])
+	}
+	if cost, err := bcrypt.Cost([]byte(values[0])); err != nil || cost != bcrypt.MinCost {
+		t.Errorf("expected the cost given with BcryptCost, got %d (%v)", cost, err)
+	}
+	if cost, err := bcrypt.Cost([]byte(values[2])); err != nil || cost != 5 {
+		t.Errorf("expected the cost given to bcrypt, got %d (%v)", cost, err)
+	}
+	if expected := "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"; values[3] != expected {
+		t.Errorf("sha256: expected %q, got %q", expected, values[3])
+	}
+	if expected := "25cf3c44c8f39313e8cbf7c23e22fe8b2ee8b288ee5206b0a6397583a1f7f0ef"; values[4] != expected {
+		t.Errorf("hmacSHA256: expected %q, got %q", expected, values[4])
+	}
+
+	if _, err := l.processTemplate([]byte(`{{bcrypt "5" "secret"}}`)); err == nil {
+		t.Error("expected an error with a cost that is not a number")
+	}
+	if err := BcryptCost(100)(l); err == nil {
+		t.Error("expected an error with a cost out of range")
 	}
 }
 
-func TestQuoteKeyword(t *testing.T) {
+func TestTemplateUUIDFor(t *testing.T) {
+	l := &Loader{
+		templateLeftDelim:  "{{",
+		templateRightDelim: "}}",
+	}
+	const content = `{{uuidFor "posts/first"}}|{{uuidFor "posts/first"}}|{{uuidFor "posts/second"}}`
+	result, err := l.processTemplate([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := strings.Split(string(result), "|")
+	if expected := "afa9d310-3a8f-56b5-9164-362c84c84368"; values[0] != expected {
+		t.Errorf("expected %q, got %q", expected, values[0])
+	}
+	if values[0] != values[1] {
+		t.Errorf("expected the same UUID for the same name, got %q and %q", values[0], values[1])
+	}
+	if values[0] == values[2] {
+		t.Errorf("expected different UUIDs for different names, got %q", values[0])
+	}
+
+	if err := UUIDNamespace("6BA7B810-9DAD-11D1-80B4-00C04FD430C8")(l); err != nil {
+		t.Fatal(err)
+	}
+	result, err = l.processTemplate([]byte(`{{uuidFor "posts/first"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "ee35aca4-84d0-58b3-b8e3-929717e3c8bc"; string(result) != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+
+	if err := UUIDNamespace("posts")(l); err == nil {
+		t.Error("expected an error with an invalid namespace")
+	}
+}
+
+func TestDumperSelectSQL(t *testing.T) {
 	tests := []struct {
 		helper   helper
-		keyword  string
+		maxRows  int
 		expected string
 	}{
-		{&postgreSQL{}, `posts_tags`, `"posts_tags"`},
-		{&postgreSQL{}, `test_schema.posts_tags`, `"test_schema"."posts_tags"`},
-		{&sqlserver{}, `posts_tags`, `[posts_tags]`},
-		{&sqlserver{}, `test_schema.posts_tags`, `[test_schema].[posts_tags]`},
+		{&postgreSQL{}, 0, `SELECT * FROM "posts"`},
+		{&postgreSQL{}, 10, `SELECT * FROM "posts" LIMIT 10`},
+		{&mySQL{}, 10, "SELECT * FROM `posts` LIMIT 10"},
+		{&sqlserver{}, 10, "SELECT TOP (10) * FROM [posts]"},
+		{&firebird{}, 10, `SELECT FIRST 10 * FROM "POSTS"`},
+	}
+	for _, test := range tests {
+		d := &Dumper{helper: test.helper, maxRows: test.maxRows}
+		if query := d.selectSQL("posts"); query != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, query)
+		}
 	}
+}
 
+func TestDumpUUID(t *testing.T) {
+	tests := []struct {
+		databaseType string
+		value        interface{}
+		expected     interface{}
+	}{
+		{"UUID", []byte("AFA9D310-3A8F-56B5-9164-362C84C84368"), "afa9d310-3a8f-56b5-9164-362c84c84368"},
+		{"UUID", "afa9d310-3a8f-56b5-9164-362c84c84368", "afa9d310-3a8f-56b5-9164-362c84c84368"},
+		{
+			"UNIQUEIDENTIFIER",
+			[]byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff},
+			"6f9619ff-8b86-d011-b42d-00c04fc964ff",
+		},
+		{"UUID", nil, nil},
+	}
 	for _, test := range tests {
-		actual := test.helper.quoteKeyword(test.keyword)
+		if value := dumpUUID(test.databaseType, test.value); value != test.expected {
+			t.Errorf("%s %v: expected %v, got %v", test.databaseType, test.value, test.expected, value)
+		}
+	}
+}
 
-		if test.expected != actual {
-			t.Errorf("TestQuoteKeyword keyword %s should have escaped to %s. Received %s instead", test.keyword, test.expected, actual)
+func TestRelativeTimes(t *testing.T) {
+	now := time.Date(2020, 1, 15, 12, 0, 0, 0, time.UTC)
+	l := &Loader{
+		helper: &postgreSQL{},
+		clock:  func() time.Time { return now },
+	}
+	f := &fixtureFile{
+		fileName: "posts.yml",
+		content:  []byte("- id: 1\n  created_at: NOW() - 3d\n  published_at: now() + 2h30m\n  updated_at: NOW()\n"),
+	}
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+
+	// The values are resolved again at each load.
+	now = now.Add(time.Hour)
+	l.resolveRelativeTimes([]*fixtureFile{f})
+	expected := map[string]interface{}{
+		"id":           1,
+		"created_at":   time.Date(2020, 1, 12, 13, 0, 0, 0, time.UTC),
+		"published_at": time.Date(2020, 1, 15, 15, 30, 0, 0, time.UTC),
+		"updated_at":   time.Date(2020, 1, 15, 13, 0, 0, 0, time.UTC),
+	}
+	if record := f.insertSQLs[0].record(); !reflect.DeepEqual(record, expected) {
+		t.Errorf("expected %v, got %v", expected, record)
+	}
+
+	for _, value := range []string{"NOW() + 3x", "NOW() - xd"} {
+		if _, err := tryStrToRelativeTime(value); err == nil {
+			t.Errorf("expected an error for %q", value)
 		}
 	}
 }
 
-func TestEnsureTestDatabase(t *testing.T) {
+func TestFileValues(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}, fileValues: true}
+	f := &fixtureFile{
+		path:     "testdata/fixtures_file_values/posts.yml",
+		fileName: "posts.yml",
+		content:  []byte("- id: 1\n  content: file:bodies/missing.html\n"),
+	}
+	err := l.buildFileInsertSQLs(f)
+	if err == nil {
+		t.Fatal("expected an error with a missing file")
+	}
+	for _, path := range []string{f.path, filepath.Join("testdata", "fixtures_file_values", "bodies", "missing.html")} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("expected the error to name %q, got %v", path, err)
+		}
+	}
+
+	// Without FileValues, the values are bound as is.
+	l.fileValues = false
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+	if value := f.insertSQLs[0].record()["content"]; value != "file:bodies/missing.html" {
+		t.Errorf("expected the value unchanged, got %v", value)
+	}
+}
+
+func TestNativeValues(t *testing.T) {
+	createdAt := time.Date(2020, 1, 15, 12, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+	values := map[interface{}]interface{}{
+		"id":         int64(1),
+		"published":  true,
+		"rating":     4.5,
+		"content":    []byte{0xca, 0xfe},
+		"created_at": createdAt,
+	}
+	l := &Loader{helper: &postgreSQL{}, location: time.UTC}
+	f := &fixtureFile{
+		fileName: "posts.yml",
+		records:  fixtureRecords{{values: values}},
+	}
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values already typed are bound as is, without being parsed like the
+	// strings of the YAML files.
+	record := f.insertSQLs[0].record()
+	for column, value := range values {
+		if !reflect.DeepEqual(record[column.(string)], value) {
+			t.Errorf("expected %s to be %#v, got %#v", column, value, record[column.(string)])
+		}
+	}
+}
+
+func TestColumnEncoder(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}}
+	for _, option := range []func(*Loader) error{
+		ColumnEncoder("products", "attributes", func(value interface{}) (interface{}, error) {
+			attributes, ok := value.(map[interface{}]interface{})
+			if !ok {
+				return nil, errors.New("attributes must be an object")
+			}
+			return fmt.Sprintf(`"color"=>"%v"`, attributes["color"]), nil
+		}),
+		ColumnCast("products", "attributes", "hstore"),
+	} {
+		if err := option(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := &fixtureFile{
+		fileName: "products.yml",
+		content:  []byte("chair:\n  id: 1\n  attributes:\n    color: red\n"),
+	}
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+	// The encoded value isn't converted to JSON, but still cast.
+	if value := f.insertSQLs[0].record()["attributes"]; value != `"color"=>"red"` {
+		t.Errorf("expected the encoded value, got %v", value)
+	}
+	if expected := `INSERT INTO "products" ("attributes", "id") VALUES ($1::hstore, $2)`; f.insertSQLs[0].sql != expected {
+		t.Errorf("expected %q, got %q", expected, f.insertSQLs[0].sql)
+	}
+
+	f = &fixtureFile{
+		fileName: "products.yml",
+		content:  []byte("chair:\n  id: 1\n  attributes: red\n"),
+	}
+	err := l.buildFileInsertSQLs(f)
+	if err == nil {
+		t.Fatal("expected the error of the encoder")
+	}
+	for _, part := range []string{`column "attributes"`, `record "chair"`, "must be an object"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("expected the error to contain %s, got %v", part, err)
+		}
+	}
+}
+
+func TestWatchRequiresTestDatabase(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}, databaseName: "production"}
+	if err := l.Watch(context.Background()); !errors.Is(err, ErrNotTestDatabase) {
+		t.Errorf("expected ErrNotTestDatabase, got %v", err)
+	}
+
+	if err := WatchInterval(0)(l); err == nil {
+		t.Error("expected an error for a zero interval")
+	}
+}
+
+func TestEnvValues(t *testing.T) {
+	t.Setenv("TESTFIXTURES_PASSWORD", "secret")
+	t.Setenv("TESTFIXTURES_EMPTY", "")
+
+	l := &Loader{helper: &postgreSQL{}}
+	if err := EnvValues()(l); err != nil {
+		t.Fatal(err)
+	}
+	f := &fixtureFile{
+		fileName: "users.yml",
+		content: []byte(`- password: env:TESTFIXTURES_PASSWORD
+  host: env:TESTFIXTURES_UNSET_HOST:localhost
+  url: env:TESTFIXTURES_UNSET_URL:http://localhost:8080
+  nickname: env:TESTFIXTURES_EMPTY:anonymous
+  id: 1
+`),
+	}
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"password": "secret",
+		"host":     "localhost",
+		"url":      "http://localhost:8080",
+		"nickname": "",
+		"id":       1,
+	}
+	if record := f.insertSQLs[0].record(); !reflect.DeepEqual(record, expected) {
+		t.Errorf("expected %v, got %v", expected, record)
+	}
+
+	f = &fixtureFile{
+		fileName: "users.yml",
+		content:  []byte("- password: env:TESTFIXTURES_UNSET_PASSWORD\n"),
+	}
+	err := l.buildFileInsertSQLs(f)
+	if err == nil || !strings.Contains(err.Error(), `environment variable "TESTFIXTURES_UNSET_PASSWORD" is not set`) {
+		t.Errorf("expected an error about the unset variable, got %v", err)
+	}
+
+	// Values are kept as is without EnvValues.
+	l = &Loader{helper: &postgreSQL{}}
+	f = &fixtureFile{
+		fileName: "users.yml",
+		content:  []byte("- password: env:TESTFIXTURES_PASSWORD\n"),
+	}
+	if err := l.buildFileInsertSQLs(f); err != nil {
+		t.Fatal(err)
+	}
+	if value := f.insertSQLs[0].record()["password"]; value != "env:TESTFIXTURES_PASSWORD" {
+		t.Errorf("expected the value to be kept, got %v", value)
+	}
+}
+
+func TestFileNameWithoutExtension(t *testing.T) {
+	paths := map[string]string{
+		"fixtures/posts.yml":          "posts",
+		"fixtures/posts.2.yml":        "posts",
+		"fixtures/posts.12.yml":       "posts",
+		"fixtures/public.posts.yml":   "public.posts",
+		"fixtures/public.posts.3.yml": "public.posts",
+		"fixtures/posts_v2.yml":       "posts_v2",
+		"fixtures/2.yml":              "2",
+		// Not parts without the file of their table next to them.
+		"fixtures/events.2021.yml": "events.2021",
+		"other/posts.3.yml":        "posts.3",
+		// Part numbers start at 2.
+		"fixtures/posts.1.yml": "posts.1",
+	}
+	files := make([]*fixtureFile, 0, len(paths))
+	for p := range paths {
+		files = append(files, &fixtureFile{path: p, fileName: filepath.Base(p)})
+	}
+	markPartFiles(files)
+	for _, f := range files {
+		if tableName := f.fileNameWithoutExtension(); tableName != paths[f.path] {
+			t.Errorf("%s: expected table %q, got %q", f.path, paths[f.path], tableName)
+		}
+	}
+}
+
+func TestGeneratedColumns(t *testing.T) {
+	l := &Loader{
+		helper: &postgreSQL{},
+		generatedColumns: &generatedColumns{
+			computed: map[string]map[string]bool{"products": {"total": true}},
+			identity: map[string]map[string]bool{"products": {"id": true}},
+		},
+	}
 	tests := []struct {
-		name           string
-		isTestDatabase bool
+		record   map[interface{}]interface{}
+		expected string
 	}{
-		{"db_test", true},
-		{"dbTEST", true},
-		{"testdb", true},
-		{"production", false},
-		{"productionTestCopy", true},
-		{"t_e_s_t", false},
-		{"ТESТ", false}, // cyrillic T
+		{
+			record:   map[interface{}]interface{}{"id": 1, "price": 10, "total": 12},
+			expected: `INSERT INTO "products" ("id", "price") OVERRIDING SYSTEM VALUE VALUES ($1, $2)`,
+		},
+		{
+			record:   map[interface{}]interface{}{"price": 10},
+			expected: `INSERT INTO "products" ("price") VALUES ($1)`,
+		},
+	}
+	for _, test := range tests {
+		sqlStr, values, _, err := l.buildInsertSQL(&fixtureFile{fileName: "products.yml"}, test.record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlStr != test.expected {
+			t.Errorf("expected %q, got %q", test.expected, sqlStr)
+		}
+		if len(values) != strings.Count(sqlStr, "$") {
+			t.Errorf("expected a value per placeholder, got %v", values)
+		}
 	}
 
-	for _, it := range tests {
-		var (
-			mockedHelper = NewMockHelper(it.name)
-			l            = &Loader{helper: mockedHelper}
-			err          = l.EnsureTestDatabase()
-		)
-		if err != nil && it.isTestDatabase {
-			t.Errorf("EnsureTestDatabase() should return nil for name = %s", it.name)
+	// Other tables are inserted as usual.
+	sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "orders.yml"}, map[interface{}]interface{}{"id": 1, "total": 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "orders" ("id", "total") VALUES ($1, $2)`; sqlStr != expected {
+		t.Errorf("expected %q, got %q", expected, sqlStr)
+	}
+}
+
+type generatedColumnsHelper struct {
+	MockHelper
+	err error
+}
+
+func (h *generatedColumnsHelper) generatedColumns(queryable) (*generatedColumns, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	return &generatedColumns{computed: map[string]map[string]bool{"products": {"total": true}}}, nil
+}
+
+func TestLoadGeneratedColumns(t *testing.T) {
+	l := &Loader{helper: &generatedColumnsHelper{}}
+	l.loadGeneratedColumns()
+	if !l.computedColumns("products")["total"] {
+		t.Errorf("expected the generated columns to be listed, got %v", l.generatedColumns)
+	}
+
+	// Databases which can't list them are loaded as if there were none.
+	l = &Loader{helper: &generatedColumnsHelper{err: errors.New("column \"is_generated\" does not exist")}}
+	l.loadGeneratedColumns()
+	if l.generatedColumns != nil || l.computedColumns("products") != nil {
+		t.Errorf("expected no generated columns, got %v", l.generatedColumns)
+	}
+}
+
+func TestGeneratedColumnsSchema(t *testing.T) {
+	record := map[interface{}]interface{}{"price": 10, "total": 12}
+	expected := `INSERT INTO "app"."products" ("price") VALUES ($1)`
+
+	for _, opt := range []func(*Loader) error{Schema("app"), TargetSchemas("app")} {
+		l := &Loader{
+			helper: &postgreSQL{},
+			generatedColumns: &generatedColumns{
+				computed: map[string]map[string]bool{"app.products": {"total": true}},
+				identity: map[string]map[string]bool{},
+			},
 		}
-		if err == nil && !it.isTestDatabase {
-			t.Errorf("EnsureTestDatabase() should return error for name = %s", it.name)
+		if err := opt(l); err != nil {
+			t.Fatal(err)
+		}
+		files, err := l.schemaFiles("app", []*fixtureFile{{fileName: "products.yml"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sqlStr, _, _, err := l.buildInsertSQL(files[0], record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sqlStr != expected {
+			t.Errorf("expected %q, got %q", expected, sqlStr)
+		}
+	}
+
+	// Files not naming a schema are looked up in the one given with Schema.
+	l := &Loader{
+		helper: &postgreSQL{},
+		schema: "app",
+		generatedColumns: &generatedColumns{
+			computed: map[string]map[string]bool{"app.products": {"total": true}},
+		},
+	}
+	sqlStr, _, _, err := l.buildInsertSQL(&fixtureFile{fileName: "products.yml"}, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "products" ("price") VALUES ($1)`; sqlStr != expected {
+		t.Errorf("expected %q, got %q", expected, sqlStr)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	l := &Loader{
+		helper:      &postgreSQL{},
+		schema:      "app",
+		deleteWhere: map[string]string{"users": "id > 1"},
+	}
+	var (
+		users = &fixtureFile{fileName: "users.yml", content: []byte("- id: 1\n")}
+		logs  = &fixtureFile{fileName: "audit.logs.yml", content: []byte("- id: 1\n")}
+	)
+	for _, f := range []*fixtureFile{users, logs} {
+		if err := l.buildFileInsertSQLs(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := l.schemaFiles("app", []*fixtureFile{users, logs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `INSERT INTO "app"."users" ("id") VALUES ($1)`; files[0].insertSQLs[0].sql != expected {
+		t.Errorf("expected %q, got %q", expected, files[0].insertSQLs[0].sql)
+	}
+	if files[1] != logs {
+		t.Errorf("expected the file naming a schema to be kept, got %q", files[1].fileName)
+	}
+	if expected := `DELETE FROM "app"."users" WHERE id > 1`; l.cleanTableSQL("app.users") != expected {
+		t.Errorf("expected %q, got %q", expected, l.cleanTableSQL("app.users"))
+	}
+	if again, _ := l.schemaFiles("app", []*fixtureFile{users, logs}); again[0] != files[0] {
+		t.Error("expected the files of the schema to be built once")
+	}
+
+	l = &Loader{helper: &sqlite{}, schema: "app"}
+	if err := l.checkSchema(); err == nil {
+		t.Error("expected an error with SQLite")
+	}
+}
+
+func TestCanCopy(t *testing.T) {
+	l := &Loader{helper: &postgreSQL{}, copyFrom: true}
+	f := &fixtureFile{fileName: "tags.yml"}
+	for _, record := range []map[interface{}]interface{}{
+		{"id": 1, "name": "Go"},
+		{"id": 2, "name": "SQL", "created_at": "RAW=NOW()"},
+	} {
+		sqlStr, values, columns, err := l.buildInsertSQL(f, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.insertSQLs = append(f.insertSQLs, insertSQL{sql: sqlStr, params: values, columns: columns})
+	}
+
+	if sqlStr := l.plainInsertSQL("tags", f.insertSQLs[0].columns); sqlStr != f.insertSQLs[0].sql {
+		t.Errorf("expected %q to be a plain insert, got %q", f.insertSQLs[0].sql, sqlStr)
+	}
+	if sqlStr := l.plainInsertSQL("tags", f.insertSQLs[1].columns); sqlStr == f.insertSQLs[1].sql {
+		t.Errorf("expected %q not to be a plain insert", sqlStr)
+	}
+	if l.canCopy(&fixtureFile{fileName: "tags.yml", insertSQLs: f.insertSQLs[:1]}) {
+		t.Error("expected the records not to be copied without the lib/pq driver")
+	}
+
+	expected := `COPY "tags" ("id", "name") FROM STDIN`
+	if sqlStr := l.helper.(copier).copySQL("tags", l.quoteColumns([]string{"id", "name"})); sqlStr != expected {
+		t.Errorf("expected %q, got %q", expected, sqlStr)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	var (
+		posts    = &fixtureFile{fileName: "posts.yml"}
+		users    = &fixtureFile{fileName: "users.yml"}
+		tags     = &fixtureFile{fileName: "tags.yml"}
+		comments = &fixtureFile{fileName: "comments.yml"}
+	)
+	l := &Loader{}
+	if err := Order("users", "comments.yml")(l); err != nil {
+		t.Fatal(err)
+	}
+
+	files := []*fixtureFile{posts, tags, comments, users}
+	expected := []*fixtureFile{users, comments, posts, tags}
+	ordered := l.orderFiles(files)
+	for i := range expected {
+		if ordered[i] != expected[i] {
+			t.Fatalf("expected %s at position %d, got %s", expected[i].fileName, i, ordered[i].fileName)
+		}
+	}
+	if files[0] != posts {
+		t.Error("expected the given files not to be modified")
+	}
+
+	if err := Order("posts")(l); err == nil {
+		t.Error("expected an error when Order is given twice")
+	}
+	if err := Order("posts", "posts")(&Loader{}); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+}
+
+// recordingTB records the errors of a test instead of failing it.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func TestNamedParams(t *testing.T) {
+	l := &Loader{helper: &sqlserver{}}
+	if err := UseNamedParams()(l); err != nil {
+		t.Fatal(err)
+	}
+	// As set by init, which needs a database.
+	l.helper.(*sqlserver).paramTypeCache = paramTypeAtSign
+
+	sqlStr, values, _, err := l.buildInsertSQL(&fixtureFile{fileName: "tags.yml"}, map[interface{}]interface{}{"id": 1, "name": "Go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "INSERT INTO [tags] ([id], [name]) VALUES (@p1, @p2)"; sqlStr != expected {
+		t.Errorf("expected %q, got %q", expected, sqlStr)
+	}
+	expected := []interface{}{sql.Named("p1", 1), sql.Named("p2", "Go")}
+	if args := l.bindParams(values); !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %v, got %v", expected, args)
+	}
+
+	if err := UseNamedParams()(&Loader{helper: &postgreSQL{}}); err == nil {
+		t.Error("expected an error for PostgreSQL")
+	}
+}
+
+// foreignKeysHelper is a MockHelper listing the given foreign keys.
+type foreignKeysHelper struct {
+	MockHelper
+	keys []foreignKey
+}
+
+func (h *foreignKeysHelper) foreignKeys(queryable) ([]foreignKey, error) {
+	return h.keys, nil
+}
+
+func TestSortByDependencies(t *testing.T) {
+	var (
+		comments  = &fixtureFile{fileName: "comments.yml"}
+		posts     = &fixtureFile{fileName: "posts.yml"}
+		postsTags = &fixtureFile{fileName: "posts_tags.yml"}
+		tags      = &fixtureFile{fileName: "tags.yml"}
+		users     = &fixtureFile{fileName: "users.yml"}
+	)
+	l := &Loader{helper: &foreignKeysHelper{keys: []foreignKey{
+		{table: "comments", referencedTable: "posts"},
+		{table: "posts_tags", referencedTable: "posts"},
+		{table: "posts_tags", referencedTable: "tags"},
+		{table: "tags", referencedTable: "tags"},
+		{table: "audit", referencedTable: "users"},
+	}}}
+
+	sorted, err := l.sortByDependencies([]*fixtureFile{comments, postsTags, users, posts, tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*fixtureFile{users, posts, comments, tags, postsTags}
+	for i := range expected {
+		if sorted[i] != expected[i] {
+			t.Fatalf("expected %s at position %d, got %s", expected[i].fileName, i, sorted[i].fileName)
 		}
 	}
+
+	l.helper.(*foreignKeysHelper).keys = append(l.helper.(*foreignKeysHelper).keys, foreignKey{table: "posts", referencedTable: "comments"})
+	_, err = l.sortByDependencies([]*fixtureFile{comments, postsTags, users, posts, tags})
+	if err == nil || !strings.Contains(err.Error(), "comments -> posts -> comments") {
+		t.Errorf("expected an error naming the cycle, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "drop DependencyOrder") {
+		t.Errorf("expected the error to tell to drop DependencyOrder, got %v", err)
+	}
+}
+
+func TestCheckReferencedTables(t *testing.T) {
+	l := &Loader{helper: &foreignKeysHelper{keys: []foreignKey{
+		{table: "comments", referencedTable: "posts"},
+		{table: "posts_tags", referencedTable: "posts"},
+		{table: "posts_tags", referencedTable: "tags"},
+		{table: "votes", referencedTable: "comments"},
+		{table: "employees", referencedTable: "employees"},
+	}}}
+	withRecords := func(fileName string, count int) *fixtureFile {
+		return &fixtureFile{fileName: fileName, insertSQLs: make([]insertSQL, count)}
+	}
+
+	// The records of tags were all filtered out, like with LoadTags.
+	files := []*fixtureFile{withRecords("comments.yml", 2), withRecords("posts_tags.yml", 1), withRecords("tags.yml", 0), withRecords("employees.yml", 1)}
+	err := l.checkReferencedTables(files)
+	expected := "testfixtures: tables without fixtures are referenced by tables with fixtures: posts (referenced by comments, posts_tags); tags (referenced by posts_tags)"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	files = append(files, withRecords("posts.yml", 1), withRecords("tags.yml", 1))
+	if err := l.checkReferencedTables(files); err != nil {
+		t.Errorf("expected no error when all the referenced tables have fixtures, got %v", err)
+	}
+}
+
+func TestDependentTables(t *testing.T) {
+	l := &Loader{helper: &foreignKeysHelper{keys: []foreignKey{
+		{table: "comments", referencedTable: "posts"},
+		{table: `public."Votes"`, referencedTable: "comments"},
+		{table: "comments", referencedTable: "comments"},
+		{table: "posts_tags", referencedTable: "posts"},
+		{table: "posts_tags", referencedTable: "tags"},
+		{table: "audit", referencedTable: "users"},
+	}}}
+
+	tables, err := l.dependentTables(nil, []*fixtureFile{{fileName: "posts.yml"}, {fileName: "tags.yml"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"public.Votes", "posts_tags", "comments"}
+	if !reflect.DeepEqual(tables, expected) {
+		t.Errorf("expected %v, got %v", expected, tables)
+	}
+
+	t.Run("ReferencingTablesFirst", func(t *testing.T) {
+		// x references y, which is found after it, as it's farther from a.
+		l := &Loader{helper: &foreignKeysHelper{keys: []foreignKey{
+			{table: "x", referencedTable: "a"},
+			{table: "x", referencedTable: "y"},
+			{table: "y", referencedTable: "b"},
+			{table: "b", referencedTable: "a"},
+		}}}
+		tables, err := l.dependentTables(nil, []*fixtureFile{{fileName: "a.yml"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"x", "y", "b"}
+		if !reflect.DeepEqual(tables, expected) {
+			t.Errorf("expected %v, got %v", expected, tables)
+		}
+	})
 }
@@ -2,9 +2,16 @@ package testfixtures
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// durationPrefix marks string values holding a Go duration (like "90m"),
+// which are inserted as an interval.
+const durationPrefix = "DURATION="
+
 var timeFormats = [...]string{
 	"2006-01-02",
 	"2006-01-02 15:04",
@@ -41,3 +48,112 @@ func (l *Loader) tryStrToDate(s string) (time.Time, error) {
 	}
 	return time.Time{}, fmt.Errorf(`testfixtures: could not convert string "%s" to time`, s)
 }
+
+// formatInterval formats a duration as "[-]HH:MM:SS[.ffffff]", which is
+// understood by PostgreSQL interval and MySQL time columns.
+func formatInterval(d time.Duration) string {
+	var sign string
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	var (
+		hours   = d / time.Hour
+		minutes = d % time.Hour / time.Minute
+		seconds = d % time.Minute / time.Second
+		micros  = d % time.Second / time.Microsecond
+	)
+	interval := fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, minutes, seconds)
+	if micros > 0 {
+		interval += fmt.Sprintf(".%06d", micros)
+	}
+	return interval
+}
+
+// tryStrToInterval converts a string prefixed with durationPrefix to an
+// interval.
+func tryStrToInterval(s string) (string, error) {
+	if !strings.HasPrefix(s, durationPrefix) {
+		return "", fmt.Errorf("not a duration, must be prefixed with %s", durationPrefix)
+	}
+	d, err := time.ParseDuration(strings.TrimPrefix(s, durationPrefix))
+	if err != nil {
+		return "", fmt.Errorf(`testfixtures: could not convert string "%s" to interval: %w`, s, err)
+	}
+	return formatInterval(d), nil
+}
+
+// relativeTimeRegexp matches the values relative to the time of the load,
+// like "NOW()", "NOW() - 3d" or "NOW() + 2h30m".
+var relativeTimeRegexp = regexp.MustCompile(`^(?i:NOW\(\))(?:\s*([+-])\s*(\S+))?$`)
+
+// relativeTime is a value relative to the time of the load, inserted as a
+// time.Time.
+type relativeTime time.Duration
+
+// Clock sets the function returning the current time, which the values like
+// "NOW() - 3d" are relative to, for tests with a frozen time. By default,
+// it's time.Now.
+func Clock(now func() time.Time) func(*Loader) error {
+	return func(l *Loader) error {
+		l.clock = now
+		return nil
+	}
+}
+
+func (l *Loader) now() time.Time {
+	if l.clock != nil {
+		return l.clock()
+	}
+	return time.Now()
+}
+
+// tryStrToRelativeTime converts a string like "NOW() - 3d" to a
+// relativeTime. Besides the units of time.ParseDuration, "d" can be used for
+// days, at the start of the duration, like "1d12h".
+func tryStrToRelativeTime(s string) (relativeTime, error) {
+	m := relativeTimeRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf(`testfixtures: could not convert string "%s" to time: not relative to NOW()`, s)
+	}
+	if m[2] == "" {
+		return 0, nil
+	}
+
+	var (
+		duration = m[2]
+		days     int
+	)
+	if i := strings.Index(duration, "d"); i > 0 {
+		n, err := strconv.Atoi(duration[:i])
+		if err != nil {
+			return 0, fmt.Errorf(`testfixtures: could not convert string "%s" to time: %w`, s, err)
+		}
+		days, duration = n, duration[i+1:]
+	}
+	d := time.Duration(days) * 24 * time.Hour
+	if duration != "" {
+		rest, err := time.ParseDuration(duration)
+		if err != nil {
+			return 0, fmt.Errorf(`testfixtures: could not convert string "%s" to time: %w`, s, err)
+		}
+		d += rest
+	}
+	if m[1] == "-" {
+		d = -d
+	}
+	return relativeTime(d), nil
+}
+
+// resolveRelativeTimes sets the params of the inserts given as relative
+// times to the current time, the same for all of them.
+func (l *Loader) resolveRelativeTimes(files []*fixtureFile) {
+	now := l.now()
+	for _, file := range files {
+		for _, i := range file.insertSQLs {
+			for k, d := range i.relativeTimes {
+				i.params[k] = now.Add(d)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// +build timescaledb
+
+package testfixtures
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+func TestTimescaleDB(t *testing.T) {
+	testLoader(
+		t,
+		"postgres",
+		os.Getenv("TIMESCALEDB_CONN_STRING"),
+		"testdata/schema/postgresql.sql",
+		UseTimescaleDB(),
+	)
+}
+
+func TestTimescaleDBHypertables(t *testing.T) {
+	db, err := sql.Open("postgres", os.Getenv("TIMESCALEDB_CONN_STRING"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	schemaLoader, err := New(Database(db), Dialect("timescaledb"))
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	if err := schemaLoader.LoadSchemaFile("testdata/schema/timescaledb.sql"); err != nil {
+		t.Fatalf("cannot load schema: %v", err)
+	}
+
+	// The fixtures span three days and the chunk time interval is one
+	// day, so the hypertable has rows on several chunks.
+	l, err := New(
+		Database(db),
+		Dialect("timescaledb"),
+		Files("testdata/fixtures_timescaledb/metrics.yml"),
+	)
+	if err != nil {
+		t.Fatalf("failed to create Loader: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := l.Load(); err != nil {
+			t.Fatalf("cannot load fixtures: %v", err)
+		}
+		assertCount(t, l, "metrics", 3)
+	}
+}
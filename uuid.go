@@ -0,0 +1,111 @@
+package testfixtures
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// defaultUUIDNamespace is the namespace of the UUIDs of uuidFor when
+// UUIDNamespace is not given. It's the version 5 UUID of the URL of the
+// project.
+const defaultUUIDNamespace = "55a3dc73-a536-57b5-91b8-c0471c52f893"
+
+// UUIDNamespace sets the namespace of the UUIDs generated by the "uuidFor"
+// template function, like in '{{uuidFor "posts/first"}}', which returns the
+// version 5 UUID of the name in the namespace. Give it before the options
+// reading the fixture files.
+//
+// Defaults to a namespace of testfixtures.
+func UUIDNamespace(namespace string) func(*Loader) error {
+	return func(l *Loader) error {
+		ns, err := parseUUID(namespace)
+		if err != nil {
+			return fmt.Errorf("testfixtures: UUIDNamespace: %w", err)
+		}
+		l.uuidNamespace = &ns
+		return nil
+	}
+}
+
+// uuidFuncs returns the template functions generating UUIDs, which are
+// available to all templates, unless TemplateFuncs gives functions with the
+// same names: "uuidFor name", returning the version 5 UUID of the name in
+// the namespace given with UUIDNamespace. The same name always gives the
+// same UUID, so records can reference each other by name, across files and
+// runs.
+func (l *Loader) uuidFuncs() template.FuncMap {
+	namespace := l.uuidNamespace
+	if namespace == nil {
+		ns, _ := parseUUID(defaultUUIDNamespace)
+		namespace = &ns
+	}
+	return template.FuncMap{
+		"uuidFor": func(name string) string {
+			return uuidV5(*namespace, name)
+		},
+	}
+}
+
+// uuidV5 returns the version 5 UUID of a name in a namespace, as defined by
+// RFC 4122.
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	b[6] = b[6]&0x0f | 0x50 // version 5
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+	return formatUUID(b)
+}
+
+// formatUUID returns the canonical form of a UUID, in lowercase.
+func formatUUID(b [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// parseUUID parses a UUID in its canonical form, in any case.
+func parseUUID(s string) ([16]byte, error) {
+	var b [16]byte
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return b, fmt.Errorf(`invalid UUID "%s"`, s)
+	}
+	if _, err := hex.Decode(b[:], []byte(strings.ReplaceAll(s, "-", ""))); err != nil {
+		return b, fmt.Errorf(`invalid UUID "%s": %w`, s, err)
+	}
+	return b, nil
+}
+
+// dumpUUID returns the canonical form of the value of a UUID column, given
+// its database type, as drivers return them as strings in any case or as
+// bytes. SQL Server stores the first three groups in little-endian order.
+func dumpUUID(databaseType string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		if len(v) == 16 {
+			var b [16]byte
+			copy(b[:], v)
+			if databaseType == "UNIQUEIDENTIFIER" {
+				b[0], b[1], b[2], b[3] = b[3], b[2], b[1], b[0]
+				b[4], b[5] = b[5], b[4]
+				b[6], b[7] = b[7], b[6]
+			}
+			return formatUUID(b)
+		}
+		return dumpUUID(databaseType, string(v))
+	case string:
+		if b, err := parseUUID(v); err == nil {
+			return formatUUID(b)
+		}
+	}
+	return value
+}
+
+// isUUIDType reports whether a column of the database type holds UUIDs.
+func isUUIDType(databaseType string) bool {
+	return databaseType == "UUID" || databaseType == "UNIQUEIDENTIFIER"
+}
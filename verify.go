@@ -0,0 +1,47 @@
+package testfixtures
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Verify checks that each table with fixtures has as many rows as records in
+// its fixture files, which catches triggers or cascades deleting or adding
+// rows while loading. Call it after Load:
+//
+//     if err := fixtures.Verify(); err != nil {
+//             ...
+//     }
+//
+// Tables are expected to have only the fixtures, so Verify is not meant to
+// be used with the DeleteWhere and Upsert options.
+func (l *Loader) Verify() error {
+	var (
+		tables     []string
+		records    = make(map[string]int)
+		mismatches []string
+	)
+	for _, file := range l.fixturesFiles {
+		tableName := file.fileNameWithoutExtension()
+		if _, ok := records[tableName]; !ok {
+			tables = append(tables, tableName)
+		}
+		records[tableName] += len(file.insertSQLs)
+	}
+
+	for _, tableName := range tables {
+		var count int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s", l.helper.quoteKeyword(tableName))
+		if err := l.db.QueryRow(query).Scan(&count); err != nil {
+			return fmt.Errorf(`testfixtures: could not count rows of table "%s": %w`, tableName, err)
+		}
+		if count != records[tableName] {
+			mismatches = append(mismatches, fmt.Sprintf(`table "%s" has %d rows, but %d records in fixtures`, tableName, count, records[tableName]))
+		}
+	}
+
+	if len(mismatches) > 0 {
+		return fmt.Errorf("testfixtures: loaded data does not match fixtures: %s", strings.Join(mismatches, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package testfixtures
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const defaultWatchInterval = 500 * time.Millisecond
+
+// WatchInterval sets how often Watch checks the fixture files for changes.
+//
+// Defaults to 500 milliseconds.
+func WatchInterval(interval time.Duration) func(*Loader) error {
+	return func(l *Loader) error {
+		if interval <= 0 {
+			return fmt.Errorf("testfixtures: WatchInterval requires a positive interval")
+		}
+		l.watchInterval = interval
+		return nil
+	}
+}
+
+// Watch reads the fixture files again and loads them each time they change,
+// until the context is done, for seeding a local database while editing
+// them. It doesn't load them first, so call Load before.
+//
+// The files, and the directories of the files found in directories, are
+// checked every WatchInterval, by their modification time and size, without
+// relying on file system notifications. Fixtures are reloaded once the files
+// stopped changing for an interval, so saving many files at once reloads
+// them once. Each reload, and its error if any, is given to the function
+// set with Logger as a SQL comment, like "-- testfixtures: ...", and doesn't
+// stop watching.
+//
+// Like Load, Watch returns an error without watching if the database doesn't
+// look like a test one, unless DangerousSkipTestDatabaseCheck is given. It
+// returns nil when the context is done. The Loader must not be used by other
+// goroutines while watching.
+func (l *Loader) Watch(ctx context.Context) error {
+	if !l.skipTestDatabaseCheck {
+		if err := l.EnsureTestDatabase(); err != nil {
+			return err
+		}
+	}
+
+	interval := l.watchInterval
+	if interval == 0 {
+		interval = defaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Files changed since they were read by New or Reload are reloaded.
+	var (
+		last    = l.readFileStates
+		changed bool
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		states := l.fileStates()
+		if !states.equal(last) {
+			last = states
+			changed = true
+			continue
+		}
+		if !changed {
+			continue
+		}
+		changed = false
+
+		err := l.Reload()
+		if err == nil {
+			err = l.LoadContext(ctx)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			l.watchLog(fmt.Sprintf("-- testfixtures: could not reload fixtures: %v", err))
+		} else {
+			l.watchLog(fmt.Sprintf("-- testfixtures: reloaded %d fixture files", len(l.fixturesFiles)))
+		}
+		last = l.readFileStates
+	}
+}
+
+func (l *Loader) watchLog(message string) {
+	if l.logger != nil {
+		l.logger(message, nil)
+	}
+}
+
+// fileState is what Watch compares to tell whether a file changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+type fileStates map[string]fileState
+
+// fileStates returns the states of the fixture files, of the directories
+// they were found in, and of the missing ones allowed by AllowMissingFiles.
+func (l *Loader) fileStates() fileStates {
+	states := make(fileStates)
+	add := func(path string) {
+		if _, ok := states[path]; ok {
+			return
+		}
+		var state fileState
+		if info, err := os.Stat(path); err == nil {
+			state = fileState{modTime: info.ModTime(), size: info.Size(), exists: true}
+		}
+		states[path] = state
+	}
+	for _, file := range l.fixturesFiles {
+		switch {
+		case file.sourcePath != "":
+			add(file.sourcePath)
+		case file.path != "":
+			add(file.path)
+		}
+		// Files added to or removed from a directory change it.
+		if file.fromDir {
+			add(filepath.Dir(file.path))
+		}
+	}
+	for _, path := range l.missingFiles {
+		add(path)
+	}
+	return states
+}
+
+func (s fileStates) equal(other fileStates) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for path, state := range s {
+		otherState, ok := other[path]
+		if !ok || !state.modTime.Equal(otherState.modTime) || state.size != otherState.size || state.exists != otherState.exists {
+			return false
+		}
+	}
+	return true
+}